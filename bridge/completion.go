@@ -0,0 +1,219 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	server "github.com/mark3labs/mcp-go/server"
+)
+
+// methodCompletionComplete 参数补全请求方法，mcp-go 服务端没有内置处理，需要在 HTTP 层拦截后转发
+const methodCompletionComplete = "completion/complete"
+
+// headerKeySessionID Streamable HTTP 会话ID请求头
+const headerKeySessionID = "Mcp-Session-Id"
+
+// completionTimeout 转发补全请求到上游的超时时间
+const completionTimeout = 30 * time.Second
+
+// completionRequest 补全请求，额外保留请求ID用于构造响应
+type completionRequest struct {
+	ID mcp.RequestId `json:"id"`
+	mcp.CompleteRequest
+}
+
+// readRequest 读取请求体中的 JSON-RPC 请求，读取后恢复请求体供后续处理器使用
+func readRequest(r *http.Request) (*completionRequest, bool) {
+	if r.Method != http.MethodPost || r.Body == nil {
+		return nil, false
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil, false
+	}
+
+	var request completionRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, false
+	}
+	return &request, true
+}
+
+// readCompletionRequest 读取请求体并判断是否是补全请求
+func readCompletionRequest(r *http.Request) (*completionRequest, bool) {
+	request, ok := readRequest(r)
+	if !ok || request.Method != methodCompletionComplete {
+		return nil, false
+	}
+	return request, true
+}
+
+// streamableHTTPHandler 包装 Streamable HTTP 处理器，补全请求直接转发到上游并以 JSON 响应返回
+func (p *upstreamProxy) streamableHTTPHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request, ok := readRequest(r)
+		if !ok || request.Method != methodCompletionComplete {
+			// initialize 的响应直接写在 POST 响应中，需要补上 completions 能力
+			if ok && p.completions && request.Method == string(mcp.MethodInitialize) {
+				w = &completionsWriter{ResponseWriter: w}
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), completionTimeout)
+		defer cancel()
+		response := p.complete(ctx, request.ID, request.CompleteRequest)
+
+		w.Header().Set("Content-Type", "application/json")
+		if sessionID := r.Header.Get(headerKeySessionID); sessionID != "" {
+			w.Header().Set(headerKeySessionID, sessionID)
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			p.logger.Error("Failed to write completion response", "error", err)
+		}
+	})
+}
+
+// sseHandler 包装 SSE 服务器，消息端点收到补全请求时转发到上游，响应通过对应会话的 SSE 流返回
+func (p *upstreamProxy) sseHandler(sseServer *server.SSEServer) http.Handler {
+	messagePath := sseServer.CompleteMessagePath()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != messagePath {
+			// initialize 的响应通过事件流返回，需要补上 completions 能力
+			if p.completions {
+				w = &completionsWriter{ResponseWriter: w}
+			}
+			sseServer.ServeHTTP(w, r)
+			return
+		}
+		request, ok := readCompletionRequest(r)
+		if !ok {
+			sseServer.ServeHTTP(w, r)
+			return
+		}
+
+		sessionID := r.URL.Query().Get("sessionId")
+		if sessionID == "" {
+			// 交给 SSE 服务器返回标准的错误响应
+			sseServer.ServeHTTP(w, r)
+			return
+		}
+
+		// 与 SSE 服务器一致: 先返回 202，响应通过事件流异步发送
+		w.WriteHeader(http.StatusAccepted)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+			defer cancel()
+			response := p.complete(ctx, request.ID, request.CompleteRequest)
+			if err := sseServer.SendEventToSession(sessionID, response); err != nil {
+				p.logger.Error("Failed to send completion response", "session_id", sessionID, "error", err)
+			}
+		}()
+	})
+}
+
+// capabilityRecorder 包装上游传输层，记录上游 initialize 结果中 mcp-go 未解析的能力
+type capabilityRecorder struct {
+	transport.Interface
+	completions atomic.Bool
+}
+
+// SendRequest 转发请求，initialize 成功时记录上游是否声明了 completions 能力
+func (t *capabilityRecorder) SendRequest(ctx context.Context, request transport.JSONRPCRequest) (*transport.JSONRPCResponse, error) {
+	response, err := t.Interface.SendRequest(ctx, request)
+	if err != nil || response.Error != nil || request.Method != string(mcp.MethodInitialize) {
+		return response, err
+	}
+	var result struct {
+		Capabilities map[string]json.RawMessage `json:"capabilities"`
+	}
+	if json.Unmarshal(response.Result, &result) == nil {
+		_, ok := result.Capabilities["completions"]
+		t.completions.Store(ok)
+	}
+	return response, nil
+}
+
+// completionsWriter 在写出的 initialize 响应中声明 completions 能力
+// mcp-go 的 ServerCapabilities 没有 completions 字段，桥接层 MCP 服务器无法直接声明，只能改写响应
+// 响应可能是 JSON 响应体，也可能是 SSE 事件，每次写入都是一条完整的消息
+type completionsWriter struct {
+	http.ResponseWriter
+}
+
+// sseMessagePrefix SSE 消息事件的前缀
+const sseMessagePrefix = "event: message\ndata: "
+
+func (w *completionsWriter) Write(data []byte) (int, error) {
+	var patched []byte
+	if rest, ok := bytes.CutPrefix(data, []byte(sseMessagePrefix)); ok {
+		if message, ok := advertiseCompletions(bytes.TrimRight(rest, "\n")); ok {
+			patched = append(append([]byte(sseMessagePrefix), message...), "\n\n"...)
+		}
+	} else if message, ok := advertiseCompletions(bytes.TrimRight(data, "\n")); ok {
+		patched = append(message, '\n')
+	}
+	if patched == nil {
+		return w.ResponseWriter.Write(data)
+	}
+	if _, err := w.ResponseWriter.Write(patched); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Flush SSE 服务器要求响应支持 http.Flusher
+func (w *completionsWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap 供 http.ResponseController 访问原始响应
+func (w *completionsWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// advertiseCompletions 消息是 initialize 结果时在能力中加入 completions，其他消息不变
+func advertiseCompletions(data []byte) ([]byte, bool) {
+	var message map[string]json.RawMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		return nil, false
+	}
+	var result map[string]json.RawMessage
+	if err := json.Unmarshal(message["result"], &result); err != nil {
+		return nil, false
+	}
+	if _, ok := result["protocolVersion"]; !ok {
+		return nil, false
+	}
+	if _, ok := result["serverInfo"]; !ok {
+		return nil, false
+	}
+	capabilities := make(map[string]json.RawMessage)
+	if raw, ok := result["capabilities"]; ok {
+		if err := json.Unmarshal(raw, &capabilities); err != nil {
+			return nil, false
+		}
+	}
+	capabilities["completions"] = json.RawMessage(`{}`)
+
+	var err error
+	if result["capabilities"], err = json.Marshal(capabilities); err != nil {
+		return nil, false
+	}
+	if message["result"], err = json.Marshal(result); err != nil {
+		return nil, false
+	}
+	patched, err := json.Marshal(message)
+	return patched, err == nil
+}
@@ -0,0 +1,78 @@
+package bridge
+
+import (
+	"io"
+	"time"
+)
+
+// defaultResyncInterval 默认定期重新同步上游列表的间隔
+const defaultResyncInterval = time.Minute
+
+// streamableHTTPPath Streamable HTTP 端点的路径，所有桥接模式都使用 /{mcpName}/mcp
+func streamableHTTPPath(mcpName string) string {
+	return "/" + mcpName + "/mcp"
+}
+
+// BridgeOption 桥接器的可选配置
+type BridgeOption func(*bridgeOptions)
+
+type bridgeOptions struct {
+	streamableHTTP bool          // 是否额外暴露 Streamable HTTP 端点
+	resyncInterval time.Duration // 定期重新同步上游列表的间隔，0 表示只在收到 list_changed 通知时同步
+	stderr         io.Writer     // stdio 子进程 stderr 的输出目标
+}
+
+// WithStreamableHTTP 在 SSE 桥接器上同时暴露 Streamable HTTP 端点，共用同一个上游连接
+func WithStreamableHTTP() BridgeOption {
+	return func(o *bridgeOptions) {
+		o.streamableHTTP = true
+	}
+}
+
+// WithResyncInterval 设置定期重新同步上游工具、资源、提示词列表的间隔，0 表示关闭定期同步
+func WithResyncInterval(interval time.Duration) BridgeOption {
+	return func(o *bridgeOptions) {
+		o.resyncInterval = interval
+	}
+}
+
+// WithStderr 将 stdio 子进程的 stderr 写入 w，子进程启动后立即开始读取，初始化失败时的输出也会保留
+func WithStderr(w io.Writer) BridgeOption {
+	return func(o *bridgeOptions) {
+		o.stderr = w
+	}
+}
+
+// stderrDrainTimeout 初始化失败时等待读取剩余 stderr 的最长时间
+const stderrDrainTimeout = time.Second
+
+// pipeStderr 持续读取子进程 stderr 直到进程退出或 transport 关闭，读取结束后关闭返回的通道
+func pipeStderr(stderr io.Reader, w io.Writer) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if stderr != nil {
+			_, _ = io.Copy(w, stderr)
+		}
+	}()
+	return done
+}
+
+// waitStderr 初始化失败时等待子进程的 stderr 读取完毕，保留失败原因
+func waitStderr(done <-chan struct{}) {
+	if done == nil {
+		return
+	}
+	select {
+	case <-done:
+	case <-time.After(stderrDrainTimeout):
+	}
+}
+
+func newBridgeOptions(opts ...BridgeOption) *bridgeOptions {
+	o := &bridgeOptions{resyncInterval: defaultResyncInterval}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
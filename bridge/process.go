@@ -0,0 +1,149 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+)
+
+// stderrTailLines 子进程退出时保留的 stderr 行数
+const stderrTailLines = 20
+
+// processKillGrace 关闭 stdin 后等待子进程自行退出的时间，超时后强制结束子进程
+var processKillGrace = 5 * time.Second
+
+// ProcessExit stdio 子进程意外退出的信息
+type ProcessExit struct {
+	ExitCode int      // 退出码，被信号终止或无法获取时为 -1
+	Err      error    // 回收子进程时返回的错误
+	Stderr   []string // 退出前 stderr 的最后几行
+}
+
+// processWatcher 监视 stdio 子进程: 持续读取 stderr，stderr 关闭即视为子进程已退出
+// 非主动关闭时回收子进程并通过 exited 通知退出信息，主动关闭时 exited 直接关闭
+type processWatcher struct {
+	transport  *transport.Stdio
+	kill       context.CancelFunc // 取消子进程的 context，强制结束子进程
+	tail       *stderrTail
+	stderrDone <-chan struct{}
+	exited     chan ProcessExit
+
+	closing   atomic.Bool
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// watchProcess 开始读取子进程的 stderr 并写入 stderr（可以为 nil），需在 transport 启动后调用
+// kill 取消启动子进程时使用的 context，子进程不响应 stdin 关闭时用于强制结束
+func watchProcess(stdio *transport.Stdio, stderr io.Writer, kill context.CancelFunc) *processWatcher {
+	w := &processWatcher{
+		transport: stdio,
+		kill:      kill,
+		tail:      newStderrTail(stderrTailLines, stderr),
+		exited:    make(chan ProcessExit, 1),
+	}
+	w.stderrDone = pipeStderr(stdio.Stderr(), w.tail)
+	go w.wait()
+	return w
+}
+
+func (w *processWatcher) wait() {
+	defer close(w.exited)
+	<-w.stderrDone
+	if w.closing.Load() {
+		return
+	}
+	err := w.close()
+	exit := ProcessExit{ExitCode: -1, Err: err, Stderr: w.tail.Lines()}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exit.ExitCode = exitErr.ExitCode()
+	} else if err == nil {
+		exit.ExitCode = 0
+	}
+	w.exited <- exit
+}
+
+// stop 主动关闭子进程，之后的退出不再通知
+func (w *processWatcher) stop() error {
+	w.closing.Store(true)
+	return w.close()
+}
+
+// close 关闭 transport 并回收子进程，只执行一次
+// 关闭 stdin 后子进程在 processKillGrace 内没有退出时强制结束，避免卡住的子进程阻塞关闭
+func (w *processWatcher) close() error {
+	w.closeOnce.Do(func() {
+		done := make(chan error, 1)
+		go func() {
+			done <- w.transport.Close()
+		}()
+		select {
+		case w.closeErr = <-done:
+		case <-time.After(processKillGrace):
+			w.kill()
+			w.closeErr = <-done
+		}
+		w.kill()
+	})
+	return w.closeErr
+}
+
+// stderrTail 转发 stderr 并保留最后几行，转发失败不影响继续读取
+type stderrTail struct {
+	mu      sync.Mutex
+	out     io.Writer
+	max     int
+	lines   []string
+	partial []byte
+}
+
+func newStderrTail(max int, out io.Writer) *stderrTail {
+	return &stderrTail{max: max, out: out}
+}
+
+func (t *stderrTail) Write(p []byte) (int, error) {
+	if t.out != nil {
+		_, _ = t.out.Write(p)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.partial = append(t.partial, p...)
+	for {
+		i := bytes.IndexByte(t.partial, '\n')
+		if i < 0 {
+			break
+		}
+		t.push(string(bytes.TrimRight(t.partial[:i], "\r")))
+		t.partial = t.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+func (t *stderrTail) push(line string) {
+	t.lines = append(t.lines, line)
+	if len(t.lines) > t.max {
+		t.lines = t.lines[len(t.lines)-t.max:]
+	}
+}
+
+// Lines 返回最后几行，包括没有换行结尾的最后一行
+func (t *stderrTail) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := append([]string(nil), t.lines...)
+	if len(t.partial) > 0 {
+		lines = append(lines, string(t.partial))
+		if len(lines) > t.max {
+			lines = lines[len(lines)-t.max:]
+		}
+	}
+	return lines
+}
@@ -0,0 +1,499 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+	client "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	server "github.com/mark3labs/mcp-go/server"
+)
+
+// methodNotificationMessage 上游服务发送日志消息的通知方法
+const methodNotificationMessage = "notifications/message"
+
+// resyncTimeout 重新同步单类列表的超时时间
+const resyncTimeout = 30 * time.Second
+
+// 需要同步的列表类型
+const (
+	listKindTools     = "tools"
+	listKindResources = "resources"
+	listKindPrompts   = "prompts"
+)
+
+// upstreamProxy 将上游 MCP 客户端的工具、资源、提示词、补全和日志能力转发到桥接层 MCP 服务器
+// 三种桥接器共用同一套实现，只是上游客户端和对外暴露的传输方式不同
+type upstreamProxy struct {
+	client    *client.Client
+	mcpServer *server.MCPServer
+	logger    xlog.Logger
+	source    string // 上游类型，用于日志: stdio / SSE
+	// 上游声明了 completions 能力时，桥接层在 initialize 响应中同样声明
+	completions bool
+
+	// 已桥接的列表快照，重新同步时与上游最新列表比较，只增删有变化的条目 - 由 syncMu 保护
+	syncMu    sync.Mutex
+	tools     map[string]mcp.Tool
+	resources map[string]mcp.Resource
+	templates map[string]mcp.ResourceTemplate
+	prompts   map[string]mcp.Prompt
+	// 首次同步成功的列表类型，定期重新同步时只处理这些类型
+	synced map[string]bool
+
+	// mcp-go 不支持删除资源模板，上游已删除的模板从列表结果中过滤，读取时返回错误
+	// templates 在 hook 中读取，修改时还需持有 templatesMu，避免列表请求等待整个同步过程
+	templatesMu sync.RWMutex
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newUpstreamProxy 根据上游初始化结果创建桥接层 MCP 服务器，并注册所有能力的转发
+// completions 为上游是否声明了 completions 能力，mcp-go 的初始化结果中不包含该能力，由 capabilityRecorder 记录
+// resyncInterval 大于 0 时定期重新获取上游列表，覆盖不发送 list_changed 通知的服务器
+func newUpstreamProxy(ctx context.Context, cli *client.Client, initResult *mcp.InitializeResult, completions bool, logger xlog.Logger, source string, resyncInterval time.Duration) *upstreamProxy {
+	p := &upstreamProxy{
+		client:      cli,
+		logger:      logger,
+		source:      source,
+		completions: completions,
+		tools:       make(map[string]mcp.Tool),
+		resources:   make(map[string]mcp.Resource),
+		templates:   make(map[string]mcp.ResourceTemplate),
+		prompts:     make(map[string]mcp.Prompt),
+		synced:      make(map[string]bool),
+		done:        make(chan struct{}),
+	}
+
+	hooks := &server.Hooks{}
+	serverOptions := []server.ServerOption{
+		server.WithToolCapabilities(true),
+		server.WithResourceCapabilities(true, true),
+		server.WithPromptCapabilities(true),
+		server.WithHooks(hooks),
+	}
+	hooks.AddAfterListResourceTemplates(p.filterRemovedTemplates)
+	// 上游支持日志时才暴露日志能力，设置日志级别时同步到上游
+	if initResult.Capabilities.Logging != nil {
+		serverOptions = append(serverOptions, server.WithLogging())
+		hooks.AddBeforeSetLevel(p.forwardSetLevel)
+	}
+
+	p.mcpServer = server.NewMCPServer(
+		initResult.ServerInfo.Name,
+		initResult.ServerInfo.Version,
+		serverOptions...,
+	)
+
+	// 设置工具桥接
+	if err := p.syncTools(ctx); err != nil {
+		p.logger.Warn("Failed to setup tool bridge", "error", err)
+	}
+
+	// 设置资源桥接（如果支持的话）
+	if err := p.syncResources(ctx); err != nil {
+		p.logger.Warnf("Resource bridging failed (server may not support resources): %v", err)
+	}
+
+	// 设置提示桥接（如果支持的话）
+	if err := p.syncPrompts(ctx); err != nil {
+		p.logger.Warnf("Prompt bridging failed (server may not support prompts): %v", err)
+	}
+
+	// 转发上游的日志消息和列表变更通知
+	cli.OnNotification(p.forwardNotification)
+
+	if resyncInterval > 0 {
+		go p.resyncLoop(resyncInterval)
+	}
+
+	return p
+}
+
+// Close 停止定期重新同步
+func (p *upstreamProxy) Close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+	})
+}
+
+// resyncLoop 定期重新同步首次同步成功的列表
+func (p *upstreamProxy) resyncLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.syncMu.Lock()
+			kinds := make([]string, 0, len(p.synced))
+			for kind := range p.synced {
+				kinds = append(kinds, kind)
+			}
+			p.syncMu.Unlock()
+			for _, kind := range kinds {
+				p.resync(kind)
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// resync 重新同步指定类型的列表，失败时只记录日志
+func (p *upstreamProxy) resync(kind string) {
+	ctx, cancel := context.WithTimeout(context.Background(), resyncTimeout)
+	defer cancel()
+
+	var err error
+	switch kind {
+	case listKindTools:
+		err = p.syncTools(ctx)
+	case listKindResources:
+		err = p.syncResources(ctx)
+	case listKindPrompts:
+		err = p.syncPrompts(ctx)
+	}
+	if err != nil {
+		p.logger.Warn("Failed to resync list from upstream server", "kind", kind, "error", err)
+	}
+}
+
+// syncTools 同步工具列表: 新增或定义变化的工具重新注册，上游已删除的工具从桥接层移除
+func (p *upstreamProxy) syncTools(ctx context.Context) error {
+	p.syncMu.Lock()
+	defer p.syncMu.Unlock()
+
+	// 获取上游服务器的工具列表
+	tools, err := listPages(ctx, func(ctx context.Context, cursor mcp.Cursor) ([]mcp.Tool, mcp.Cursor, error) {
+		request := mcp.ListToolsRequest{}
+		request.Params.Cursor = cursor
+		result, err := p.client.ListToolsByPage(ctx, request)
+		if err != nil {
+			return nil, "", err
+		}
+		return result.Tools, result.NextCursor, nil
+	})
+	if err != nil {
+		p.logger.Error("Failed to list tools from upstream server", "source", p.source, "error", err)
+		return fmt.Errorf("failed to list tools from %s server: %w", p.source, err)
+	}
+	p.synced[listKindTools] = true
+
+	latest := make(map[string]mcp.Tool, len(tools))
+	var added []server.ServerTool
+	for _, tool := range tools {
+		latest[tool.Name] = tool
+		if old, ok := p.tools[tool.Name]; ok && reflect.DeepEqual(old, tool) {
+			continue
+		}
+		p.logger.Debug("Bridging tool", "tool_name", tool.Name)
+		added = append(added, server.ServerTool{Tool: tool, Handler: p.toolHandler(tool.Name)})
+	}
+	removed := staleKeys(p.tools, latest)
+
+	if len(removed) > 0 {
+		p.mcpServer.DeleteTools(removed...)
+	}
+	if len(added) > 0 {
+		p.mcpServer.AddTools(added...)
+	}
+	p.tools = latest
+
+	p.logger.Info("Bridging tools from upstream server", "source", p.source, "tool_count", len(latest), "added", len(added), "removed", len(removed))
+	return nil
+}
+
+// toolHandler 创建工具处理器，将调用转发到上游客户端
+func (p *upstreamProxy) toolHandler(toolName string) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		p.logger.Debug("Calling tool", "tool_name", toolName)
+
+		result, err := p.client.CallTool(ctx, request)
+		if err != nil {
+			p.logger.Error("Tool call failed", "tool_name", toolName, "error", err)
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to call tool %s: %v", toolName, err)), nil
+		}
+
+		p.logger.Debug("Tool call succeeded", "tool_name", toolName, result)
+		return result, nil
+	}
+}
+
+// syncResources 同步资源和资源模板列表
+func (p *upstreamProxy) syncResources(ctx context.Context) error {
+	p.syncMu.Lock()
+	defer p.syncMu.Unlock()
+
+	// 获取上游服务器的资源列表
+	resources, err := listPages(ctx, func(ctx context.Context, cursor mcp.Cursor) ([]mcp.Resource, mcp.Cursor, error) {
+		request := mcp.ListResourcesRequest{}
+		request.Params.Cursor = cursor
+		result, err := p.client.ListResourcesByPage(ctx, request)
+		if err != nil {
+			return nil, "", err
+		}
+		return result.Resources, result.NextCursor, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list resources from %s server: %w", p.source, err)
+	}
+	p.synced[listKindResources] = true
+
+	latest := make(map[string]mcp.Resource, len(resources))
+	var added []server.ServerResource
+	for _, resource := range resources {
+		latest[resource.URI] = resource
+		if old, ok := p.resources[resource.URI]; ok && reflect.DeepEqual(old, resource) {
+			continue
+		}
+		p.logger.Debug("Bridging resource", "resource_uri", resource.URI)
+		added = append(added, server.ServerResource{Resource: resource, Handler: p.resourceHandler(resource.URI)})
+	}
+	removed := staleKeys(p.resources, latest)
+
+	for _, uri := range removed {
+		p.mcpServer.RemoveResource(uri)
+	}
+	if len(added) > 0 {
+		p.mcpServer.AddResources(added...)
+	}
+	p.resources = latest
+
+	p.logger.Info("Bridging resources from upstream server", "source", p.source, "resource_count", len(latest), "added", len(added), "removed", len(removed))
+
+	// 获取资源模板
+	templates, err := listPages(ctx, func(ctx context.Context, cursor mcp.Cursor) ([]mcp.ResourceTemplate, mcp.Cursor, error) {
+		request := mcp.ListResourceTemplatesRequest{}
+		request.Params.Cursor = cursor
+		result, err := p.client.ListResourceTemplatesByPage(ctx, request)
+		if err != nil {
+			return nil, "", err
+		}
+		return result.ResourceTemplates, result.NextCursor, nil
+	})
+	if err != nil {
+		p.logger.Error("Failed to list resource templates from upstream server", "source", p.source, "error", err)
+		return fmt.Errorf("failed to list resource templates from %s server: %w", p.source, err)
+	}
+
+	latestTemplates := make(map[string]mcp.ResourceTemplate, len(templates))
+	var addedTemplates []mcp.ResourceTemplate
+	for _, template := range templates {
+		templateURI := template.URITemplate.Raw()
+		latestTemplates[templateURI] = template
+		if old, ok := p.templates[templateURI]; ok && sameTemplate(old, template) {
+			continue
+		}
+		p.logger.Debug("Bridging resource template", "template_uri", templateURI)
+		addedTemplates = append(addedTemplates, template)
+	}
+	removedTemplates := staleKeys(p.templates, latestTemplates)
+
+	p.templatesMu.Lock()
+	p.templates = latestTemplates
+	p.templatesMu.Unlock()
+	for _, template := range addedTemplates {
+		templateURI := template.URITemplate.Raw()
+		p.mcpServer.AddResourceTemplate(template, server.ResourceTemplateHandlerFunc(p.templateHandler(templateURI)))
+	}
+	if len(removedTemplates) > 0 {
+		p.mcpServer.SendNotificationToAllClients(mcp.MethodNotificationResourcesListChanged, nil)
+	}
+
+	p.logger.Info("Bridging resource templates from upstream server", "source", p.source, "template_count", len(latestTemplates), "added", len(addedTemplates), "removed", len(removedTemplates))
+	return nil
+}
+
+// templateHandler 创建资源模板处理器，模板已被上游删除时直接返回错误
+func (p *upstreamProxy) templateHandler(templateURI string) server.ResourceHandlerFunc {
+	handler := p.resourceHandler(templateURI)
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		p.templatesMu.RLock()
+		_, ok := p.templates[templateURI]
+		p.templatesMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("resource template %s was removed by %s server", templateURI, p.source)
+		}
+		return handler(ctx, request)
+	}
+}
+
+// filterRemovedTemplates 从资源模板列表结果中去掉上游已删除的模板
+func (p *upstreamProxy) filterRemovedTemplates(ctx context.Context, id any, request *mcp.ListResourceTemplatesRequest, result *mcp.ListResourceTemplatesResult) {
+	p.templatesMu.RLock()
+	defer p.templatesMu.RUnlock()
+	templates := result.ResourceTemplates[:0]
+	for _, template := range result.ResourceTemplates {
+		if _, ok := p.templates[template.URITemplate.Raw()]; ok {
+			templates = append(templates, template)
+		}
+	}
+	result.ResourceTemplates = templates
+}
+
+// resourceHandler 创建资源处理器，将读取请求转发到上游客户端，资源和资源模板共用
+func (p *upstreamProxy) resourceHandler(resourceURI string) server.ResourceHandlerFunc {
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		p.logger.Debug("Reading resource", "resource_uri", resourceURI)
+
+		result, err := p.client.ReadResource(ctx, request)
+		if err != nil {
+			p.logger.Error("Resource read failed", "resource_uri", resourceURI, "error", err)
+			return nil, fmt.Errorf("failed to read resource %s: %w", resourceURI, err)
+		}
+
+		p.logger.Debug("Resource read succeeded", "resource_uri", resourceURI, result)
+		return result.Contents, nil
+	}
+}
+
+// syncPrompts 同步提示词列表
+func (p *upstreamProxy) syncPrompts(ctx context.Context) error {
+	p.syncMu.Lock()
+	defer p.syncMu.Unlock()
+
+	// 获取上游服务器的提示列表
+	prompts, err := listPages(ctx, func(ctx context.Context, cursor mcp.Cursor) ([]mcp.Prompt, mcp.Cursor, error) {
+		request := mcp.ListPromptsRequest{}
+		request.Params.Cursor = cursor
+		result, err := p.client.ListPromptsByPage(ctx, request)
+		if err != nil {
+			return nil, "", err
+		}
+		return result.Prompts, result.NextCursor, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list prompts from %s server: %w", p.source, err)
+	}
+	p.synced[listKindPrompts] = true
+
+	latest := make(map[string]mcp.Prompt, len(prompts))
+	var added []server.ServerPrompt
+	for _, prompt := range prompts {
+		latest[prompt.Name] = prompt
+		if old, ok := p.prompts[prompt.Name]; ok && reflect.DeepEqual(old, prompt) {
+			continue
+		}
+		p.logger.Debug("Bridging prompt", "prompt_name", prompt.Name)
+		added = append(added, server.ServerPrompt{Prompt: prompt, Handler: p.promptHandler(prompt.Name)})
+	}
+	removed := staleKeys(p.prompts, latest)
+
+	if len(removed) > 0 {
+		p.mcpServer.DeletePrompts(removed...)
+	}
+	if len(added) > 0 {
+		p.mcpServer.AddPrompts(added...)
+	}
+	p.prompts = latest
+
+	p.logger.Info("Bridging prompts from upstream server", "source", p.source, "prompt_count", len(latest), "added", len(added), "removed", len(removed))
+	return nil
+}
+
+// promptHandler 创建提示处理器，将请求转发到上游客户端
+func (p *upstreamProxy) promptHandler(promptName string) server.PromptHandlerFunc {
+	return func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		p.logger.Debug("Getting prompt", "prompt_name", promptName)
+
+		result, err := p.client.GetPrompt(ctx, request)
+		if err != nil {
+			p.logger.Error("Prompt get failed", "prompt_name", promptName, "error", err)
+			return nil, fmt.Errorf("failed to get prompt %s: %w", promptName, err)
+		}
+
+		p.logger.Debug("Prompt get succeeded", "prompt_name", promptName, result)
+		return result, nil
+	}
+}
+
+// listPages 按 NextCursor 依次获取上游列表的所有分页
+// 上游返回已经出现过的游标时报错，避免无限循环
+func listPages[T any](ctx context.Context, fetch func(ctx context.Context, cursor mcp.Cursor) ([]T, mcp.Cursor, error)) ([]T, error) {
+	var items []T
+	seen := make(map[mcp.Cursor]bool)
+	var cursor mcp.Cursor
+	for {
+		page, next, err := fetch(ctx, cursor)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page...)
+		if next == "" {
+			return items, nil
+		}
+		if seen[next] {
+			return nil, fmt.Errorf("upstream returned repeated cursor %q", next)
+		}
+		seen[next] = true
+		cursor = next
+	}
+}
+
+// sameTemplate 比较资源模板的 JSON 表示，解析后的 URITemplate 内部状态不同，不能用 reflect.DeepEqual 比较
+func sameTemplate(a, b mcp.ResourceTemplate) bool {
+	aData, aErr := json.Marshal(a)
+	bData, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && bytes.Equal(aData, bData)
+}
+
+// staleKeys 返回旧快照中存在、最新列表中已不存在的key
+func staleKeys[V any](old, latest map[string]V) []string {
+	var keys []string
+	for key := range old {
+		if _, ok := latest[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// forwardSetLevel 将客户端设置的日志级别同步到上游服务器
+func (p *upstreamProxy) forwardSetLevel(ctx context.Context, id any, request *mcp.SetLevelRequest) {
+	if err := p.client.SetLevel(ctx, *request); err != nil {
+		p.logger.Warn("Failed to forward log level to upstream server", "level", request.Params.Level, "error", err)
+	}
+}
+
+// forwardNotification 将上游服务器的日志消息转发给所有客户端，列表变更时重新同步
+// 重新同步会向上游发送请求，不能阻塞客户端的通知处理
+// 桥接层增删条目时 mcp-go 会向已连接的客户端发送对应的 list_changed 通知
+func (p *upstreamProxy) forwardNotification(notification mcp.JSONRPCNotification) {
+	switch notification.Method {
+	case methodNotificationMessage:
+		p.mcpServer.SendNotificationToAllClients(notification.Method, notification.Params.AdditionalFields)
+	case mcp.MethodNotificationToolsListChanged:
+		go p.resync(listKindTools)
+	case mcp.MethodNotificationResourcesListChanged:
+		go p.resync(listKindResources)
+	case mcp.MethodNotificationPromptsListChanged:
+		go p.resync(listKindPrompts)
+	}
+}
+
+// complete 将 completion/complete 请求转发到上游服务器，返回完整的 JSON-RPC 响应
+func (p *upstreamProxy) complete(ctx context.Context, id mcp.RequestId, request mcp.CompleteRequest) mcp.JSONRPCMessage {
+	result, err := p.client.Complete(ctx, request)
+	if err != nil {
+		p.logger.Error("Completion failed", "error", err)
+		response := mcp.JSONRPCError{
+			JSONRPC: mcp.JSONRPC_VERSION,
+			ID:      id,
+		}
+		response.Error.Code = mcp.INTERNAL_ERROR
+		response.Error.Message = err.Error()
+		return response
+	}
+	return mcp.JSONRPCResponse{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		ID:      id,
+		Result:  result,
+	}
+}
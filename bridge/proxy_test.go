@@ -0,0 +1,598 @@
+package bridge
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+	client "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	server "github.com/mark3labs/mcp-go/server"
+)
+
+// testStdioServerEnv 设置后测试进程作为 stdio MCP 服务器运行，避免依赖 npx
+const testStdioServerEnv = "BRIDGE_TEST_STDIO_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testStdioServerEnv) == "1" {
+		runTestStdioServer()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runTestStdioServer 运行支持工具、提示词、补全和日志的 stdio MCP 服务器
+// mcp-go 服务端不处理 completion/complete，这里直接应答并在 initialize 结果中声明 completions 能力；设置日志级别后发送一条日志通知
+// 调用 grow 工具新增 extra 工具（notify=true 时发送 list_changed 通知），调用 shrink 工具静默删除 extra 工具
+// 启动时向 stderr 输出一行日志，调用 crash 工具时输出一行日志后以退出码 3 退出
+func runTestStdioServer() {
+	fmt.Fprintln(os.Stderr, "fake-stdio server starting")
+	mcpServer := server.NewMCPServer("fake-stdio", "1.0.0",
+		server.WithToolCapabilities(true),
+		server.WithPromptCapabilities(true),
+		server.WithLogging(),
+	)
+	mcpServer.AddPrompt(mcp.NewPrompt("greet", mcp.WithArgument("name")), func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		return mcp.NewGetPromptResult("greet", []mcp.PromptMessage{
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent("hello "+request.Params.Arguments["name"])),
+		}), nil
+	})
+	notifyToolsChanged := false
+	mcpServer.AddTool(mcp.NewTool("grow", mcp.WithBoolean("notify")), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		mcpServer.AddTool(mcp.NewTool("extra"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultText("extra"), nil
+		})
+		notifyToolsChanged = request.GetBool("notify", false)
+		return mcp.NewToolResultText("grown"), nil
+	})
+	mcpServer.AddTool(mcp.NewTool("crash"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		fmt.Fprintln(os.Stderr, "fatal: boom")
+		os.Exit(3)
+		return nil, nil
+	})
+	mcpServer.AddTool(mcp.NewTool("shrink"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		mcpServer.DeleteTools("extra")
+		return mcp.NewToolResultText("shrunk"), nil
+	})
+
+	encoder := json.NewEncoder(os.Stdout)
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var message struct {
+			ID     mcp.RequestId `json:"id"`
+			Method string        `json:"method"`
+			Params struct {
+				Level mcp.LoggingLevel `json:"level"`
+			} `json:"params"`
+		}
+		if err := json.Unmarshal(line, &message); err != nil {
+			continue
+		}
+
+		switch message.Method {
+		case methodCompletionComplete:
+			result := mcp.CompleteResult{}
+			result.Completion.Values = []string{"alice", "bob"}
+			_ = encoder.Encode(mcp.JSONRPCResponse{JSONRPC: mcp.JSONRPC_VERSION, ID: message.ID, Result: result})
+			continue
+		case string(mcp.MethodSetLogLevel):
+			_ = encoder.Encode(mcp.NewLoggingMessageNotification(message.Params.Level, "fake", "level set to "+string(message.Params.Level)))
+		}
+
+		if response := mcpServer.HandleMessage(context.Background(), line); response != nil {
+			if message.Method == string(mcp.MethodInitialize) {
+				data, _ := json.Marshal(response)
+				patched, _ := advertiseCompletions(data)
+				_ = encoder.Encode(json.RawMessage(patched))
+				continue
+			}
+			_ = encoder.Encode(response)
+		}
+		if notifyToolsChanged {
+			notifyToolsChanged = false
+			_ = encoder.Encode(mcp.JSONRPCNotification{
+				JSONRPC:      mcp.JSONRPC_VERSION,
+				Notification: mcp.Notification{Method: mcp.MethodNotificationToolsListChanged},
+			})
+		}
+	}
+}
+
+// freeAddr 获取一个空闲的本地监听地址
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find free port: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func newTestStdioTransport() *transport.Stdio {
+	return transport.NewStdio(os.Args[0], []string{testStdioServerEnv + "=1"}, "-test.run=^$")
+}
+
+func initializeTestClient(ctx context.Context, t *testing.T, c *client.Client) *mcp.InitializeResult {
+	if err := c.Start(ctx); err != nil {
+		t.Fatalf("Failed to start client: %v", err)
+	}
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{Name: "bridge-test", Version: "1.0.0"}
+	result, err := c.Initialize(ctx, initRequest)
+	if err != nil {
+		t.Fatalf("Failed to initialize client: %v", err)
+	}
+	return result
+}
+
+func promptCompleteRequest() mcp.CompleteRequest {
+	request := mcp.CompleteRequest{}
+	request.Params.Ref = mcp.PromptReference{Type: "ref/prompt", Name: "greet"}
+	request.Params.Argument.Name = "name"
+	request.Params.Argument.Value = "a"
+	return request
+}
+
+// startTestStdioBridge 启动连接测试 stdio 服务器的 SSE 桥接器，返回监听地址
+func startTestStdioBridge(ctx context.Context, t *testing.T, opts ...BridgeOption) (*StdioToSSEBridge, string) {
+	bridge, err := NewStdioToSSEBridge(ctx, newTestStdioTransport(), "fake", opts...)
+	if err != nil {
+		t.Fatalf("Failed to create bridge: %v", err)
+	}
+	addr := freeAddr(t)
+	go bridge.Start(addr)
+	t.Cleanup(func() { bridge.Close() })
+	time.Sleep(300 * time.Millisecond)
+	return bridge, addr
+}
+
+func newTestSSEClient(t *testing.T, addr string) *client.Client {
+	sseClient, err := client.NewSSEMCPClient(fmt.Sprintf("http://%s/fake/sse", addr))
+	if err != nil {
+		t.Fatalf("Failed to create SSE client: %v", err)
+	}
+	return sseClient
+}
+
+func callTestTool(ctx context.Context, t *testing.T, c *client.Client, name string, args map[string]any) {
+	request := mcp.CallToolRequest{}
+	request.Params.Name = name
+	request.Params.Arguments = args
+	if _, err := c.CallTool(ctx, request); err != nil {
+		t.Fatalf("Failed to call tool %s: %v", name, err)
+	}
+}
+
+// waitForTool 轮询桥接层工具列表，直到指定工具的存在状态符合预期
+func waitForTool(ctx context.Context, t *testing.T, c *client.Client, name string, want bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		tools, err := c.ListTools(ctx, mcp.ListToolsRequest{})
+		if err != nil {
+			t.Fatalf("Failed to list tools: %v", err)
+		}
+		found := false
+		for _, tool := range tools.Tools {
+			found = found || tool.Name == name
+		}
+		if found == want {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for tool %s present=%v", name, want)
+}
+
+func TestStdioToSSEBridge_PromptsCompletionAndLogging(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	bridge, addr := startTestStdioBridge(ctx, t, WithStreamableHTTP())
+	// 通过 capabilityRecorder 检查桥接层是否声明了 completions 能力
+	sseTransport, err := transport.NewSSE(fmt.Sprintf("http://%s/fake/sse", addr))
+	if err != nil {
+		t.Fatalf("Failed to create SSE transport: %v", err)
+	}
+	sseRecorder := &capabilityRecorder{Interface: sseTransport}
+	sseClient := client.NewClient(sseRecorder)
+	defer sseClient.Close()
+	logMessages := make(chan mcp.JSONRPCNotification, 1)
+	sseClient.OnNotification(func(notification mcp.JSONRPCNotification) {
+		if notification.Method == methodNotificationMessage {
+			logMessages <- notification
+		}
+	})
+
+	initResult := initializeTestClient(ctx, t, sseClient)
+	if initResult.Capabilities.Logging == nil {
+		t.Errorf("Expected logging capability to be bridged")
+	}
+	if !sseRecorder.completions.Load() {
+		t.Errorf("Expected completions capability to be bridged over SSE")
+	}
+
+	// 提示词
+	prompts, err := sseClient.ListPrompts(ctx, mcp.ListPromptsRequest{})
+	if err != nil {
+		t.Fatalf("Failed to list prompts: %v", err)
+	}
+	if len(prompts.Prompts) != 1 || prompts.Prompts[0].Name != "greet" {
+		t.Fatalf("Expected bridged prompt greet, got %+v", prompts.Prompts)
+	}
+	promptRequest := mcp.GetPromptRequest{}
+	promptRequest.Params.Name = "greet"
+	promptRequest.Params.Arguments = map[string]string{"name": "alice"}
+	prompt, err := sseClient.GetPrompt(ctx, promptRequest)
+	if err != nil {
+		t.Fatalf("Failed to get prompt: %v", err)
+	}
+	if len(prompt.Messages) != 1 {
+		t.Fatalf("Expected 1 prompt message, got %d", len(prompt.Messages))
+	}
+
+	// 补全（SSE）
+	completion, err := sseClient.Complete(ctx, promptCompleteRequest())
+	if err != nil {
+		t.Fatalf("Failed to complete over SSE: %v", err)
+	}
+	if len(completion.Completion.Values) != 2 || completion.Completion.Values[0] != "alice" {
+		t.Errorf("Unexpected completion values over SSE: %v", completion.Completion.Values)
+	}
+
+	// 日志级别转发到上游，上游的日志通知转发回客户端
+	setLevel := mcp.SetLevelRequest{}
+	setLevel.Params.Level = mcp.LoggingLevelDebug
+	if err := sseClient.SetLevel(ctx, setLevel); err != nil {
+		t.Fatalf("Failed to set level: %v", err)
+	}
+	select {
+	case notification := <-logMessages:
+		if notification.Params.AdditionalFields["data"] != "level set to debug" {
+			t.Errorf("Unexpected log notification: %+v", notification.Params.AdditionalFields)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected upstream log notification to be forwarded")
+	}
+
+	// 补全（Streamable HTTP）
+	streamTransport, err := transport.NewStreamableHTTP(fmt.Sprintf("http://%s%s", addr, bridge.StreamableHTTPEndpoint()))
+	if err != nil {
+		t.Fatalf("Failed to create streamable http transport: %v", err)
+	}
+	streamRecorder := &capabilityRecorder{Interface: streamTransport}
+	streamClient := client.NewClient(streamRecorder)
+	defer streamClient.Close()
+	initializeTestClient(ctx, t, streamClient)
+	if !streamRecorder.completions.Load() {
+		t.Errorf("Expected completions capability to be bridged over streamable http")
+	}
+	completion, err = streamClient.Complete(ctx, promptCompleteRequest())
+	if err != nil {
+		t.Fatalf("Failed to complete over streamable http: %v", err)
+	}
+	if len(completion.Completion.Values) != 2 {
+		t.Errorf("Unexpected completion values over streamable http: %v", completion.Completion.Values)
+	}
+}
+
+func TestStdioToSSEBridge_ResyncOnListChanged(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	// 关闭定期同步，只依赖上游的 list_changed 通知
+	_, addr := startTestStdioBridge(ctx, t, WithResyncInterval(0))
+	sseClient := newTestSSEClient(t, addr)
+	defer sseClient.Close()
+	toolsChanged := make(chan struct{}, 10)
+	sseClient.OnNotification(func(notification mcp.JSONRPCNotification) {
+		if notification.Method == mcp.MethodNotificationToolsListChanged {
+			toolsChanged <- struct{}{}
+		}
+	})
+	initializeTestClient(ctx, t, sseClient)
+
+	callTestTool(ctx, t, sseClient, "grow", map[string]any{"notify": true})
+	select {
+	case <-toolsChanged:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected tools/list_changed to be propagated to clients")
+	}
+	waitForTool(ctx, t, sseClient, "extra", true)
+}
+
+func TestUpstreamProxy_PaginationAndTemplateRemoval(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	// 上游每页只返回一条，可以通过 hook 从列表中去掉 removed 模板
+	var removed atomic.Bool
+	hooks := &server.Hooks{}
+	hooks.AddAfterListResourceTemplates(func(ctx context.Context, id any, request *mcp.ListResourceTemplatesRequest, result *mcp.ListResourceTemplatesResult) {
+		if !removed.Load() {
+			return
+		}
+		templates := result.ResourceTemplates[:0]
+		for _, template := range result.ResourceTemplates {
+			if template.Name != "removed" {
+				templates = append(templates, template)
+			}
+		}
+		result.ResourceTemplates = templates
+	})
+	upstream := server.NewMCPServer("paged", "1.0.0", server.WithPaginationLimit(1), server.WithHooks(hooks))
+	for _, name := range []string{"a", "b", "c"} {
+		upstream.AddTool(mcp.NewTool(name), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultText("ok"), nil
+		})
+		upstream.AddPrompt(mcp.NewPrompt(name), func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			return mcp.NewGetPromptResult(name, nil), nil
+		})
+		upstream.AddResource(mcp.NewResource("test://"+name, name), func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			return nil, nil
+		})
+	}
+	for _, name := range []string{"kept", "removed"} {
+		upstream.AddResourceTemplate(mcp.NewResourceTemplate("test://"+name+"/{id}", name), func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			return []mcp.ResourceContents{mcp.TextResourceContents{URI: request.Params.URI, Text: "ok"}}, nil
+		})
+	}
+
+	upstreamClient, err := client.NewInProcessClient(upstream)
+	if err != nil {
+		t.Fatalf("Failed to create in-process client: %v", err)
+	}
+	defer upstreamClient.Close()
+	initResult := initializeTestClient(ctx, t, upstreamClient)
+	proxy := newUpstreamProxy(ctx, upstreamClient, initResult, false, xlog.NewLogger("test"), "in-process", 0)
+	defer proxy.Close()
+
+	bridged, err := client.NewInProcessClient(proxy.mcpServer)
+	if err != nil {
+		t.Fatalf("Failed to create in-process client: %v", err)
+	}
+	defer bridged.Close()
+	initializeTestClient(ctx, t, bridged)
+
+	// 所有分页都被桥接
+	tools, err := bridged.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil || len(tools.Tools) != 3 {
+		t.Fatalf("Expected 3 bridged tools, got %v (%v)", tools, err)
+	}
+	prompts, err := bridged.ListPrompts(ctx, mcp.ListPromptsRequest{})
+	if err != nil || len(prompts.Prompts) != 3 {
+		t.Fatalf("Expected 3 bridged prompts, got %v (%v)", prompts, err)
+	}
+	resources, err := bridged.ListResources(ctx, mcp.ListResourcesRequest{})
+	if err != nil || len(resources.Resources) != 3 {
+		t.Fatalf("Expected 3 bridged resources, got %v (%v)", resources, err)
+	}
+	templates, err := bridged.ListResourceTemplates(ctx, mcp.ListResourceTemplatesRequest{})
+	if err != nil || len(templates.ResourceTemplates) != 2 {
+		t.Fatalf("Expected 2 bridged resource templates, got %v (%v)", templates, err)
+	}
+
+	// 上游删除的资源模板在重新同步后不再列出，也不能读取
+	removed.Store(true)
+	proxy.resync(listKindResources)
+	templates, err = bridged.ListResourceTemplates(ctx, mcp.ListResourceTemplatesRequest{})
+	if err != nil || len(templates.ResourceTemplates) != 1 || templates.ResourceTemplates[0].Name != "kept" {
+		t.Fatalf("Expected only the kept resource template, got %v (%v)", templates, err)
+	}
+	readRequest := mcp.ReadResourceRequest{}
+	readRequest.Params.URI = "test://removed/1"
+	if _, err := bridged.ReadResource(ctx, readRequest); err == nil {
+		t.Error("Expected reading a removed resource template to fail")
+	}
+	readRequest.Params.URI = "test://kept/1"
+	if _, err := bridged.ReadResource(ctx, readRequest); err != nil {
+		t.Errorf("Failed to read kept resource template: %v", err)
+	}
+}
+
+func TestStdioToSSEBridge_PeriodicResync(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	_, addr := startTestStdioBridge(ctx, t, WithResyncInterval(100*time.Millisecond))
+	sseClient := newTestSSEClient(t, addr)
+	defer sseClient.Close()
+	initializeTestClient(ctx, t, sseClient)
+
+	// 上游不发送通知时，定期同步发现新增和删除的工具
+	callTestTool(ctx, t, sseClient, "grow", map[string]any{"notify": false})
+	waitForTool(ctx, t, sseClient, "extra", true)
+	callTestTool(ctx, t, sseClient, "shrink", nil)
+	waitForTool(ctx, t, sseClient, "extra", false)
+}
+
+// syncBuffer 并发安全的 io.Writer，用于收集子进程 stderr
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestStdioToSSEBridge_Stderr(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	stderr := &syncBuffer{}
+	startTestStdioBridge(ctx, t, WithStderr(stderr))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(stderr.String(), "fake-stdio server starting") {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected stderr to be captured, got %q", stderr.String())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestStdioToSSEBridge_ServeUnixSocket(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	bridge, err := NewStdioToSSEBridge(ctx, newTestStdioTransport(), "fake")
+	if err != nil {
+		t.Fatalf("Failed to create bridge: %v", err)
+	}
+	socketPath := filepath.Join(t.TempDir(), "fake.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Skipf("unix socket not supported: %v", err)
+	}
+	go bridge.Serve(listener)
+
+	// 请求地址中的主机名不参与连接，所有请求都通过 socket 发送
+	httpClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		},
+	}}
+	sseClient, err := client.NewSSEMCPClient("http://unix/fake/sse", transport.WithHTTPClient(httpClient))
+	if err != nil {
+		t.Fatalf("Failed to create SSE client: %v", err)
+	}
+	defer sseClient.Close()
+	initializeTestClient(ctx, t, sseClient)
+	waitForTool(ctx, t, sseClient, "grow", true)
+
+	if err := bridge.Close(); err != nil {
+		t.Fatalf("Failed to close bridge: %v", err)
+	}
+	if _, err := os.Stat(socketPath); !os.IsNotExist(err) {
+		t.Errorf("Expected socket file to be removed on close, got %v", err)
+	}
+}
+
+func TestStdioToHTTPStreamBridge_EndpointPath(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	// 只暴露 Streamable HTTP 时与 both 模式使用相同的路径
+	bridge, err := NewStdioToHTTPStreamBridge(ctx, newTestStdioTransport(), "fake")
+	if err != nil {
+		t.Fatalf("Failed to create bridge: %v", err)
+	}
+	defer bridge.Close()
+	if endpoint := bridge.StreamableHTTPEndpoint(); endpoint != "/fake/mcp" {
+		t.Fatalf("Expected endpoint /fake/mcp, got %s", endpoint)
+	}
+	addr := freeAddr(t)
+	go bridge.Start(addr)
+	time.Sleep(300 * time.Millisecond)
+
+	streamClient, err := client.NewStreamableHttpClient(fmt.Sprintf("http://%s/fake/mcp", addr))
+	if err != nil {
+		t.Fatalf("Failed to create streamable http client: %v", err)
+	}
+	defer streamClient.Close()
+	initializeTestClient(ctx, t, streamClient)
+	waitForTool(ctx, t, streamClient, "grow", true)
+}
+
+func TestStdioToSSEBridge_Exited(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	bridge, addr := startTestStdioBridge(ctx, t)
+	sseClient := newTestSSEClient(t, addr)
+	defer sseClient.Close()
+	initializeTestClient(ctx, t, sseClient)
+
+	// 子进程退出时不会返回响应，不等待调用结果
+	go func() {
+		request := mcp.CallToolRequest{}
+		request.Params.Name = "crash"
+		callCtx, callCancel := context.WithTimeout(ctx, time.Second)
+		defer callCancel()
+		_, _ = sseClient.CallTool(callCtx, request)
+	}()
+
+	select {
+	case exit, ok := <-bridge.Exited():
+		if !ok {
+			t.Fatal("Expected exit info for unexpected exit")
+		}
+		if exit.ExitCode != 3 {
+			t.Errorf("Expected exit code 3, got %d (%v)", exit.ExitCode, exit.Err)
+		}
+		if len(exit.Stderr) == 0 || exit.Stderr[len(exit.Stderr)-1] != "fatal: boom" {
+			t.Errorf("Expected last stderr line to be kept, got %v", exit.Stderr)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for process exit")
+	}
+}
+
+func TestStdioToSSEBridge_ExitedOnClose(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	bridge, _ := startTestStdioBridge(ctx, t)
+	// 启动时的 ctx 结束后子进程继续运行
+	cancel()
+	if err := bridge.Ping(context.Background()); err != nil {
+		t.Fatalf("Expected stdio process to outlive the startup context: %v", err)
+	}
+
+	bridge.Close()
+	select {
+	case exit, ok := <-bridge.Exited():
+		if ok {
+			t.Errorf("Expected no exit info when closing the bridge, got %+v", exit)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for exit channel to close")
+	}
+}
+
+func TestStdioToSSEBridge_KillHungProcess(t *testing.T) {
+	grace := processKillGrace
+	processKillGrace = 100 * time.Millisecond
+	defer func() { processKillGrace = grace }()
+
+	// sleep 不读取 stdin 也不响应 initialize，初始化超时后关闭 stdin 也不会退出
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := NewStdioToSSEBridge(ctx, transport.NewStdio("sleep", nil, "60"), "hung")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Expected initialize to fail")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for the hung process to be killed")
+	}
+}
@@ -3,6 +3,8 @@ package bridge
 import (
 	"context"
 	"fmt"
+	"net"
+	"net/http"
 
 	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
 	client "github.com/mark3labs/mcp-go/client"
@@ -14,10 +16,13 @@ import (
 // SSEToHTTPStreamBridge 创建一个将 SSE MCP 服务器桥接到 HTTP Stream 的转换器
 type SSEToHTTPStreamBridge struct {
 	sseClient *client.Client
-	mcpServer *server.MCPServer
+	proxy     *upstreamProxy
 	*server.StreamableHTTPServer
 	mcpName string
 	logger  xlog.Logger
+
+	// httpServer 承载桥接器的 HTTP 服务，可以监听 TCP 端口或 unix socket
+	httpServer *http.Server
 }
 
 func NewSSEToHTTPStreamBridge(ctx context.Context, sseBaseURL string, mcpName string, options ...transport.ClientOption) (*SSEToHTTPStreamBridge, error) {
@@ -31,7 +36,9 @@ func NewSSEToHTTPStreamBridge(ctx context.Context, sseBaseURL string, mcpName st
 		return nil, fmt.Errorf("failed to create SSE transport: %w", err)
 	}
 
-	sseClient := client.NewClient(sseTransport)
+	// 记录上游 initialize 结果中声明的 completions 能力
+	recorder := &capabilityRecorder{Interface: sseTransport}
+	sseClient := client.NewClient(recorder)
 
 	logger.Info("Starting SSE client", "mcp_name", mcpName, "base_url", sseBaseURL)
 	if err := sseClient.Start(ctx); err != nil {
@@ -58,216 +65,62 @@ func NewSSEToHTTPStreamBridge(ctx context.Context, sseBaseURL string, mcpName st
 		"server_version", initResult.ServerInfo.Version,
 	)
 
-	// 2. 创建 MCP 服务器，作为桥接层
-	mcpServer := server.NewMCPServer(
-		initResult.ServerInfo.Name,
-		initResult.ServerInfo.Version,
-		server.WithToolCapabilities(true),
-		server.WithResourceCapabilities(true, true),
-		server.WithPromptCapabilities(true),
-	)
+	// 2. 创建 MCP 服务器作为桥接层，转发工具、资源、提示词、补全和日志
+	proxy := newUpstreamProxy(ctx, sseClient, initResult, recorder.completions.Load(), logger, "SSE", defaultResyncInterval)
 
 	bridge := &SSEToHTTPStreamBridge{
 		sseClient: sseClient,
-		mcpServer: mcpServer,
+		proxy:     proxy,
 		mcpName:   mcpName,
 		logger:    logger,
 	}
 
-	// 3. 设置工具桥接
-	if err := bridge.setupToolBridge(ctx); err != nil {
-		bridge.logger.Warn("Failed to setup tool bridge", "error", err)
-	}
-
-	// 4. 设置资源桥接（如果支持的话）
-	if err := bridge.setupResourceBridge(ctx); err != nil {
-		bridge.logger.Warnf("Resource bridging failed (server may not support resources): %v", err)
-		// 不返回错误，继续启动服务器
-	}
-
-	// 5. 设置提示桥接（如果支持的话）
-	if err := bridge.setupPromptBridge(ctx); err != nil {
-		bridge.logger.Warnf("Prompt bridging failed (server may not support prompts): %v", err)
-		// 不返回错误，继续启动服务器
-	}
-
-	// 6. 创建 StreamableHTTP 服务器包装 MCP 服务器
-	httpStreamServer := server.NewStreamableHTTPServer(
-		mcpServer,
-		server.WithEndpointPath(fmt.Sprintf("/%s", mcpName)),
+	// 3. 创建 StreamableHTTP 服务器包装 MCP 服务器，补全请求在 HTTP 层转发
+	endpointPath := streamableHTTPPath(mcpName)
+	mux := http.NewServeMux()
+	bridge.httpServer = &http.Server{Handler: mux}
+	bridge.StreamableHTTPServer = server.NewStreamableHTTPServer(
+		proxy.mcpServer,
+		server.WithEndpointPath(endpointPath),
 		server.WithStateLess(false), // 保持会话状态以支持实时通信
+		server.WithStreamableHTTPServer(bridge.httpServer),
 	)
-
-	bridge.StreamableHTTPServer = httpStreamServer
+	mux.Handle(endpointPath, proxy.streamableHTTPHandler(bridge.StreamableHTTPServer))
 
 	return bridge, nil
 }
 
-// setupToolBridge 设置工具桥接
-func (b *SSEToHTTPStreamBridge) setupToolBridge(ctx context.Context) error {
-	// 获取 SSE 服务器的工具列表
-	toolsRequest := mcp.ListToolsRequest{}
-	toolsResult, err := b.sseClient.ListTools(ctx, toolsRequest)
-	if err != nil {
-		b.logger.Error("Failed to list tools from SSE server", "error", err)
-		return fmt.Errorf("failed to list tools from SSE server: %w", err)
-	}
-
-	b.logger.Info("Bridging tools from SSE server", "tool_count", len(toolsResult.Tools))
-
-	// 为每个工具创建桥接
-	for _, tool := range toolsResult.Tools {
-		// 复制工具定义
-		bridgedTool := tool
-		toolName := tool.Name
-
-		b.logger.Debug("Bridging tool", "tool_name", toolName)
-
-		// 创建工具处理器，将调用转发到 SSE 客户端
-		b.mcpServer.AddTool(bridgedTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-			b.logger.Debug("Calling tool", "tool_name", toolName)
-
-			// 转发工具调用到 SSE 服务器
-			result, err := b.sseClient.CallTool(ctx, request)
-			if err != nil {
-				b.logger.Error("Tool call failed", "tool_name", toolName, "error", err)
-				return mcp.NewToolResultError(fmt.Sprintf("Failed to call tool %s: %v", toolName, err)), nil
-			}
-
-			b.logger.Debug("Tool call succeeded", "tool_name", toolName, result)
-			return result, nil
-		})
-	}
-
-	return nil
-}
-
-// setupResourceBridge 设置资源桥接
-func (b *SSEToHTTPStreamBridge) setupResourceBridge(ctx context.Context) error {
-	// 获取 SSE 服务器的资源列表
-	resourcesRequest := mcp.ListResourcesRequest{}
-	resourcesResult, err := b.sseClient.ListResources(ctx, resourcesRequest)
-	if err != nil {
-		return fmt.Errorf("failed to list resources from SSE server: %w", err)
-	}
-
-	b.logger.Info("Bridging resources from SSE server", "resource_count", len(resourcesResult.Resources))
-
-	// 为每个资源创建桥接
-	for _, resource := range resourcesResult.Resources {
-		// 复制资源定义
-		bridgedResource := resource
-		resourceURI := resource.URI
-
-		b.logger.Debug("Bridging resource", "resource_uri", resourceURI)
-
-		// 创建资源处理器，将请求转发到 SSE 客户端
-		b.mcpServer.AddResource(bridgedResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-			b.logger.Debug("Reading resource", "resource_uri", resourceURI)
-
-			// 转发资源读取请求到 SSE 服务器
-			result, err := b.sseClient.ReadResource(ctx, request)
-			if err != nil {
-				b.logger.Error("Resource read failed", "resource_uri", resourceURI, "error", err)
-				return nil, fmt.Errorf("failed to read resource %s: %w", resourceURI, err)
-			}
-
-			b.logger.Debug("Resource read succeeded", "resource_uri", resourceURI, result)
-			return result.Contents, nil
-		})
-	}
-
-	// 获取资源模板
-	templatesRequest := mcp.ListResourceTemplatesRequest{}
-	templatesResult, err := b.sseClient.ListResourceTemplates(ctx, templatesRequest)
-	if err != nil {
-		b.logger.Error("Failed to list resource templates from SSE server", "error", err)
-		return fmt.Errorf("failed to list resource templates from SSE server: %w", err)
-	}
-
-	b.logger.Info("Bridging resource templates from SSE server", "template_count", len(templatesResult.ResourceTemplates))
-
-	// 为每个资源模板创建桥接
-	for _, template := range templatesResult.ResourceTemplates {
-		// 复制模板定义
-		bridgedTemplate := template
-		templateURI := template.URITemplate
-
-		b.logger.Debug("Bridging resource template", "template_uri", templateURI)
-
-		// 创建模板处理器
-		b.mcpServer.AddResourceTemplate(bridgedTemplate, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-			b.logger.Debug("Reading resource template", "template_uri", templateURI)
-
-			// 转发资源读取请求到 SSE 服务器
-			result, err := b.sseClient.ReadResource(ctx, request)
-			if err != nil {
-				b.logger.Error("Resource template read failed", "template_uri", templateURI, "error", err)
-				return nil, fmt.Errorf("failed to read resource template %+v: %w", templateURI, err)
-			}
-
-			b.logger.Debug("Resource template read succeeded", "template_uri", templateURI, result)
-			return result.Contents, nil
-		})
-	}
-	return nil
-}
-
-// setupPromptBridge 设置提示桥接
-func (b *SSEToHTTPStreamBridge) setupPromptBridge(ctx context.Context) error {
-	// 获取 SSE 服务器的提示列表
-	promptsRequest := mcp.ListPromptsRequest{}
-	promptsResult, err := b.sseClient.ListPrompts(ctx, promptsRequest)
+// Start 监听 TCP 地址并启动 HTTP Stream 服务器
+func (b *SSEToHTTPStreamBridge) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
 	if err != nil {
-		return fmt.Errorf("failed to list prompts from SSE server: %w", err)
-	}
-
-	b.logger.Info("Bridging prompts from SSE server", "prompt_count", len(promptsResult.Prompts))
-
-	// 为每个提示创建桥接
-	for _, prompt := range promptsResult.Prompts {
-		// 复制提示定义
-		bridgedPrompt := prompt
-		promptName := prompt.Name
-
-		b.logger.Debug("Bridging prompt", "prompt_name", promptName)
-
-		// 创建提示处理器，将请求转发到 SSE 客户端
-		b.mcpServer.AddPrompt(bridgedPrompt, func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
-			b.logger.Debug("Getting prompt", "prompt_name", promptName)
-
-			// 转发提示请求到 SSE 服务器
-			result, err := b.sseClient.GetPrompt(ctx, request)
-			if err != nil {
-				b.logger.Error("Prompt get failed", "prompt_name", promptName, "error", err)
-				return nil, fmt.Errorf("failed to get prompt %s: %w", promptName, err)
-			}
-
-			b.logger.Debug("Prompt get succeeded", "prompt_name", promptName, result)
-			return result, nil
-		})
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
 	}
-
-	return nil
+	return b.Serve(listener)
 }
 
-// Start 启动 HTTP Stream 服务器
-func (b *SSEToHTTPStreamBridge) Start(addr string) error {
+// Serve 在 listener 上启动 HTTP Stream 服务器，阻塞直到桥接器关闭
+func (b *SSEToHTTPStreamBridge) Serve(listener net.Listener) error {
+	addr := listener.Addr().String()
 	b.logger.Info("Starting HTTP Stream bridge server", "address", addr)
 
 	if err := b.Ping(context.Background()); err != nil {
+		_ = listener.Close()
 		b.logger.Error("Failed to ping SSE server", "error", err)
 		return fmt.Errorf("failed to ping SSE server: %w", err)
 	}
 
 	b.logger.Info("HTTP Stream bridge server started successfully", "address", addr)
-	return b.StreamableHTTPServer.Start(addr)
+	return b.httpServer.Serve(listener)
 }
 
 // Close 关闭桥接器
 func (b *SSEToHTTPStreamBridge) Close() error {
 	b.logger.Info("Closing HTTP Stream bridge")
 
+	// 先停止定期同步，避免向已关闭的客户端发送请求
+	b.proxy.Close()
+
 	if b.sseClient != nil {
 		if err := b.sseClient.Close(); err != nil {
 			b.logger.Error("Failed to close SSE client", "error", err)
@@ -285,6 +138,11 @@ func (b *SSEToHTTPStreamBridge) Close() error {
 	return nil
 }
 
+// StreamableHTTPEndpoint 返回 Streamable HTTP 端点路径
+func (b *SSEToHTTPStreamBridge) StreamableHTTPEndpoint() string {
+	return streamableHTTPPath(b.mcpName)
+}
+
 func (b *SSEToHTTPStreamBridge) Ping(ctx context.Context) error {
 	if b.sseClient == nil {
 		return fmt.Errorf("SSE client is not initialized")
@@ -77,7 +77,7 @@ func TestSSEToHTTPStreamBridge(t *testing.T) {
 	time.Sleep(2 * time.Second)
 
 	// 3. 创建 HTTP Stream 客户端连接到我们的桥接器
-	httpStreamTransport, err := transport.NewStreamableHTTP("http://localhost:8083/filesystem-bridge")
+	httpStreamTransport, err := transport.NewStreamableHTTP("http://localhost:8083" + bridge.StreamableHTTPEndpoint())
 	if err != nil {
 		t.Fatalf("Failed to create HTTP Stream transport: %v", err)
 	}
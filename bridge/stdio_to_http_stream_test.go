@@ -52,7 +52,7 @@ func TestHTTPStreamClient(t *testing.T) {
 	t.Log("server started")
 
 	// 创建 HTTP Stream 客户端连接到我们的桥接器
-	httpStreamTransport, err := transport.NewStreamableHTTP("http://localhost:8081/filesystem")
+	httpStreamTransport, err := transport.NewStreamableHTTP("http://localhost:8081" + bridge.StreamableHTTPEndpoint())
 	if err != nil {
 		t.Fatalf("Failed to create HTTP Stream transport: %v", err)
 	}
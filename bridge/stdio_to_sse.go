@@ -3,6 +3,8 @@ package bridge
 import (
 	"context"
 	"fmt"
+	"net"
+	"net/http"
 
 	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
 	client "github.com/mark3labs/mcp-go/client"
@@ -14,24 +16,42 @@ import (
 // StdioToSSEBridge 创建一个将 stdio MCP 服务器桥接到 SSE 的转换器
 type StdioToSSEBridge struct {
 	stdioClient *client.Client
-	mcpServer   *server.MCPServer
+	watcher     *processWatcher
+	proxy       *upstreamProxy
 	*server.SSEServer
 	mcpName string
 	logger  xlog.Logger
+
+	// httpServer 承载桥接器的 HTTP 服务，可以监听 TCP 端口或 unix socket
+	httpServer *http.Server
+
+	// 可选的 Streamable HTTP 端点，与 SSE 共用同一个 MCP 服务器
+	streamServer *server.StreamableHTTPServer
+	streamPath   string
 }
 
-func NewStdioToSSEBridge(ctx context.Context, transport *transport.Stdio, mcpName string) (*StdioToSSEBridge, error) {
+func NewStdioToSSEBridge(ctx context.Context, transport *transport.Stdio, mcpName string, opts ...BridgeOption) (*StdioToSSEBridge, error) {
+	options := newBridgeOptions(opts...)
+
 	// 创建带有 mcpName 的专用 logger
 	logger := xlog.NewLogger("bridge").With("mcp_name", mcpName)
 
-	stdioClient := client.NewClient(transport)
+	// 记录上游 initialize 结果中声明的 completions 能力
+	recorder := &capabilityRecorder{Interface: transport}
+	stdioClient := client.NewClient(recorder)
 
 	logger.Info("Starting stdio client", "mcp_name", mcpName)
-	if err := stdioClient.Start(ctx); err != nil {
+	// 子进程由桥接器关闭，不随启动时的 ctx 结束而退出；关闭时子进程不退出则取消 procCtx 强制结束
+	procCtx, kill := context.WithCancel(context.WithoutCancel(ctx))
+	if err := stdioClient.Start(procCtx); err != nil {
+		kill()
 		logger.Error("Failed to start stdio client", "error", err)
 		return nil, fmt.Errorf("failed to start stdio client: %w", err)
 	}
 
+	// 转发子进程的 stderr 并监视子进程退出，需在初始化前开始读取，便于排查启动失败
+	watcher := watchProcess(transport, options.stderr, kill)
+
 	// 初始化 stdio 客户端
 	initRequest := mcp.InitializeRequest{}
 	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
@@ -43,6 +63,8 @@ func NewStdioToSSEBridge(ctx context.Context, transport *transport.Stdio, mcpNam
 	initResult, err := stdioClient.Initialize(ctx, initRequest)
 	if err != nil {
 		logger.Error("Failed to initialize stdio client", "error", err)
+		waitStderr(watcher.stderrDone)
+		_ = watcher.stop()
 		return nil, fmt.Errorf("failed to initialize stdio client: %w", err)
 	}
 
@@ -51,173 +73,78 @@ func NewStdioToSSEBridge(ctx context.Context, transport *transport.Stdio, mcpNam
 		"server_version", initResult.ServerInfo.Version,
 	)
 
-	// 2. 创建 MCP 服务器，作为桥接层
-	mcpServer := server.NewMCPServer(
-		initResult.ServerInfo.Name,
-		initResult.ServerInfo.Version,
-		server.WithToolCapabilities(true),
-		server.WithResourceCapabilities(true, true),
-		server.WithPromptCapabilities(true),
-	)
+	// 2. 创建 MCP 服务器作为桥接层，转发工具、资源、提示词、补全和日志
+	proxy := newUpstreamProxy(ctx, stdioClient, initResult, recorder.completions.Load(), logger, "stdio", options.resyncInterval)
 
 	bridge := &StdioToSSEBridge{
 		stdioClient: stdioClient,
-		mcpServer:   mcpServer,
+		watcher:     watcher,
+		proxy:       proxy,
 		mcpName:     mcpName,
 		logger:      logger,
 	}
 
-	// 3. 设置工具桥接
-	if err := bridge.setupToolBridge(ctx); err != nil {
-		bridge.logger.Warn("Failed to setup tool bridge", "error", err)
-	}
-
-	// 4. 设置资源桥接（如果支持的话）
-	if err := bridge.setupResourceBridge(ctx); err != nil {
-		bridge.logger.Warnf("Resource bridging failed (server may not support resources): %v", err)
-		// 不返回错误，继续启动服务器
-	}
-
-	// 5. 创建 SSE 服务器包装 MCP 服务器
-	sseServer := server.NewSSEServer(
-		mcpServer,
+	// 3. 创建 SSE 服务器包装 MCP 服务器
+	// SSE 服务器持有 http.Server，以便 Shutdown 时一并关闭 SSE 会话
+	mux := http.NewServeMux()
+	bridge.httpServer = &http.Server{Handler: mux}
+	bridge.SSEServer = server.NewSSEServer(proxy.mcpServer,
 		server.WithStaticBasePath(mcpName),
 		server.WithSSEEndpoint("/sse"),
 		server.WithMessageEndpoint("/message"),
+		server.WithHTTPServer(bridge.httpServer),
 	)
+	mux.Handle("/", proxy.sseHandler(bridge.SSEServer))
 
-	bridge.SSEServer = sseServer
-
-	return bridge, nil
-}
-
-// setupToolBridge 设置工具桥接
-func (b *StdioToSSEBridge) setupToolBridge(ctx context.Context) error {
-	// 获取 stdio 服务器的工具列表
-	toolsRequest := mcp.ListToolsRequest{}
-	toolsResult, err := b.stdioClient.ListTools(ctx, toolsRequest)
-	if err != nil {
-		b.logger.Error("Failed to list tools from stdio server", "error", err)
-		return fmt.Errorf("failed to list tools from stdio server: %w", err)
-	}
-
-	b.logger.Info("Bridging tools from stdio server", "tool_count", len(toolsResult.Tools))
-
-	// 为每个工具创建桥接
-	for _, tool := range toolsResult.Tools {
-		// 复制工具定义
-		bridgedTool := tool
-		toolName := tool.Name
-
-		b.logger.Debug("Bridging tool", "tool_name", toolName)
-
-		// 创建工具处理器，将调用转发到 stdio 客户端
-		b.mcpServer.AddTool(bridgedTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-			b.logger.Debug("Calling tool", "tool_name", toolName)
-
-			// 转发工具调用到 stdio 服务器
-			result, err := b.stdioClient.CallTool(ctx, request)
-			if err != nil {
-				b.logger.Error("Tool call failed", "tool_name", toolName, "error", err)
-				return mcp.NewToolResultError(fmt.Sprintf("Failed to call tool %s: %v", toolName, err)), nil
-			}
-
-			b.logger.Debug("Tool call succeeded", "tool_name", toolName, result)
-			return result, nil
-		})
+	// 4. 需要时在同一端口上额外暴露 Streamable HTTP 端点
+	if options.streamableHTTP {
+		bridge.streamPath = streamableHTTPPath(mcpName)
+		bridge.streamServer = server.NewStreamableHTTPServer(
+			proxy.mcpServer,
+			server.WithEndpointPath(bridge.streamPath),
+			server.WithStateLess(false), // 保持会话状态
+		)
+		mux.Handle(bridge.streamPath, proxy.streamableHTTPHandler(bridge.streamServer))
 	}
 
-	return nil
+	return bridge, nil
 }
 
-// setupResourceBridge 设置资源桥接
-func (b *StdioToSSEBridge) setupResourceBridge(ctx context.Context) error {
-	// 获取 stdio 服务器的资源列表
-	resourcesRequest := mcp.ListResourcesRequest{}
-	resourcesResult, err := b.stdioClient.ListResources(ctx, resourcesRequest)
-	if err != nil {
-		return fmt.Errorf("failed to list resources from stdio server: %w", err)
-	}
-
-	b.logger.Info("Bridging resources from stdio server", "resource_count", len(resourcesResult.Resources))
-
-	// 为每个资源创建桥接
-	for _, resource := range resourcesResult.Resources {
-		// 复制资源定义
-		bridgedResource := resource
-		resourceURI := resource.URI
-
-		b.logger.Debug("Bridging resource", "resource_uri", resourceURI)
-
-		// 创建资源处理器，将请求转发到 stdio 客户端
-		b.mcpServer.AddResource(bridgedResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-			b.logger.Debug("Reading resource", "resource_uri", resourceURI)
-
-			// 转发资源读取请求到 stdio 服务器
-			result, err := b.stdioClient.ReadResource(ctx, request)
-			if err != nil {
-				b.logger.Error("Resource read failed", "resource_uri", resourceURI, "error", err)
-				return nil, fmt.Errorf("failed to read resource %s: %w", resourceURI, err)
-			}
-
-			b.logger.Debug("Resource read succeeded", "resource_uri", resourceURI, result)
-			return result.Contents, nil
-		})
-	}
-
-	// 获取资源模板
-	templatesRequest := mcp.ListResourceTemplatesRequest{}
-	templatesResult, err := b.stdioClient.ListResourceTemplates(ctx, templatesRequest)
+// Start 监听 TCP 地址并启动 SSE 服务器
+func (b *StdioToSSEBridge) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
 	if err != nil {
-		b.logger.Error("Failed to list resource templates from stdio server", "error", err)
-		return fmt.Errorf("failed to list resource templates from stdio server: %w", err)
-	}
-
-	b.logger.Info("Bridging resource templates from stdio server", "template_count", len(templatesResult.ResourceTemplates))
-
-	// 为每个资源模板创建桥接
-	for _, template := range templatesResult.ResourceTemplates {
-		// 复制模板定义
-		bridgedTemplate := template
-		templateURI := template.URITemplate
-
-		b.logger.Debug("Bridging resource template", "template_uri", templateURI)
-
-		// 创建模板处理器
-		b.mcpServer.AddResourceTemplate(bridgedTemplate, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-			b.logger.Debug("Reading resource template", "template_uri", templateURI)
-
-			// 转发资源读取请求到 stdio 服务器
-			result, err := b.stdioClient.ReadResource(ctx, request)
-			if err != nil {
-				b.logger.Error("Resource template read failed", "template_uri", templateURI, "error", err)
-				return nil, fmt.Errorf("failed to read resource template %+v: %w", templateURI, err)
-			}
-
-			b.logger.Debug("Resource template read succeeded", "template_uri", templateURI, result)
-			return result.Contents, nil
-		})
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
 	}
-	return nil
+	return b.Serve(listener)
 }
 
-// StartSSEServer 启动 SSE 服务器
-func (b *StdioToSSEBridge) Start(addr string) error {
+// Serve 在 listener 上启动 SSE 服务器，阻塞直到桥接器关闭
+func (b *StdioToSSEBridge) Serve(listener net.Listener) error {
+	addr := listener.Addr().String()
 	b.logger.Info("Starting SSE bridge server", "address", addr)
 
 	if err := b.Ping(context.Background()); err != nil {
+		_ = listener.Close()
 		b.logger.Error("Failed to ping stdio server", "error", err)
 		return fmt.Errorf("failed to ping stdio server: %w", err)
 	}
 
 	b.logger.Info("SSE bridge server started successfully", "address", addr)
-	return b.SSEServer.Start(addr)
+	return b.httpServer.Serve(listener)
 }
 
 // Close 关闭桥接器
 func (b *StdioToSSEBridge) Close() error {
 	b.logger.Info("Closing SSE bridge")
 
+	// 先停止定期同步，避免向已关闭的客户端发送请求
+	b.proxy.Close()
+
+	// 主动关闭子进程，不作为意外退出通知
+	if err := b.watcher.stop(); err != nil {
+		b.logger.Debug("Stdio process exited", "error", err)
+	}
 	if b.stdioClient != nil {
 		b.stdioClient.Close()
 		b.logger.Debug("Stdio client closed")
@@ -233,9 +160,19 @@ func (b *StdioToSSEBridge) Close() error {
 	return nil
 }
 
+// StreamableHTTPEndpoint 返回 Streamable HTTP 端点路径，未启用时返回空字符串
+func (b *StdioToSSEBridge) StreamableHTTPEndpoint() string {
+	return b.streamPath
+}
+
 func (b *StdioToSSEBridge) Ping(ctx context.Context) error {
 	if b.stdioClient == nil {
 		return fmt.Errorf("stdio client is not initialized")
 	}
 	return b.stdioClient.Ping(ctx)
 }
+
+// Exited 子进程意外退出时返回退出信息，桥接器主动关闭时通道直接关闭
+func (b *StdioToSSEBridge) Exited() <-chan ProcessExit {
+	return b.watcher.exited
+}
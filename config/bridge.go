@@ -0,0 +1,45 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+)
+
+// BridgeNetwork 网关与本地桥接器之间的连接方式
+type BridgeNetwork string
+
+const (
+	BridgeNetworkUnix BridgeNetwork = "unix" // 桥接器监听配置目录下的 unix socket (默认)，不占用端口
+	BridgeNetworkTCP  BridgeNetwork = "tcp"  // 桥接器监听 127.0.0.1 上分配的端口
+)
+
+// IsValid 检查连接方式是否合法，空值表示使用默认值
+func (n BridgeNetwork) IsValid() bool {
+	switch n {
+	case "", BridgeNetworkUnix, BridgeNetworkTCP:
+		return true
+	}
+	return false
+}
+
+// SOCKETS_DIR 默认的 unix socket 目录
+const SOCKETS_DIR = "sockets"
+
+// BridgeListenConfig 本地桥接器的监听方式
+type BridgeListenConfig struct {
+	Network   BridgeNetwork
+	SocketDir string
+}
+
+// UseUnix 是否通过 unix socket 连接桥接器
+func (c BridgeListenConfig) UseUnix() bool {
+	return c.Network != BridgeNetworkTCP
+}
+
+// SocketPath 服务桥接器的 socket 路径
+// 文件名使用工作空间和服务名称的哈希，避免名称中的特殊字符以及超过 socket 路径的长度限制
+func (c BridgeListenConfig) SocketPath(workspace, name string) string {
+	sum := sha256.Sum256([]byte(workspace + "/" + name))
+	return filepath.Join(c.SocketDir, hex.EncodeToString(sum[:8])+".sock")
+}
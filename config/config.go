@@ -16,6 +16,17 @@ type Config struct {
 	SessionGCInterval   time.Duration // Session GC间隔
 	ProxySessionTimeout time.Duration // Proxy Session 超时时间
 	McpServiceMgrConfig McpServiceMgrConfig
+	Workspaces          map[string]WorkspaceOptions // 按工作空间ID单独配置的选项
+	SecretsDir          string                      // 密钥目录，${secret:name} 从该目录读取，默认为配置目录下的 secrets
+	StateFile           string                      // 持久化工作空间和服务的状态文件，默认为配置目录下的 state.json
+	PortRange           PortRangeConfig             // 桥接服务监听的端口范围，BridgeNetwork 为 tcp 时使用
+	BridgeNetwork       BridgeNetwork               // 网关与本地桥接器之间的连接方式: unix(默认) 或 tcp
+	SocketDir           string                      // unix socket 目录，默认为配置目录下的 sockets
+
+	// 会话的回收和数量限制，SessionGCInterval 以外的项可以在 Workspaces 中按工作空间覆盖
+	SessionMaxLifetime      time.Duration // 会话最长存活时间，0 表示不限制
+	MaxSessionsPerWorkspace int           // 每个工作空间的最大会话数，0 表示不限制
+	MaxSessionsPerApiKey    int           // 每个 API Key 在所有工作空间中的最大会话数，0 表示不限制
 }
 
 func InitConfig(cfgDir string) (cfg *Config, err error) {
@@ -51,7 +62,7 @@ func (c *Config) Default() {
 		}
 	}
 	if c.SessionGCInterval == 0 {
-		c.SessionGCInterval = 5 * time.Minute
+		c.SessionGCInterval = DefaultSessionGCInterval
 	}
 	if c.ProxySessionTimeout == 0 {
 		c.ProxySessionTimeout = 5 * c.SessionGCInterval // 默认为5倍GC间隔
@@ -59,7 +70,48 @@ func (c *Config) Default() {
 	if c.McpServiceMgrConfig.McpServiceRetryCount == 0 {
 		c.McpServiceMgrConfig.McpServiceRetryCount = 3
 	}
+	if c.PortRange.Start == 0 && c.PortRange.End == 0 {
+		c.PortRange = PortRangeConfig{Start: 10000, End: 19999}
+	}
+
+}
 
+// GetWorkspaceOptions 获取指定工作空间的配置选项，未配置时返回默认值
+func (c *Config) GetWorkspaceOptions(workId string) WorkspaceOptions {
+	return c.Workspaces[workId]
+}
+
+// GetSecretsDir 获取密钥目录，相对路径基于配置目录
+func (c *Config) GetSecretsDir() string {
+	if c.SecretsDir == "" {
+		return filepath.Join(c.ConfigDirPath, SECRETS_DIR)
+	}
+	if filepath.IsAbs(c.SecretsDir) {
+		return c.SecretsDir
+	}
+	return filepath.Join(c.ConfigDirPath, c.SecretsDir)
+}
+
+// GetEnvResolver 获取解析服务环境变量的解析器
+func (c *Config) GetEnvResolver() EnvResolver {
+	return EnvResolver{
+		BaseDir:    c.ConfigDirPath,
+		SecretsDir: c.GetSecretsDir(),
+	}
+}
+
+// GetBridgeListenConfig 获取本地桥接器的监听方式，相对路径基于配置目录
+func (c *Config) GetBridgeListenConfig() BridgeListenConfig {
+	socketDir := c.SocketDir
+	if socketDir == "" {
+		socketDir = filepath.Join(c.ConfigDirPath, SOCKETS_DIR)
+	} else if !filepath.IsAbs(socketDir) {
+		socketDir = filepath.Join(c.ConfigDirPath, socketDir)
+	}
+	return BridgeListenConfig{
+		Network:   c.BridgeNetwork,
+		SocketDir: socketDir,
+	}
 }
 
 func (c *Config) GetAuthConfig() *AuthConfig {
@@ -85,6 +137,12 @@ func (c *AuthConfig) GetApiKey() string {
 	return c.ApiKey
 }
 
+// PortRangeConfig 端口范围，包含 Start 和 End
+type PortRangeConfig struct {
+	Start int
+	End   int
+}
+
 type McpServiceMgrConfig struct {
 	McpServiceRetryCount int // 服务重试次数，服务挂掉后会重试
 }
@@ -105,6 +163,20 @@ func (c *Config) GetMcpConfigPath() string {
 
 const CONFIG_PATH = "config.json"
 
+// STATE_PATH 默认的状态文件，保存通过 API 部署的工作空间和服务
+const STATE_PATH = "state.json"
+
+// GetStatePath 获取状态文件路径，相对路径基于配置目录
+func (c *Config) GetStatePath() string {
+	if c.StateFile == "" {
+		return filepath.Join(c.ConfigDirPath, STATE_PATH)
+	}
+	if filepath.IsAbs(c.StateFile) {
+		return c.StateFile
+	}
+	return filepath.Join(c.ConfigDirPath, c.StateFile)
+}
+
 // 保存这个Config信息
 func (c *Config) SaveConfig() error {
 	data, err := json.MarshalIndent(c, "", "    ")
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SECRETS_DIR 默认的密钥目录，位于配置目录下，每个文件是一个密钥，文件名即密钥名
+const SECRETS_DIR = "secrets"
+
+// RedactedValue 在接口响应中替换敏感值
+const RedactedValue = "******"
+
+// envReferencePattern 匹配环境变量值中的引用: ${secret:name}、${env:HOST_VAR}
+var envReferencePattern = regexp.MustCompile(`\$\{(secret|env):([^}]*)\}`)
+
+// EnvResolver 在启动服务时解析环境变量: 读取 dotenv 文件并展开密钥和宿主机环境变量引用
+type EnvResolver struct {
+	BaseDir    string // envFile 为相对路径时的基准目录
+	SecretsDir string // ${secret:name} 读取的密钥目录
+}
+
+// Resolve 解析服务的环境变量，返回 KEY=VALUE 列表，env 中的值覆盖 envFile 中的同名变量
+func (r EnvResolver) Resolve(cfg MCPServerConfig) ([]string, error) {
+	envs := make(map[string]string)
+	if cfg.EnvFile != "" {
+		path := cfg.EnvFile
+		if !filepath.IsAbs(path) && r.BaseDir != "" {
+			path = filepath.Join(r.BaseDir, path)
+		}
+		fileEnvs, err := ReadDotenv(path)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range fileEnvs {
+			envs[k] = v
+		}
+	}
+	for k, v := range cfg.Env {
+		envs[k] = v
+	}
+
+	list := make([]string, 0, len(envs))
+	for k, v := range envs {
+		value, err := r.Expand(v)
+		if err != nil {
+			return nil, fmt.Errorf("env %s: %w", k, err)
+		}
+		list = append(list, k+"="+value)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// Expand 展开值中的 ${secret:name} 和 ${env:HOST_VAR} 引用，其他内容保持不变
+func (r EnvResolver) Expand(value string) (string, error) {
+	var expandErr error
+	expanded := envReferencePattern.ReplaceAllStringFunc(value, func(ref string) string {
+		match := envReferencePattern.FindStringSubmatch(ref)
+		var resolved string
+		var err error
+		switch match[1] {
+		case "secret":
+			resolved, err = r.readSecret(match[2])
+		case "env":
+			var ok bool
+			if resolved, ok = os.LookupEnv(match[2]); !ok {
+				err = fmt.Errorf("host env %s is not set", match[2])
+			}
+		}
+		if err != nil && expandErr == nil {
+			expandErr = err
+		}
+		return resolved
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return expanded, nil
+}
+
+// readSecret 读取密钥文件内容，去掉末尾换行
+func (r EnvResolver) readSecret(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid secret name %q", name)
+	}
+	if r.SecretsDir == "" {
+		return "", fmt.Errorf("secret %s: secrets directory is not configured", name)
+	}
+	data, err := os.ReadFile(filepath.Join(r.SecretsDir, name))
+	if err != nil {
+		return "", fmt.Errorf("read secret %s: %w", name, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// ReadDotenv 读取 dotenv 文件: 每行 KEY=VALUE，支持 # 注释、export 前缀和引号
+func ReadDotenv(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read env file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	envs := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("env file %s line %d: expected KEY=VALUE", path, lineNo)
+		}
+		envs[key] = parseDotenvValue(strings.TrimSpace(value))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read env file %s: %w", path, err)
+	}
+	return envs, nil
+}
+
+// parseDotenvValue 去掉值两侧的引号，双引号内支持 \n 转义；未加引号的值去掉行尾注释
+func parseDotenvValue(value string) string {
+	if len(value) >= 2 {
+		switch quote := value[0]; {
+		case quote == '"' && value[len(value)-1] == '"':
+			return strings.NewReplacer(`\n`, "\n", `\"`, `"`).Replace(value[1 : len(value)-1])
+		case quote == '\'' && value[len(value)-1] == '\'':
+			return value[1 : len(value)-1]
+		}
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value
+}
+
+// redactValue 隐藏明文值，只引用密钥或环境变量的值不含敏感信息，原样保留
+func redactValue(value string) string {
+	if value == "" || envReferencePattern.ReplaceAllString(value, "") == "" {
+		return value
+	}
+	return RedactedValue
+}
+
+// Redacted 返回隐藏了环境变量值、请求头和 Token 的配置副本，用于接口响应
+func (c MCPServerConfig) Redacted() MCPServerConfig {
+	if len(c.Env) > 0 {
+		env := make(map[string]string, len(c.Env))
+		for k, v := range c.Env {
+			env[k] = redactValue(v)
+		}
+		c.Env = env
+	}
+	if len(c.Headers) > 0 {
+		headers := make(map[string]string, len(c.Headers))
+		for k, v := range c.Headers {
+			headers[k] = redactValue(v)
+		}
+		c.Headers = headers
+	}
+	c.BearerToken = redactValue(c.BearerToken)
+	return c
+}
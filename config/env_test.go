@@ -0,0 +1,79 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEnvResolver_Resolve(t *testing.T) {
+	dir := t.TempDir()
+	secretsDir := filepath.Join(dir, SECRETS_DIR)
+	if err := os.Mkdir(secretsDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(secretsDir, "token"), []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	dotenv := "# comment\nexport FROM_FILE=file\nOVERRIDE=file\nQUOTED=\"a b\"\nINLINE=value # comment\n"
+	if err := os.WriteFile(filepath.Join(dir, "app.env"), []byte(dotenv), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GATEWAY_TEST_HOST_VAR", "host")
+
+	resolver := EnvResolver{BaseDir: dir, SecretsDir: secretsDir}
+	envs, err := resolver.Resolve(MCPServerConfig{
+		EnvFile: "app.env",
+		Env: map[string]string{
+			"PLAIN":    "value",
+			"OVERRIDE": "env",
+			"TOKEN":    "Bearer ${secret:token}",
+			"HOST":     "${env:GATEWAY_TEST_HOST_VAR}",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Resolve() error: %v", err)
+	}
+
+	expected := []string{
+		"FROM_FILE=file",
+		"HOST=host",
+		"INLINE=value",
+		"OVERRIDE=env",
+		"PLAIN=value",
+		"QUOTED=a b",
+		"TOKEN=Bearer s3cr3t",
+	}
+	if !reflect.DeepEqual(envs, expected) {
+		t.Errorf("Resolve() = %v, want %v", envs, expected)
+	}
+}
+
+func TestEnvResolver_ResolveErrors(t *testing.T) {
+	resolver := EnvResolver{SecretsDir: t.TempDir()}
+	tests := []struct {
+		name string
+		cfg  MCPServerConfig
+	}{
+		{name: "missing secret", cfg: MCPServerConfig{Env: map[string]string{"K": "${secret:missing}"}}},
+		{name: "secret path traversal", cfg: MCPServerConfig{Env: map[string]string{"K": "${secret:../token}"}}},
+		{name: "missing host env", cfg: MCPServerConfig{Env: map[string]string{"K": "${env:GATEWAY_TEST_UNSET_VAR}"}}},
+		{name: "missing env file", cfg: MCPServerConfig{EnvFile: filepath.Join(t.TempDir(), "missing.env")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := resolver.Resolve(tt.cfg); err == nil {
+				t.Errorf("Expected Resolve() to fail")
+			}
+		})
+	}
+}
+
+func TestMCPServerConfig_GetEnvs(t *testing.T) {
+	cfg := MCPServerConfig{Env: map[string]string{"PLAIN": "value", "TOKEN": "${secret:token}"}}
+	// 引用保持原样，由 EnvResolver 在启动时解析
+	if envs := cfg.GetEnvs(); !reflect.DeepEqual(envs, []string{"PLAIN=value", "TOKEN=${secret:token}"}) {
+		t.Errorf("GetEnvs() = %v", envs)
+	}
+}
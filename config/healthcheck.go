@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	DefaultHealthCheckInterval         = 30 * time.Second
+	DefaultHealthCheckTimeout          = 10 * time.Second
+	DefaultHealthCheckFailureThreshold = 3
+)
+
+// HealthCheckConfig 服务的主动健康检查，通过 MCP ping 探测，可以额外调用一个工具，未配置时使用默认值
+type HealthCheckConfig struct {
+	Disabled          bool           `json:"disabled,omitempty"`
+	IntervalSeconds   int            `json:"intervalSeconds,omitempty"`   // 探测间隔，默认 30
+	TimeoutSeconds    int            `json:"timeoutSeconds,omitempty"`    // 单次探测的超时时间，默认 10
+	FailureThreshold  int            `json:"failureThreshold,omitempty"`  // 连续失败达到该次数时判定为不健康，默认 3
+	DegradedLatencyMs int            `json:"degradedLatencyMs,omitempty"` // 探测耗时超过该值时判定为降级，0 表示不按耗时判定
+	CanaryTool        string         `json:"canaryTool,omitempty"`        // 探测时额外调用的工具，返回错误视为探测失败
+	CanaryArguments   map[string]any `json:"canaryArguments,omitempty"`   // 调用 canaryTool 的参数
+}
+
+// Validate 检查健康检查配置是否合法，nil 表示使用默认值
+func (c *HealthCheckConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.IntervalSeconds < 0 || c.TimeoutSeconds < 0 || c.FailureThreshold < 0 || c.DegradedLatencyMs < 0 {
+		return fmt.Errorf("健康检查的间隔、超时、失败次数和耗时不能为负数")
+	}
+	if c.CanaryTool == "" && len(c.CanaryArguments) > 0 {
+		return fmt.Errorf("设置 canaryArguments 时必须指定 canaryTool")
+	}
+	return nil
+}
+
+// Enabled 是否开启主动健康检查，默认开启
+func (c *HealthCheckConfig) Enabled() bool {
+	return c == nil || !c.Disabled
+}
+
+// Interval 探测间隔
+func (c *HealthCheckConfig) Interval() time.Duration {
+	if c == nil || c.IntervalSeconds == 0 {
+		return DefaultHealthCheckInterval
+	}
+	return time.Duration(c.IntervalSeconds) * time.Second
+}
+
+// Timeout 单次探测的超时时间
+func (c *HealthCheckConfig) Timeout() time.Duration {
+	if c == nil || c.TimeoutSeconds == 0 {
+		return DefaultHealthCheckTimeout
+	}
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+// GetFailureThreshold 判定为不健康的连续失败次数
+func (c *HealthCheckConfig) GetFailureThreshold() int {
+	if c == nil || c.FailureThreshold == 0 {
+		return DefaultHealthCheckFailureThreshold
+	}
+	return c.FailureThreshold
+}
+
+// DegradedLatency 判定为降级的探测耗时，0 表示不按耗时判定
+func (c *HealthCheckConfig) DegradedLatency() time.Duration {
+	if c == nil {
+		return 0
+	}
+	return time.Duration(c.DegradedLatencyMs) * time.Millisecond
+}
+
+// GetCanaryTool 探测时额外调用的工具，为空时只发送 ping
+func (c *HealthCheckConfig) GetCanaryTool() (string, map[string]any) {
+	if c == nil {
+		return "", nil
+	}
+	return c.CanaryTool, c.CanaryArguments
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// MCPIsolation stdio 服务的子进程在会话之间的隔离方式
+type MCPIsolation string
+
+const (
+	IsolationShared     MCPIsolation = "shared"      // 所有会话共用一个子进程 (默认)
+	IsolationPerSession MCPIsolation = "per-session" // 每个会话启动独立的子进程，会话关闭时结束
+	IsolationPooled     MCPIsolation = "pooled"      // 会话从池中独占一个子进程，会话关闭后归还复用，空闲超时后结束
+)
+
+const (
+	DefaultIsolationPoolSize    = 4
+	DefaultIsolationIdleTimeout = 5 * time.Minute
+)
+
+// IsValid 检查隔离方式是否合法，空值表示 shared
+func (i MCPIsolation) IsValid() bool {
+	switch i {
+	case "", IsolationShared, IsolationPerSession, IsolationPooled:
+		return true
+	}
+	return false
+}
+
+// IsolationConfig 会话隔离的配置，per-session 和 pooled 时生效
+type IsolationConfig struct {
+	Mode               MCPIsolation `json:"mode,omitempty"`
+	PoolSize           int          `json:"poolSize,omitempty"`           // 同时存在的子进程上限，pooled 默认 4，per-session 默认不限制
+	IdleTimeoutSeconds int          `json:"idleTimeoutSeconds,omitempty"` // pooled 时空闲子进程保留的时间，默认 300
+	WarmSpares         int          `json:"warmSpares,omitempty"`         // 预先启动的空闲子进程数，新会话直接使用，不计入空闲超时
+}
+
+// Validate 检查隔离配置是否合法，nil 表示 shared
+func (c *IsolationConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if !c.Mode.IsValid() {
+		return fmt.Errorf("不支持的隔离方式: %s", c.Mode)
+	}
+	if c.PoolSize < 0 || c.IdleTimeoutSeconds < 0 || c.WarmSpares < 0 {
+		return fmt.Errorf("隔离的 poolSize、idleTimeoutSeconds 和 warmSpares 不能为负数")
+	}
+	if size := c.GetPoolSize(); size > 0 && c.WarmSpares > size {
+		return fmt.Errorf("隔离的 warmSpares 不能超过 poolSize (%d)", size)
+	}
+	return nil
+}
+
+// GetMode 获取隔离方式，未设置时为 shared
+func (c *IsolationConfig) GetMode() MCPIsolation {
+	if c == nil || c.Mode == "" {
+		return IsolationShared
+	}
+	return c.Mode
+}
+
+// Isolated 会话是否使用独立的子进程
+func (c *IsolationConfig) Isolated() bool {
+	return c.GetMode() != IsolationShared
+}
+
+// GetPoolSize 同时存在的子进程上限，0 表示不限制
+func (c *IsolationConfig) GetPoolSize() int {
+	if c == nil {
+		return 0
+	}
+	if c.PoolSize <= 0 && c.GetMode() == IsolationPooled {
+		return DefaultIsolationPoolSize
+	}
+	return c.PoolSize
+}
+
+// GetWarmSpares 预先启动的空闲子进程数，shared 时为 0
+func (c *IsolationConfig) GetWarmSpares() int {
+	if !c.Isolated() {
+		return 0
+	}
+	return c.WarmSpares
+}
+
+// IdleTimeout pooled 时空闲子进程保留的时间
+func (c *IsolationConfig) IdleTimeout() time.Duration {
+	if c == nil || c.IdleTimeoutSeconds <= 0 {
+		return DefaultIsolationIdleTimeout
+	}
+	return time.Duration(c.IdleTimeoutSeconds) * time.Second
+}
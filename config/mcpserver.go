@@ -1,21 +1,136 @@
 package config
 
+import (
+	"path"
+	"sort"
+)
+
+// MCPServerTransport 定义MCP服务对外暴露的传输方式
+type MCPServerTransport string
+
+const (
+	TransportSSE            MCPServerTransport = "sse"             // 仅暴露 SSE (默认)
+	TransportStreamableHTTP MCPServerTransport = "streamable-http" // 仅暴露 Streamable HTTP
+	TransportBoth           MCPServerTransport = "both"            // 同时暴露 SSE 和 Streamable HTTP
+)
+
+// IsValid 检查传输方式是否合法，空值表示使用默认值
+func (t MCPServerTransport) IsValid() bool {
+	switch t {
+	case "", TransportSSE, TransportStreamableHTTP, TransportBoth:
+		return true
+	}
+	return false
+}
+
+// ExposeSSE 是否需要暴露 SSE 端点
+func (t MCPServerTransport) ExposeSSE() bool {
+	return t == "" || t == TransportSSE || t == TransportBoth
+}
+
+// ExposeStreamableHTTP 是否需要暴露 Streamable HTTP 端点
+func (t MCPServerTransport) ExposeStreamableHTTP() bool {
+	return t == TransportStreamableHTTP || t == TransportBoth
+}
+
+// MCPUpstreamType 定义远程MCP服务(URL)自身使用的传输协议
+type MCPUpstreamType string
+
+const (
+	UpstreamAuto           MCPUpstreamType = ""                // 自动探测
+	UpstreamSSE            MCPUpstreamType = "sse"             // HTTP+SSE (2024-11-05)
+	UpstreamStreamableHTTP MCPUpstreamType = "streamable-http" // Streamable HTTP (2025-03-26)
+)
+
+// IsValid 检查远程传输协议是否合法，空值表示自动探测
+func (t MCPUpstreamType) IsValid() bool {
+	switch t {
+	case UpstreamAuto, UpstreamSSE, UpstreamStreamableHTTP:
+		return true
+	}
+	return false
+}
+
 // MCPServerConfig 定义单个MCP服务器的配置
 type MCPServerConfig struct {
-	Workspace string            `json:"workspace,omitempty"`
-	URL       string            `json:"url,omitempty"`
-	Command   string            `json:"command,omitempty"`
-	Args      []string          `json:"args,omitempty"`
-	Env       map[string]string `json:"env,omitempty"`
+	Workspace string             `json:"workspace,omitempty"`
+	URL       string             `json:"url,omitempty"`
+	Command   string             `json:"command,omitempty"`
+	Args      []string           `json:"args,omitempty"`
+	Env       map[string]string  `json:"env,omitempty"`       // 值支持 ${secret:name}、${env:HOST_VAR} 引用，启动时解析
+	EnvFile   string             `json:"envFile,omitempty"`   // dotenv 文件路径，相对路径基于配置目录，env 中的同名变量优先
+	Transport MCPServerTransport `json:"transport,omitempty"` // sse, streamable-http, both
+
+	// 以下仅对 URL 类型的远程服务生效
+	Type        MCPUpstreamType   `json:"type,omitempty"`        // 远程服务的传输协议: sse, streamable-http, 为空时自动探测
+	Headers     map[string]string `json:"headers,omitempty"`     // 请求远程服务时携带的自定义请求头
+	BearerToken string            `json:"bearerToken,omitempty"` // 请求远程服务时携带的 Bearer Token
+
+	ToolFilter  *ToolFilter        `json:"toolFilter,omitempty"`  // 聚合会话中对外暴露的工具，为空时暴露全部
+	HealthCheck *HealthCheckConfig `json:"healthCheck,omitempty"` // 主动健康检查，为空时使用默认配置
+	Sandbox     *SandboxConfig     `json:"sandbox,omitempty"`     // stdio 服务的资源限制和隔离，为空时不限制
+	Isolation   *IsolationConfig   `json:"isolation,omitempty"`   // stdio 服务在会话之间的隔离方式，为空时所有会话共用一个子进程
+	Warmup      *WarmupConfig      `json:"warmup,omitempty"`      // 部署时预热依赖包，为空时 npx/uvx 服务自动预热
 
 	LogConfig
 	McpServiceMgrConfig
 }
 
+// ToolFilter 按工具原始名称过滤工具，支持 * 通配符，deny 优先于 allow
+type ToolFilter struct {
+	Allow []string `json:"allow,omitempty"` // 只暴露匹配的工具，为空时不限制
+	Deny  []string `json:"deny,omitempty"`  // 不暴露匹配的工具
+}
+
+// Allowed 判断工具是否对外暴露，未配置过滤时总是暴露
+func (f *ToolFilter) Allowed(name string) bool {
+	if f == nil {
+		return true
+	}
+	if matchAny(f.Deny, name) {
+		return false
+	}
+	return len(f.Allow) == 0 || matchAny(f.Allow, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// GetEnvs 获取 KEY=VALUE 形式的环境变量列表，不解析引用和 envFile，启动服务请使用 EnvResolver.Resolve
 func (c *MCPServerConfig) GetEnvs() []string {
 	list := make([]string, 0, len(c.Env))
-	for s := range c.Env {
-		list = append(list, s)
+	for k, v := range c.Env {
+		list = append(list, k+"="+v)
 	}
+	sort.Strings(list)
 	return list
 }
+
+// GetTransport 获取传输方式，未设置时默认为 SSE
+func (c *MCPServerConfig) GetTransport() MCPServerTransport {
+	if c.Transport == "" {
+		return TransportSSE
+	}
+	return c.Transport
+}
+
+// GetHeaders 获取请求远程服务时需要携带的请求头，BearerToken 会覆盖 Headers 中的 Authorization
+func (c *MCPServerConfig) GetHeaders() map[string]string {
+	if len(c.Headers) == 0 && c.BearerToken == "" {
+		return nil
+	}
+	headers := make(map[string]string, len(c.Headers)+1)
+	for k, v := range c.Headers {
+		headers[k] = v
+	}
+	if c.BearerToken != "" {
+		headers["Authorization"] = "Bearer " + c.BearerToken
+	}
+	return headers
+}
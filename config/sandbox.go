@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// SandboxConfig stdio 服务子进程的资源限制和隔离，仅在 Linux 上生效，未设置的项不限制
+type SandboxConfig struct {
+	MemoryMB      int      `json:"memoryMB,omitempty"`      // 内存上限，限制整个进程树，需要 cgroup v2
+	CPUSeconds    int      `json:"cpuSeconds,omitempty"`    // 累计 CPU 时间上限 (RLIMIT_CPU)，超过后子进程被终止
+	CPUs          float64  `json:"cpus,omitempty"`          // 可使用的 CPU 核数，需要 cgroup v2
+	OpenFiles     int      `json:"openFiles,omitempty"`     // 打开文件数上限 (RLIMIT_NOFILE)
+	MaxProcesses  int      `json:"maxProcesses,omitempty"`  // 进程数上限，cgroup v2 可用时为 pids.max，否则为 RLIMIT_NPROC（按用户计数）
+	WorkDir       string   `json:"workDir,omitempty"`       // 子进程的工作目录，默认为网关的工作目录
+	UID           *int     `json:"uid,omitempty"`           // 以该用户运行，需要网关以 root 运行
+	GID           *int     `json:"gid,omitempty"`           // 以该用户组运行，需要网关以 root 运行
+	ReadOnlyFS    bool     `json:"readOnlyFS,omitempty"`    // 在独立的 mount namespace 中将根文件系统挂载为只读，需要网关以 root 运行
+	WritablePaths []string `json:"writablePaths,omitempty"` // readOnlyFS 时仍然可写的目录，workDir 和 /tmp 默认可写
+}
+
+// Validate 检查沙箱配置是否合法，nil 表示不限制
+func (c *SandboxConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.MemoryMB < 0 || c.CPUSeconds < 0 || c.CPUs < 0 || c.OpenFiles < 0 || c.MaxProcesses < 0 {
+		return fmt.Errorf("沙箱的资源限制不能为负数")
+	}
+	if (c.UID != nil && *c.UID < 0) || (c.GID != nil && *c.GID < 0) {
+		return fmt.Errorf("沙箱的 uid 和 gid 不能为负数")
+	}
+	if c.WorkDir != "" && !filepath.IsAbs(c.WorkDir) {
+		return fmt.Errorf("沙箱的 workDir 必须是绝对路径: %s", c.WorkDir)
+	}
+	for _, p := range c.WritablePaths {
+		if !filepath.IsAbs(p) {
+			return fmt.Errorf("沙箱的 writablePaths 必须是绝对路径: %s", p)
+		}
+	}
+	if len(c.WritablePaths) > 0 && !c.ReadOnlyFS {
+		return fmt.Errorf("设置 writablePaths 时必须开启 readOnlyFS")
+	}
+	return nil
+}
+
+// Enabled 是否需要通过沙箱启动子进程
+func (c *SandboxConfig) Enabled() bool {
+	if c == nil {
+		return false
+	}
+	return c.MemoryMB > 0 || c.CPUSeconds > 0 || c.CPUs > 0 || c.OpenFiles > 0 || c.MaxProcesses > 0 ||
+		c.WorkDir != "" || c.UID != nil || c.GID != nil || c.ReadOnlyFS
+}
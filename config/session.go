@@ -0,0 +1,50 @@
+package config
+
+import "time"
+
+// DefaultSessionGCInterval 未配置时回收会话的检查间隔
+const DefaultSessionGCInterval = 5 * time.Minute
+
+// SessionOptions 按工作空间覆盖会话的回收和数量限制，未配置的项使用全局配置
+type SessionOptions struct {
+	IdleTimeoutSeconds int `json:"idleTimeoutSeconds,omitempty"` // 没有活跃连接的会话空闲多久后回收
+	MaxLifetimeSeconds int `json:"maxLifetimeSeconds,omitempty"` // 会话创建后最长存活的时间
+	MaxSessions        int `json:"maxSessions,omitempty"`        // 工作空间的最大会话数
+}
+
+// SessionLimits 工作空间生效的会话回收和数量限制，时长和数量为 0 表示不限制
+type SessionLimits struct {
+	GCInterval  time.Duration // 回收会话的检查间隔
+	IdleTimeout time.Duration // 没有活跃连接的会话空闲超时
+	MaxLifetime time.Duration // 会话最长存活时间
+	MaxSessions int           // 工作空间的最大会话数
+}
+
+// GetGCInterval 获取回收会话的检查间隔，未配置时为 5 分钟
+func (l SessionLimits) GetGCInterval() time.Duration {
+	if l.GCInterval <= 0 {
+		return DefaultSessionGCInterval
+	}
+	return l.GCInterval
+}
+
+// GetSessionLimits 获取工作空间生效的会话回收和数量限制，工作空间未配置的项使用全局配置
+func (c *Config) GetSessionLimits(workId string) SessionLimits {
+	limits := SessionLimits{
+		GCInterval:  c.SessionGCInterval,
+		IdleTimeout: c.ProxySessionTimeout,
+		MaxLifetime: c.SessionMaxLifetime,
+		MaxSessions: c.MaxSessionsPerWorkspace,
+	}
+	opts := c.GetWorkspaceOptions(workId).Session
+	if opts.IdleTimeoutSeconds > 0 {
+		limits.IdleTimeout = time.Duration(opts.IdleTimeoutSeconds) * time.Second
+	}
+	if opts.MaxLifetimeSeconds > 0 {
+		limits.MaxLifetime = time.Duration(opts.MaxLifetimeSeconds) * time.Second
+	}
+	if opts.MaxSessions > 0 {
+		limits.MaxSessions = opts.MaxSessions
+	}
+	return limits
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const DefaultWarmupTimeout = 10 * time.Minute
+
+// WarmupConfig 部署时预热依赖包的配置，npx 和 uvx 启动的服务默认开启
+// 预热在部署时执行一次，下载并缓存依赖包，之后的启动和 per-session 子进程直接使用缓存
+type WarmupConfig struct {
+	Disabled       bool     `json:"disabled,omitempty"`
+	Command        string   `json:"command,omitempty"`        // 自定义预热命令，为空时根据 npx/uvx 的参数推导
+	Args           []string `json:"args,omitempty"`           // 自定义预热命令的参数
+	TimeoutSeconds int      `json:"timeoutSeconds,omitempty"` // 预热的超时时间，默认 600
+}
+
+// Validate 检查预热配置是否合法，nil 表示使用默认配置
+func (c *WarmupConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.TimeoutSeconds < 0 {
+		return fmt.Errorf("预热的 timeoutSeconds 不能为负数")
+	}
+	if c.Command == "" && len(c.Args) > 0 {
+		return fmt.Errorf("设置预热的 args 时必须指定 command")
+	}
+	return nil
+}
+
+// Timeout 预热的超时时间
+func (c *WarmupConfig) Timeout() time.Duration {
+	if c == nil || c.TimeoutSeconds == 0 {
+		return DefaultWarmupTimeout
+	}
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+// npx 和 uvx 中带值的选项，用于找到参数中的包名
+var (
+	npxValueFlags = []string{"-p", "--package", "-c", "--call", "--registry", "--cache", "--userconfig"}
+	uvxValueFlags = []string{"--from", "--with", "--with-editable", "--with-requirements", "-p", "--python",
+		"--index", "--index-url", "--default-index", "--extra-index-url", "-f", "--find-links", "--cache-dir", "-c", "--constraints"}
+)
+
+// WarmupCommand 部署时预热依赖包的命令，没有需要预热的内容时返回 false
+// npx 服务通过 npx --yes --package <包> -c true 安装到 npx 缓存，uvx 服务通过 uvx --from <包> python -c "" 创建工具环境
+func (c *MCPServerConfig) WarmupCommand() (string, []string, bool) {
+	if c.Warmup != nil && c.Warmup.Disabled {
+		return "", nil, false
+	}
+	if c.Warmup != nil && c.Warmup.Command != "" {
+		return c.Warmup.Command, c.Warmup.Args, true
+	}
+
+	switch strings.TrimSuffix(filepath.Base(c.Command), filepath.Ext(c.Command)) {
+	case "npx":
+		flags, pkg, hasPackage := leadingFlags(c.Args, npxValueFlags, []string{"-y", "--yes", "-c", "--call"}, "-p", "--package")
+		if pkg == "" && !hasPackage {
+			return "", nil, false
+		}
+		args := append([]string{"--yes"}, flags...)
+		if !hasPackage {
+			args = append(args, "--package", pkg)
+		}
+		return c.Command, append(args, "-c", "true"), true
+	case "uvx":
+		flags, pkg, hasFrom := leadingFlags(c.Args, uvxValueFlags, nil, "--from")
+		if pkg == "" && !hasFrom {
+			return "", nil, false
+		}
+		args := flags
+		if !hasFrom {
+			args = append(args, "--from", pkg)
+		}
+		return c.Command, append(args, "python", "-c", ""), true
+	}
+	return "", nil, false
+}
+
+// leadingFlags 拆分命令参数: 第一个位置参数之前的选项和第一个位置参数，同时返回选项中是否包含 keys
+// drop 中的选项由预热命令重新指定，不保留在返回的选项中
+func leadingFlags(args, valueFlags, drop []string, keys ...string) (flags []string, positional string, hasKey bool) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			return flags, arg, hasKey
+		}
+		if arg == "--" {
+			if i+1 < len(args) {
+				positional = args[i+1]
+			}
+			return flags, positional, hasKey
+		}
+		name, _, inline := strings.Cut(arg, "=")
+		if slices.Contains(keys, name) {
+			hasKey = true
+		}
+		takesValue := !inline && slices.Contains(valueFlags, name)
+		if !slices.Contains(drop, name) {
+			flags = append(flags, arg)
+			if takesValue && i+1 < len(args) {
+				flags = append(flags, args[i+1])
+			}
+		}
+		if takesValue {
+			i++
+		}
+	}
+	return flags, "", hasKey
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWarmupCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     MCPServerConfig
+		command string
+		args    []string
+	}{
+		{"npx", MCPServerConfig{Command: "npx", Args: []string{"-y", "@modelcontextprotocol/server-filesystem", "/tmp"}},
+			"npx", []string{"--yes", "--package", "@modelcontextprotocol/server-filesystem", "-c", "true"}},
+		{"npx with package", MCPServerConfig{Command: "/usr/bin/npx", Args: []string{"--registry", "https://registry.npmmirror.com", "-p", "pkg@1.0.0", "server"}},
+			"/usr/bin/npx", []string{"--yes", "--registry", "https://registry.npmmirror.com", "-p", "pkg@1.0.0", "-c", "true"}},
+		{"uvx", MCPServerConfig{Command: "uvx", Args: []string{"mcp-server-time", "--local-timezone=Asia/Shanghai"}},
+			"uvx", []string{"--from", "mcp-server-time", "python", "-c", ""}},
+		{"uvx with from", MCPServerConfig{Command: "uvx", Args: []string{"--python", "3.12", "--from", "git+https://example.com/repo", "server"}},
+			"uvx", []string{"--python", "3.12", "--from", "git+https://example.com/repo", "python", "-c", ""}},
+		{"custom", MCPServerConfig{Command: "node", Args: []string{"server.js"}, Warmup: &WarmupConfig{Command: "npm", Args: []string{"ci"}}},
+			"npm", []string{"ci"}},
+		{"disabled", MCPServerConfig{Command: "npx", Args: []string{"-y", "pkg"}, Warmup: &WarmupConfig{Disabled: true}}, "", nil},
+		{"other command", MCPServerConfig{Command: "python", Args: []string{"server.py"}}, "", nil},
+		{"npx without package", MCPServerConfig{Command: "npx", Args: []string{"-y"}}, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command, args, ok := tt.cfg.WarmupCommand()
+			if ok != (tt.command != "") || command != tt.command || !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("WarmupCommand() = %s %q %v, want %s %q", command, args, ok, tt.command, tt.args)
+			}
+		})
+	}
+}
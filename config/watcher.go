@@ -0,0 +1,87 @@
+package config
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval 默认检查配置文件变化的间隔
+const DefaultWatchInterval = 2 * time.Second
+
+// fileStamp 文件的修改时间和大小，文件不存在时为零值
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+func statFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size(), exists: true}
+}
+
+// FileWatcher 定期检查文件的修改时间和大小，发生变化（包括创建和删除）时回调
+// 使用轮询而不是 inotify，编辑器先写临时文件再重命名的方式同样可以检测到
+type FileWatcher struct {
+	interval time.Duration
+	paths    []string
+	stamps   map[string]fileStamp
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewFileWatcher 创建文件监听器，以创建时的文件状态为基准
+func NewFileWatcher(interval time.Duration, paths ...string) *FileWatcher {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	w := &FileWatcher{
+		interval: interval,
+		paths:    paths,
+		stamps:   make(map[string]fileStamp, len(paths)),
+		done:     make(chan struct{}),
+	}
+	for _, path := range paths {
+		w.stamps[path] = statFile(path)
+	}
+	return w
+}
+
+// Run 持续检查文件变化直到 Close，每次检查中发生变化的文件一起回调
+func (w *FileWatcher) Run(onChange func(changed []string)) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			if changed := w.check(); len(changed) > 0 {
+				onChange(changed)
+			}
+		}
+	}
+}
+
+// check 返回自上次检查以来发生变化的文件
+func (w *FileWatcher) check() []string {
+	var changed []string
+	for _, path := range w.paths {
+		stamp := statFile(path)
+		if stamp != w.stamps[path] {
+			w.stamps[path] = stamp
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+// Close 停止检查
+func (w *FileWatcher) Close() {
+	w.closeOnce.Do(func() { close(w.done) })
+}
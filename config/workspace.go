@@ -4,7 +4,42 @@ type WorkspaceConfig struct {
 	Servers map[string]MCPServerConfig `json:"servers"`
 	McpServiceMgrConfig
 	LogConfig
-	CommandBase string `json:"commandBase"`
+	WorkspaceOptions
+	CommandBase string             `json:"commandBase"`
+	EnvResolver EnvResolver        `json:"-"` // 解析服务环境变量中的密钥引用和 envFile
+	Bridge      BridgeListenConfig `json:"-"` // 本地桥接器的监听方式
+	Sessions    SessionLimits      `json:"-"` // 会话的回收和数量限制
+}
+
+// WorkspaceOptions 可按工作空间单独配置的选项
+type WorkspaceOptions struct {
+	Namespace     NamespaceConfig `json:"namespace"`
+	SessionPolicy SessionPolicy   `json:"sessionPolicy,omitempty"` // 部分服务不可用时如何创建会话，默认为 strict
+	Session       SessionOptions  `json:"session"`                 // 覆盖全局的会话回收和数量限制
+	// 网关响应 initialize 时是否将各服务的 instructions 按服务分段合并后返回给客户端
+	MergeInstructions bool `json:"mergeInstructions,omitempty"`
+}
+
+// SessionPolicy 创建会话时部分服务订阅失败的处理方式
+type SessionPolicy string
+
+const (
+	SessionPolicyBestEffort SessionPolicy = "best-effort" // 使用可用的服务创建会话，其余服务可用后在后台加入
+	SessionPolicyStrict     SessionPolicy = "strict"      // 任一运行中的服务订阅失败或没有可用的服务时创建会话失败
+)
+
+// GetSessionPolicy 获取创建会话的策略，未配置或无法识别时为 strict，与之前的行为一致
+func (o WorkspaceOptions) GetSessionPolicy() SessionPolicy {
+	if o.SessionPolicy == SessionPolicyBestEffort {
+		return SessionPolicyBestEffort
+	}
+	return SessionPolicyStrict
+}
+
+// NamespaceConfig 聚合会话中工具、资源、提示词对外名称的命名规则
+type NamespaceConfig struct {
+	Separator string                       `json:"separator,omitempty"` // MCP名称与原始名称之间的分隔符，默认为 "_"
+	Aliases   map[string]map[string]string `json:"aliases,omitempty"`   // 工具别名: mcpName -> toolName -> 对外名称
 }
 
 type LogConfig struct {
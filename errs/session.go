@@ -0,0 +1,7 @@
+package errs
+
+import "errors"
+
+var (
+	ErrSessionLimitExceeded = errors.New("session limit exceeded")
+)
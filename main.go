@@ -16,10 +16,16 @@ import (
 	"github.com/lucky-aeon/agentx/plugin-helper/config"
 	"github.com/lucky-aeon/agentx/plugin-helper/middleware_impl"
 	"github.com/lucky-aeon/agentx/plugin-helper/router"
+	"github.com/lucky-aeon/agentx/plugin-helper/sandbox"
 	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
 )
 
 func main() {
+	// 作为 stdio 服务的沙箱启动器运行时不做其他初始化
+	if sandbox.IsLauncher() {
+		sandbox.RunLauncher()
+	}
+
 	cfgDir := "./vm"
 	if _, err := os.Stat(cfgDir); os.IsNotExist(err) {
 		cfgDir = "."
@@ -28,10 +34,6 @@ func main() {
 	if err != nil {
 		panic(fmt.Errorf("failed to init config: %w", err))
 	}
-	defer func() {
-		cfg.SaveConfig()
-	}()
-
 	// Setup logging with zap
 	xlog.SetHeader(xlog.DefaultHeader)
 	err = xlog.SetupFileLogging(cfg.ConfigDirPath, "plugin-proxy.log")
@@ -61,11 +63,30 @@ func main() {
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
-	e.Use(middleware.KeyAuthWithConfig(middleware_impl.NewAuthMiddleware(cfg).GetKeyAuthConfig())) // API Key 鉴权
+	authMiddleware := middleware_impl.NewAuthMiddleware(cfg)
+	e.Use(middleware.KeyAuthWithConfig(authMiddleware.GetKeyAuthConfig())) // API Key 鉴权
 
 	// 初始化服务管理器
 	srvMgr := router.NewServerManager(*cfg, e)
 
+	// 热加载: 配置文件变化或收到 SIGHUP 时重新读取 config.json 和 mcp_servers.json
+	reloader := newConfigReloader(cfg, authMiddleware, srvMgr)
+	defer func() {
+		reloader.Config().SaveConfig()
+	}()
+	watcher := config.NewFileWatcher(config.DefaultWatchInterval, reloader.WatchPaths()...)
+	defer watcher.Close()
+	go watcher.Run(func(changed []string) {
+		reloader.Reload(fmt.Sprintf("files changed: %v", changed))
+	})
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reloader.Reload("SIGHUP")
+		}
+	}()
+
 	// 启动 pprof 调试服务器在单独端口
 	go func() {
 		mainLogger.Info("Starting pprof server on :6060")
@@ -2,6 +2,7 @@ package middleware_impl
 
 import (
 	"net/http"
+	"sync/atomic"
 
 	"strings"
 
@@ -13,15 +14,31 @@ import (
 )
 
 type AuthMiddleware struct {
-	config *config.Config
+	auth atomic.Pointer[config.AuthConfig] // 热加载配置时整体替换
 }
 
 func NewAuthMiddleware(cfg *config.Config) *AuthMiddleware {
-	return &AuthMiddleware{config: cfg}
+	m := &AuthMiddleware{}
+	m.UpdateConfig(cfg)
+	return m
+}
+
+// UpdateConfig 更新鉴权配置，重新加载 config.json 后调用
+func (m *AuthMiddleware) UpdateConfig(cfg *config.Config) {
+	auth := *cfg.GetAuthConfig()
+	m.auth.Store(&auth)
 }
 
 func (m *AuthMiddleware) GetKeyAuthConfig() middleware.KeyAuthConfig {
 	return middleware.KeyAuthConfig{
+		// 存活和就绪检查供负载均衡和编排系统调用，不需要鉴权，只返回状态；服务详情 /health/details 仍需鉴权
+		Skipper: func(c echo.Context) bool {
+			switch c.Request().URL.Path {
+			case "/health", "/ready":
+				return true
+			}
+			return false
+		},
 		KeyLookup: "header:Authorization:Bearer ,query:api_key,query:sessionId", // 从Header或Query获取
 		Validator: m.KeyAuthValidator,
 		ErrorHandler: func(err error, c echo.Context) error {
@@ -35,12 +52,13 @@ func (m *AuthMiddleware) KeyAuthValidator(key string, c echo.Context) (bool, err
 	realPath := c.Request().URL.Path
 	xl.Infof("Auth key: %s, path: %s", key, realPath)
 
-	if m.config.GetAuthConfig() == nil { // 如果没有配置，直接放行
+	authConfig := m.auth.Load()
+	if authConfig == nil { // 如果没有配置，直接放行
 		xl.Infof("Auth config not found")
 		return false, errs.ErrAuthConfigNotFound
 	}
-	xl.Infof("Auth key: %s, api key: %s", key, m.config.GetAuthConfig().GetApiKey())
-	if key == m.config.GetAuthConfig().GetApiKey() { // 验证API Key
+	xl.Infof("Auth key: %s, api key: %s", key, authConfig.GetApiKey())
+	if key == authConfig.GetApiKey() { // 验证API Key
 		return true, nil
 	}
 
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/middleware_impl"
+	"github.com/lucky-aeon/agentx/plugin-helper/router"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+// configReloader 重新读取 config.json 和 mcp_servers.json 并应用变更，由文件变化或 SIGHUP 触发
+type configReloader struct {
+	mu     sync.Mutex
+	cfg    *config.Config
+	auth   *middleware_impl.AuthMiddleware
+	srvMgr *router.ServerManager
+	logger xlog.Logger
+}
+
+func newConfigReloader(cfg *config.Config, auth *middleware_impl.AuthMiddleware, srvMgr *router.ServerManager) *configReloader {
+	return &configReloader{cfg: cfg, auth: auth, srvMgr: srvMgr, logger: xlog.NewLogger("RELOAD")}
+}
+
+// Config 返回当前生效的配置
+func (r *configReloader) Config() *config.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cfg
+}
+
+// WatchPaths 需要监听的配置文件
+func (r *configReloader) WatchPaths() []string {
+	cfg := r.Config()
+	return []string{filepath.Join(cfg.ConfigDirPath, config.CONFIG_PATH), cfg.GetMcpConfigPath()}
+}
+
+// Reload 重新加载配置，config.json 解析失败时保留当前配置
+// 鉴权和重试次数立即生效，绑定地址等需要重启才能生效
+func (r *configReloader) Reload(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logger.Infof("Reloading config (%s)", reason)
+
+	cfg, err := config.InitConfig(r.cfg.ConfigDirPath)
+	if err != nil {
+		r.logger.Errorf("Failed to reload config, keeping current config: %v", err)
+		return
+	}
+	if cfg.Bind != r.cfg.Bind {
+		r.logger.Warnf("Bind address changed from %s to %s, restart to apply", r.cfg.Bind, cfg.Bind)
+	}
+
+	r.auth.UpdateConfig(cfg)
+	if err := r.srvMgr.Reload(*cfg); err != nil {
+		r.logger.Errorf("Failed to reload %s: %v", config.MCP_CONFIG_PATH, err)
+	}
+	r.cfg = cfg
+	r.logger.Infof("Config reloaded")
+}
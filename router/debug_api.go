@@ -2,13 +2,13 @@ package router
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"regexp"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 
@@ -17,6 +17,9 @@ import (
 	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
 )
 
+// apiTestTimeout API 测试请求的超时时间
+const apiTestTimeout = 30 * time.Second
+
 // DebugRequest 调试请求结构
 type DebugRequest struct {
 	Message string `json:"message" validate:"required"`
@@ -33,20 +36,6 @@ type DebugResponse struct {
 	ResponseLog string                 `json:"response_log,omitempty"`
 }
 
-// LogEntry 日志条目结构
-type LogEntry struct {
-	Timestamp string `json:"timestamp"`
-	Level     string `json:"level"`
-	Message   string `json:"message"`
-}
-
-// ServiceLogsResponse 服务日志响应
-type ServiceLogsResponse struct {
-	ServiceName string     `json:"service_name"`
-	Logs        []LogEntry `json:"logs"`
-	TotalLines  int        `json:"total_lines"`
-}
-
 // APIEndpoint API端点信息
 type APIEndpoint struct {
 	Method      string         `json:"method"`
@@ -95,6 +84,9 @@ type APITestRequest struct {
 	Query       map[string]string      `json:"query,omitempty"`
 	Body        map[string]interface{} `json:"body,omitempty"`
 	ContentType string                 `json:"content_type,omitempty"`
+	// 指定服务时请求发送到该服务本地桥接器的地址而不是网关，通过服务的客户端访问，支持 unix socket 桥接器
+	Workspace string `json:"workspace,omitempty"`
+	Service   string `json:"service,omitempty"`
 }
 
 // APITestResponse API测试响应
@@ -228,6 +220,7 @@ func (m *ServerManager) handleGetServiceDebugInfo(c echo.Context) error {
 			"message_url": serviceInfo.URLs.MessageUrl,
 			"sse_url":     serviceInfo.URLs.SSEUrl,
 			"base_url":    serviceInfo.URLs.BaseURL,
+			"mcp_url":     serviceInfo.URLs.StreamableHTTPUrl,
 		},
 		"debug_commands": []string{
 			"GET /api/workspaces/" + workspace + "/services/" + serviceName + "/debug/info",
@@ -288,13 +281,15 @@ func (m *ServerManager) handleTestServiceConnection(c echo.Context) error {
 	}
 	tests = append(tests, statusTest)
 
-	// 测试2: 端口检查
+	// 测试2: 监听检查，桥接器监听 unix socket 时没有端口
 	portTest := map[string]interface{}{
 		"name":    "Port Availability Check",
-		"success": serviceInfo.Port > 0,
+		"success": serviceInfo.Port > 0 || serviceInfo.Socket != "",
 		"details": fmt.Sprintf("Service port: %d", serviceInfo.Port),
 	}
-	if serviceInfo.Port <= 0 {
+	if serviceInfo.Socket != "" {
+		portTest["details"] = fmt.Sprintf("Service socket: %s", serviceInfo.Socket)
+	} else if serviceInfo.Port <= 0 {
 		portTest["error"] = "Invalid port number"
 		portTest["success"] = false
 	}
@@ -347,104 +342,9 @@ func (m *ServerManager) handleTestServiceConnection(c echo.Context) error {
 	return c.JSON(http.StatusOK, testResult)
 }
 
-// handleGetServiceDebugLogs 获取服务日志（调试用）
+// handleGetServiceDebugLogs 获取服务日志（调试用），默认返回最后100行
 func (m *ServerManager) handleGetServiceDebugLogs(c echo.Context) error {
-	workspace := c.Param("workspace")
-	serviceName := c.Param("name")
-
-	if workspace == "" {
-		workspace = "default"
-	}
-
-	// 获取查询参数
-	limitStr := c.QueryParam("limit")
-	offsetStr := c.QueryParam("offset")
-
-	limit := 100 // 默认返回最后100行
-	offset := 0
-
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
-	}
-
-	if offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
-		}
-	}
-
-	logger := xlog.NewLogger("[ServiceLogs]")
-
-	nameArg := service.NameArg{
-		Workspace: workspace,
-		Server:    serviceName,
-	}
-
-	mcpService, err := m.mcpServiceMgr.GetMcpService(logger, nameArg)
-	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": fmt.Sprintf("Service not found: %v", err),
-		})
-	}
-
-	serviceInfo := mcpService.Info()
-
-	// 模拟日志读取（实际实现中应该从日志文件读取）
-	logs := []LogEntry{
-		{
-			Timestamp: serviceInfo.DeployedAt.Format("2006-01-02 15:04:05"),
-			Level:     "INFO",
-			Message:   fmt.Sprintf("Service %s deployed", serviceName),
-		},
-	}
-
-	if !serviceInfo.LastStartedAt.IsZero() {
-		logs = append(logs, LogEntry{
-			Timestamp: serviceInfo.LastStartedAt.Format("2006-01-02 15:04:05"),
-			Level:     "INFO",
-			Message:   fmt.Sprintf("Service %s started on port %d", serviceName, serviceInfo.Port),
-		})
-	}
-
-	if serviceInfo.LastError != "" {
-		logs = append(logs, LogEntry{
-			Timestamp: serviceInfo.LastStoppedAt.Format("2006-01-02 15:04:05"),
-			Level:     "ERROR",
-			Message:   serviceInfo.LastError,
-		})
-	}
-
-	if serviceInfo.Status == service.Running {
-		logs = append(logs, LogEntry{
-			Timestamp: "current",
-			Level:     "INFO",
-			Message:   fmt.Sprintf("Service %s is running (uptime: %.0f seconds)", serviceName, 0.0), // 需要实际计算uptime
-		})
-	}
-
-	// 应用分页
-	totalLines := len(logs)
-	start := offset
-	end := offset + limit
-
-	if start >= totalLines {
-		logs = []LogEntry{}
-	} else {
-		if end > totalLines {
-			end = totalLines
-		}
-		logs = logs[start:end]
-	}
-
-	response := ServiceLogsResponse{
-		ServiceName: serviceName,
-		Logs:        logs,
-		TotalLines:  totalLines,
-	}
-
-	return c.JSON(http.StatusOK, response)
+	return m.serveServiceLogs(c, xlog.NewLogger("[ServiceLogs]"), 100)
 }
 
 // 添加调试路由到ServerManager的初始化中
@@ -563,7 +463,7 @@ func (m *ServerManager) getAPIGroup(path string) string {
 		return "API接口"
 	} else if path == "/deploy" || path == "/delete" {
 		return "核心功能"
-	} else if path == "/sse" || path == "/message" {
+	} else if path == "/sse" || path == "/message" || path == "/mcp" {
 		return "通信接口"
 	} else if path == "/services" {
 		return "服务状态"
@@ -650,6 +550,9 @@ func (m *ServerManager) getAPIDescription(method, path, handler string) string {
 		"DELETE /delete":            "删除单个MCP服务",
 		"GET /sse":                  "全局SSE事件流",
 		"POST /message":             "发送全局消息",
+		"POST /mcp":                 "Streamable HTTP 发送请求",
+		"GET /mcp":                  "Streamable HTTP 事件流",
+		"DELETE /mcp":               "结束 Streamable HTTP 会话",
 		"GET /services":             "获取所有服务列表",
 		"GET /api/workspaces":       "获取所有工作空间",
 		"POST /api/workspaces":      "创建新工作空间",
@@ -795,6 +698,30 @@ func (m *ServerManager) getAPIExamples(method, path string) []APIExample {
 				},
 			},
 		})
+		examples = append(examples, APIExample{
+			Name:        "测试服务端点",
+			Description: "指定 service 时请求发送到该 stdio 服务本地桥接器的地址，支持 unix socket 桥接器，不支持远程服务",
+			Request: map[string]interface{}{
+				"method":    "POST",
+				"path":      "/fileSystem/mcp",
+				"workspace": "default",
+				"service":   "fileSystem",
+				"body": map[string]interface{}{
+					"jsonrpc": "2.0",
+					"id":      1,
+					"method":  "initialize",
+					"params": map[string]interface{}{
+						"protocolVersion": "2025-03-26",
+						"capabilities":    map[string]interface{}{},
+						"clientInfo":      map[string]string{"name": "api-test", "version": "1.0.0"},
+					},
+				},
+			},
+			Response: map[string]interface{}{
+				"success":     true,
+				"status_code": 200,
+			},
+		})
 	}
 
 	return examples
@@ -841,7 +768,7 @@ func (m *ServerManager) handleGetAPIGroups(c echo.Context) error {
 		},
 		"通信接口": map[string]interface{}{
 			"description": "处理消息和事件流的接口",
-			"endpoints":   []string{"/sse", "/message"},
+			"endpoints":   []string{"/sse", "/message", "/mcp"},
 		},
 	}
 
@@ -874,13 +801,47 @@ func (m *ServerManager) handleTestAPI(c echo.Context) error {
 
 	startTime := time.Now()
 
-	// 构建完整URL
+	// 构建完整URL，默认请求网关自身
 	scheme := "http"
 	if c.IsTLS() {
 		scheme = "https"
 	}
-	host := c.Request().Host
-	fullURL := fmt.Sprintf("%s://%s%s", scheme, host, req.Path)
+	baseURL := fmt.Sprintf("%s://%s", scheme, c.Request().Host)
+	client := &http.Client{}
+	if req.Service != "" {
+		workspace := req.Workspace
+		if workspace == "" {
+			workspace = service.DefaultWorkspace
+		}
+		mcpService, err := m.mcpServiceMgr.GetMcpService(logger, service.NameArg{Workspace: workspace, Server: req.Service})
+		if err != nil {
+			return c.JSON(http.StatusNotFound, APITestResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Service not found: %v", err),
+			})
+		}
+		if mcpService.IsIsolated() {
+			return c.JSON(http.StatusConflict, APITestResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Service %s uses per-session isolation and has no shared address", req.Service),
+			})
+		}
+		// 远程服务的地址是完整的端点，并且需要网关保存的凭据，不允许通过测试接口访问任意路径
+		if mcpService.IsRemote() {
+			return c.JSON(http.StatusBadRequest, APITestResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Service %s is a remote service, only local bridges can be tested", req.Service),
+			})
+		}
+		if baseURL = mcpService.GetUrl(); baseURL == "" {
+			return c.JSON(http.StatusConflict, APITestResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Service %s has no reachable address (status: %s)", req.Service, mcpService.GetStatus()),
+			})
+		}
+		client = mcpService.HTTPClient()
+	}
+	fullURL := strings.TrimSuffix(baseURL, "/") + req.Path
 
 	// 添加查询参数
 	if len(req.Query) > 0 {
@@ -906,8 +867,10 @@ func (m *ServerManager) handleTestAPI(c echo.Context) error {
 		requestBodyStr = string(bodyBytes)
 	}
 
-	// 创建HTTP请求
-	httpReq, err := http.NewRequest(req.Method, fullURL, bodyReader)
+	// 创建HTTP请求，服务的客户端没有超时，统一通过 context 限制
+	ctx, cancel := context.WithTimeout(c.Request().Context(), apiTestTimeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, fullURL, bodyReader)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, APITestResponse{
 			Success: false,
@@ -922,9 +885,11 @@ func (m *ServerManager) handleTestAPI(c echo.Context) error {
 		httpReq.Header.Set("Content-Type", "application/json")
 	}
 
-	// 复制原始请求的授权头部
-	if auth := c.Request().Header.Get("Authorization"); auth != "" {
-		httpReq.Header.Set("Authorization", auth)
+	// 请求网关时复制原始请求的授权头部，请求服务的桥接器时不把网关的 API Key 发给服务
+	if req.Service == "" {
+		if auth := c.Request().Header.Get("Authorization"); auth != "" {
+			httpReq.Header.Set("Authorization", auth)
+		}
 	}
 
 	// 添加自定义头部
@@ -941,10 +906,6 @@ func (m *ServerManager) handleTestAPI(c echo.Context) error {
 	}
 
 	// 发送请求
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
 	resp, err := client.Do(httpReq)
 	responseTime := time.Since(startTime)
 
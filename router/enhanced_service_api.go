@@ -10,7 +10,7 @@ import (
 	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
 )
 
-// handleDeployServiceToWorkspace 在指定工作空间部署服务
+// handleDeployServiceToWorkspace 在指定工作空间部署服务，async=true 时服务在后台启动，立即返回 202
 func (m *ServerManager) handleDeployServiceToWorkspace(c echo.Context) error {
 	xl := xlog.NewLogger("DEPLOY-TO-WORKSPACE")
 	workspaceID := c.Param("workspace")
@@ -21,15 +21,19 @@ func (m *ServerManager) handleDeployServiceToWorkspace(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
+	async := c.QueryParam("async") == "true"
 	for name, config := range req.MCPServers {
 		config.Workspace = workspaceID
-		if _, err := m.DeployServer(name, config); err != nil {
+		if _, err := m.deployServer(name, config, async); err != nil {
 			return c.JSON(http.StatusInternalServerError, map[string]string{
 				"error": err.Error(),
 			})
 		}
 	}
 
+	if async {
+		return c.JSON(http.StatusAccepted, map[string]string{"status": "starting"})
+	}
 	return c.JSON(http.StatusOK, map[string]string{"status": "success"})
 }
 
@@ -155,17 +159,10 @@ func (m *ServerManager) handleDeleteServiceFromWorkspace(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]string{"status": "success"})
 }
 
-// handleGetServiceLogs 获取服务日志 (预留接口)
+// handleGetServiceLogs 获取服务日志
+// 支持 tail、offset、limit、level 查询参数，follow=true 时通过 SSE 持续推送新日志
 func (m *ServerManager) handleGetServiceLogs(c echo.Context) error {
 	xl := xlog.NewLogger("GET-SERVICE-LOGS")
-	workspaceID := c.Param("workspace")
-	serviceName := c.Param("name")
-	xl.Infof("Get logs for service %s in workspace: %s", serviceName, workspaceID)
-
-	// TODO: 实现日志获取功能
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"logs": []string{
-			"Log functionality will be implemented in the future",
-		},
-	})
+	xl.Infof("Get logs for service %s in workspace: %s", c.Param("name"), c.Param("workspace"))
+	return m.serveServiceLogs(c, xl, 200)
 }
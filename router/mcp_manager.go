@@ -27,31 +27,85 @@ func (m *ServerManager) handleGetAllServices(c echo.Context) error {
 	return c.JSON(http.StatusOK, serviceInfos)
 }
 
-// DeployServer 部署单个服务
+// DeployServer 部署单个服务，等待服务启动完成
 func (m *ServerManager) DeployServer(name string, config config.MCPServerConfig) (service.AddMcpServiceResult, error) {
+	return m.deployServer(name, config, false)
+}
+
+// DeployServerAsync 部署单个服务，服务在后台启动
+func (m *ServerManager) DeployServerAsync(name string, config config.MCPServerConfig) (service.AddMcpServiceResult, error) {
+	return m.deployServer(name, config, true)
+}
+
+func (m *ServerManager) deployServer(name string, config config.MCPServerConfig, async bool) (service.AddMcpServiceResult, error) {
 	m.Lock()
 	defer m.Unlock()
 
 	logger := xlog.NewLogger("DEPLOY")
 
-	if config.Command == "" && config.URL == "" {
-		return "", fmt.Errorf("服务配置必须包含 URL 或 Command")
-	}
-
-	if config.Command != "" && config.URL != "" {
-		return "", fmt.Errorf("服务配置不能同时包含 URL 和 Command")
+	if err := validateServerConfig(config); err != nil {
+		return "", err
 	}
 
 	if config.Workspace == "" {
 		config.Workspace = service.DefaultWorkspace
 	}
-	return m.mcpServiceMgr.DeployServer(logger, service.NameArg{
+	nameArg := service.NameArg{
 		Server:    name,
 		Workspace: config.Workspace,
-	}, config)
+	}
+	if async {
+		return m.mcpServiceMgr.DeployServerAsync(logger, nameArg, config)
+	}
+	return m.mcpServiceMgr.DeployServer(logger, nameArg, config)
 }
 
-// handleDeploy 处理部署请求
+// validateServerConfig 校验服务配置
+func validateServerConfig(config config.MCPServerConfig) error {
+	if config.Command == "" && config.URL == "" {
+		return fmt.Errorf("服务配置必须包含 URL 或 Command")
+	}
+
+	if config.Command != "" && config.URL != "" {
+		return fmt.Errorf("服务配置不能同时包含 URL 和 Command")
+	}
+
+	if !config.Transport.IsValid() {
+		return fmt.Errorf("不支持的传输方式: %s", config.Transport)
+	}
+
+	if !config.Type.IsValid() {
+		return fmt.Errorf("不支持的远程传输协议: %s", config.Type)
+	}
+
+	if err := config.HealthCheck.Validate(); err != nil {
+		return err
+	}
+
+	if config.Sandbox.Enabled() && config.Command == "" {
+		return fmt.Errorf("只有 Command 类型的服务支持 sandbox")
+	}
+	if err := config.Sandbox.Validate(); err != nil {
+		return err
+	}
+
+	if config.Isolation.Isolated() && config.Command == "" {
+		return fmt.Errorf("只有 Command 类型的服务支持 %s 隔离", config.Isolation.GetMode())
+	}
+	if err := config.Isolation.Validate(); err != nil {
+		return err
+	}
+
+	if config.Warmup != nil && config.Command == "" {
+		return fmt.Errorf("只有 Command 类型的服务支持 warmup")
+	}
+	if err := config.Warmup.Validate(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// handleDeploy 处理部署请求，async=true 时服务在后台启动，立即返回 202
 func (m *ServerManager) handleDeploy(c echo.Context) error {
 	xl := xlog.NewLogger("DEPLOY-REQ")
 	xl.Infof("Deploy request: %v", c.Request().Body)
@@ -59,8 +113,9 @@ func (m *ServerManager) handleDeploy(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
-	xl.Infof("Deploy request: %v", req)
+	xl.Infof("Deploy request: %d servers", len(req.MCPServers))
 	workspace := utils.GetWorkspace(c, service.DefaultWorkspace)
+	async := c.QueryParam("async") == "true"
 
 	// 初始化响应结构
 	response := types.DeployResponse{
@@ -73,17 +128,20 @@ func (m *ServerManager) handleDeploy(c echo.Context) error {
 
 	// 部署每个服务
 	for name, config := range req.MCPServers {
-		xl.Infof("Deploying %s: %v", name, config)
+		xl.Infof("Deploying %s: %v", name, config.Redacted())
 		if workspace != "" {
 			config.Workspace = workspace
 		} else if config.Workspace == "" {
 			config.Workspace = service.DefaultWorkspace
 		}
 
-		result, err := m.DeployServer(name, config)
+		result, err := m.deployServer(name, config, async)
 		serviceResult := types.ServiceDeployResult{
 			Name: name,
 		}
+		if async && err == nil {
+			serviceResult.StatusURL = serviceStatusURL(config.Workspace, name)
+		}
 
 		if err != nil {
 			xl.Errorf("Failed to deploy %s: %v", name, err)
@@ -98,6 +156,9 @@ func (m *ServerManager) handleDeploy(c echo.Context) error {
 			case service.AddMcpServiceResultDeployed:
 				serviceResult.Status = types.ServiceDeployStatusDeployed
 				serviceResult.Message = "服务部署成功"
+				if async {
+					serviceResult.Message = "服务已部署，正在后台启动"
+				}
 				response.Summary.Deployed++
 			case service.AddMcpServiceResultExisted:
 				serviceResult.Status = types.ServiceDeployStatusExisted
@@ -106,6 +167,9 @@ func (m *ServerManager) handleDeploy(c echo.Context) error {
 			case service.AddMcpServiceResultReplaced:
 				serviceResult.Status = types.ServiceDeployStatusReplaced
 				serviceResult.Message = "服务已替换（原服务已停止或失败）"
+				if async {
+					serviceResult.Message = "服务已替换（原服务已停止或失败），正在后台启动"
+				}
 				response.Summary.Replaced++
 			}
 		}
@@ -130,6 +194,8 @@ func (m *ServerManager) handleDeploy(c echo.Context) error {
 	statusCode := http.StatusOK
 	if response.Summary.Failed > 0 {
 		statusCode = http.StatusPartialContent // 206表示部分成功
+	} else if async {
+		statusCode = http.StatusAccepted // 202表示服务正在后台启动
 	}
 
 	return c.JSON(statusCode, response)
@@ -167,5 +233,65 @@ func (m *ServerManager) handleGetServiceHealth(c echo.Context) error {
 	}
 
 	health := mcpService.GetHealthStatus()
+	if !mcpService.Health().Live {
+		return c.JSON(http.StatusServiceUnavailable, health)
+	}
 	return c.JSON(http.StatusOK, health)
 }
+
+// handleGetServiceReady 服务是否就绪，未就绪时返回 503
+func (m *ServerManager) handleGetServiceReady(c echo.Context) error {
+	xl := xlog.NewLogger("GET-SERVICE-READY")
+	serviceName := c.Param("name")
+	workspace := utils.GetWorkspace(c, service.DefaultWorkspace)
+
+	mcpService, err := m.mcpServiceMgr.GetMcpService(xl, service.NameArg{
+		Server:    serviceName,
+		Workspace: workspace,
+	})
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": fmt.Sprintf("Service %s not found: %v", serviceName, err)})
+	}
+
+	health := mcpService.Health()
+	if !health.Ready {
+		return c.JSON(http.StatusServiceUnavailable, health)
+	}
+	return c.JSON(http.StatusOK, health)
+}
+
+// handleGatewayHealth 网关存活检查，只要能处理请求就返回 200
+// 不需要鉴权，只返回状态，各服务的详情通过需要鉴权的 /health/details 获取
+func (m *ServerManager) handleGatewayHealth(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleGatewayReady 网关就绪检查，存在未就绪的服务时返回 503，手动停止的服务不计入
+// 不需要鉴权，只返回是否就绪
+func (m *ServerManager) handleGatewayReady(c echo.Context) error {
+	xl := xlog.NewLogger("GATEWAY-READY")
+	ready := m.mcpServiceMgr.CheckHealth(xl).Ready
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	return c.JSON(status, map[string]bool{"ready": ready})
+}
+
+// handleGatewayHealthDetails 网关及各服务的健康详情，not_ready 列出未就绪的服务，需要鉴权
+func (m *ServerManager) handleGatewayHealthDetails(c echo.Context) error {
+	xl := xlog.NewLogger("GATEWAY-HEALTH")
+	health := m.mcpServiceMgr.CheckHealth(xl)
+	notReady := make([]string, 0)
+	for _, svc := range health.Services {
+		if svc.Status != service.Stopped && !svc.Ready {
+			notReady = append(notReady, svc.Workspace+"/"+svc.Name)
+		}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":    "ok",
+		"ready":     health.Ready,
+		"not_ready": notReady,
+		"health":    health,
+	})
+}
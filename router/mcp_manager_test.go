@@ -2,12 +2,17 @@ package router
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/lucky-aeon/agentx/plugin-helper/config"
@@ -28,6 +33,11 @@ func (m *MockServiceManager) DeployServer(logger xlog.Logger, name service.NameA
 	return args.Get(0).(service.AddMcpServiceResult), args.Error(1)
 }
 
+func (m *MockServiceManager) DeployServerAsync(logger xlog.Logger, name service.NameArg, config config.MCPServerConfig) (service.AddMcpServiceResult, error) {
+	args := m.Called(logger, name, config)
+	return args.Get(0).(service.AddMcpServiceResult), args.Error(1)
+}
+
 func (m *MockServiceManager) StopServer(logger xlog.Logger, name service.NameArg) {
 	m.Called(logger, name)
 }
@@ -76,6 +86,39 @@ func (m *MockServiceManager) DeleteServer(logger xlog.Logger, name service.NameA
 	return args.Error(0)
 }
 
+func (m *MockServiceManager) PlanWorkspace(logger xlog.Logger, name service.NameArg, servers map[string]config.MCPServerConfig) service.WorkspacePlan {
+	args := m.Called(logger, name, servers)
+	return args.Get(0).(service.WorkspacePlan)
+}
+
+func (m *MockServiceManager) ApplyWorkspace(logger xlog.Logger, name service.NameArg, servers map[string]config.MCPServerConfig) service.WorkspacePlan {
+	args := m.Called(logger, name, servers)
+	return args.Get(0).(service.WorkspacePlan)
+}
+
+func (m *MockServiceManager) CheckHealth(logger xlog.Logger) service.GatewayHealth {
+	args := m.Called(logger)
+	return args.Get(0).(service.GatewayHealth)
+}
+
+func (m *MockServiceManager) GetSessionMetrics(logger xlog.Logger) map[string]service.SessionMetrics {
+	args := m.Called(logger)
+	return args.Get(0).(map[string]service.SessionMetrics)
+}
+
+func (m *MockServiceManager) UpdateConfig(logger xlog.Logger, cfg config.Config) {
+	m.Called(logger, cfg)
+}
+
+func (m *MockServiceManager) StartRestored(logger xlog.Logger) {
+	m.Called(logger)
+}
+
+func (m *MockServiceManager) DeleteWorkspace(logger xlog.Logger, name service.NameArg) error {
+	args := m.Called(logger, name)
+	return args.Error(0)
+}
+
 func (m *MockServiceManager) Close() {
 	m.Called()
 }
@@ -85,9 +128,9 @@ type MockPortManager struct {
 	mock.Mock
 }
 
-func (m *MockPortManager) GetNextAvailablePort() int {
-	args := m.Called()
-	return args.Int(0)
+func (m *MockPortManager) AllocatePort(owner string) (int, error) {
+	args := m.Called(owner)
+	return args.Int(0), args.Error(1)
 }
 
 func (m *MockPortManager) ReleasePort(port int) {
@@ -182,6 +225,42 @@ func TestHandleDeploy_Success_NewDeployment(t *testing.T) {
 	mockServiceMgr.AssertExpectations(t)
 }
 
+func TestHandleDeploy_Async(t *testing.T) {
+	e := echo.New()
+	serverMgr, mockServiceMgr := createTestServerManager()
+
+	deployReq := types.DeployRequest{
+		MCPServers: map[string]config.MCPServerConfig{
+			"slow-service": {
+				Command: "npx",
+				Args:    []string{"-y", "@modelcontextprotocol/server-everything"},
+			},
+		},
+	}
+	mockServiceMgr.On("DeployServerAsync", mock.AnythingOfType("*xlog.zapLogger"), service.NameArg{
+		Server:    "slow-service",
+		Workspace: "team a",
+	}, mock.Anything).Return(service.AddMcpServiceResultDeployed, nil)
+
+	reqBody, _ := json.Marshal(deployReq)
+	req := httptest.NewRequest(http.MethodPost, "/deploy?async=true", bytes.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Workspace-Id", "team a")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := serverMgr.handleDeploy(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	var response types.DeployResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, types.ServiceDeployStatusDeployed, response.Results["slow-service"].Status)
+	assert.Equal(t, "/api/workspaces/team%20a/services/slow-service/status?follow=true", response.Results["slow-service"].StatusURL)
+	mockServiceMgr.AssertExpectations(t)
+	mockServiceMgr.AssertNotCalled(t, "DeployServer", mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestHandleDeploy_MixedResults(t *testing.T) {
 	// 设置 Echo
 	e := echo.New()
@@ -402,3 +481,292 @@ func TestHandleDeploy_EmptyRequest(t *testing.T) {
 	assert.Equal(t, 0, response.Summary.Total)
 	assert.Equal(t, 0, len(response.Results))
 }
+
+func TestHandleApplyWorkspace_DryRun(t *testing.T) {
+	e := echo.New()
+	serverMgr, mockServiceMgr := createTestServerManager()
+
+	manifest := types.WorkspaceManifest{
+		MCPServers: map[string]config.MCPServerConfig{
+			"time": {Command: "uvx", Args: []string{"mcp-server-time"}},
+		},
+	}
+	plan := service.WorkspacePlan{
+		Workspace: "ws",
+		Items:     []service.PlanItem{{Name: "time", Action: service.PlanActionAdd}},
+	}
+	mockServiceMgr.On("PlanWorkspace", mock.Anything, service.NameArg{Workspace: "ws"}, manifest.MCPServers).Return(plan)
+
+	reqBody, _ := json.Marshal(manifest)
+	req := httptest.NewRequest(http.MethodPost, "/api/workspaces/ws/apply?dryRun=true", bytes.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("workspace")
+	c.SetParamValues("ws")
+
+	err := serverMgr.handleApplyWorkspace(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response ApplyWorkspaceResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.True(t, response.DryRun)
+	assert.Equal(t, plan.Items, response.Items)
+	mockServiceMgr.AssertNotCalled(t, "ApplyWorkspace", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHandleApplyWorkspace_InvalidManifest(t *testing.T) {
+	e := echo.New()
+	serverMgr, mockServiceMgr := createTestServerManager()
+
+	reqBody := `{"mcpServers": {"ok": {"command": "uvx"}, "bad": {"command": "uvx", "url": "http://localhost:3000"}}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/workspaces/ws/apply", strings.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("workspace")
+	c.SetParamValues("ws")
+
+	err := serverMgr.handleApplyWorkspace(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "bad")
+	mockServiceMgr.AssertNotCalled(t, "ApplyWorkspace", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHandleGatewayReady(t *testing.T) {
+	e := echo.New()
+	serverMgr, mockServiceMgr := createTestServerManager()
+
+	mockServiceMgr.On("CheckHealth", mock.Anything).Return(service.GatewayHealth{
+		Ready: false,
+		Services: []service.WorkspaceServiceHealth{
+			{Workspace: "default", ServiceHealth: service.ServiceHealth{Name: "time", Status: service.Running, Live: true, Ready: true}},
+			{Workspace: "default", ServiceHealth: service.ServiceHealth{Name: "git", Status: service.Failed}},
+			{Workspace: "ws", ServiceHealth: service.ServiceHealth{Name: "fs", Status: service.Stopped}},
+		},
+	})
+
+	// 不需要鉴权的就绪检查只返回是否就绪
+	req := httptest.NewRequest(http.MethodGet, "/ready", nil)
+	rec := httptest.NewRecorder()
+	err := serverMgr.handleGatewayReady(e.NewContext(req, rec))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.JSONEq(t, `{"ready": false}`, rec.Body.String())
+
+	// 存活检查不受服务状态影响
+	rec = httptest.NewRecorder()
+	err = serverMgr.handleGatewayHealth(e.NewContext(httptest.NewRequest(http.MethodGet, "/health", nil), rec))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status": "ok"}`, rec.Body.String())
+
+	// 详情中列出未就绪的服务，手动停止的服务不计入
+	rec = httptest.NewRecorder()
+	err = serverMgr.handleGatewayHealthDetails(e.NewContext(httptest.NewRequest(http.MethodGet, "/health/details", nil), rec))
+	assert.NoError(t, err)
+	var response struct {
+		Ready    bool     `json:"ready"`
+		NotReady []string `json:"not_ready"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.False(t, response.Ready)
+	assert.Equal(t, []string{"default/git"}, response.NotReady)
+}
+
+func TestHandleGlobalMCPGet_Resume(t *testing.T) {
+	e := echo.New()
+	serverMgr, mockServiceMgr := createTestServerManager()
+	session := service.NewSession("resume")
+	defer session.Close()
+	session.SetTransport(service.SessionTransportStreamableHTTP)
+	mockServiceMgr.On("GetProxySession", mock.Anything, service.NameArg{Workspace: "default", Session: "resume"}).Return(session, true)
+
+	// 断线期间的事件
+	session.SendEvent(service.SessionMsg{Event: "message", Data: `{"n":1}`})
+	session.SendEvent(service.SessionMsg{Event: "message", Data: `{"n":2}`})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/mcp", nil).WithContext(ctx)
+	req.Header.Set("Mcp-Session-Id", "resume")
+	req.Header.Set("Last-Event-ID", "1")
+	rec := httptest.NewRecorder()
+	done := make(chan error, 1)
+	go func() { done <- serverMgr.handleGlobalMCPGet(e.NewContext(req, rec)) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+
+	body := rec.Body.String()
+	assert.Contains(t, body, "id: 2\nevent: message\ndata: {\"n\":2}\n\n")
+	assert.NotContains(t, body, `{"n":1}`)
+}
+
+func TestHandleGlobalMCPPost_Notification(t *testing.T) {
+	e := echo.New()
+	serverMgr, mockServiceMgr := createTestServerManager()
+	session := service.NewSession("notify")
+	defer session.Close()
+	mockServiceMgr.On("GetProxySession", mock.Anything, service.NameArg{Workspace: "default", Session: "notify"}).Return(session, true)
+
+	// 通知交给会话处理后返回 202，不产生响应
+	for _, body := range []string{
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":1}}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+		req.Header.Set("Mcp-Session-Id", "notify")
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serverMgr.handleGlobalMCPPost(e.NewContext(req, rec)))
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+	}
+
+	// 格式错误的通知返回 400
+	req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":"bad"}`))
+	req.Header.Set("Mcp-Session-Id", "notify")
+	rec := httptest.NewRecorder()
+	assert.NoError(t, serverMgr.handleGlobalMCPPost(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestServerManager_ReloadChangedServers(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Config{ConfigDirPath: dir}
+	serverMgr, mockServiceMgr := createTestServerManager()
+	serverMgr.fileServers = map[string]config.MCPServerConfig{
+		"same":    {Command: "uvx", Args: []string{"same"}, Workspace: service.DefaultWorkspace},
+		"changed": {Command: "uvx", Args: []string{"old"}, Workspace: service.DefaultWorkspace},
+		"removed": {Command: "uvx", Workspace: service.DefaultWorkspace},
+	}
+	manifest := `{
+		"same": {"command": "uvx", "args": ["same"]},
+		"changed": {"command": "uvx", "args": ["new"]},
+		"added": {"command": "uvx", "args": ["added"]}
+	}`
+	if err := os.WriteFile(cfg.GetMcpConfigPath(), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	existing := service.NewMcpService("existing", config.MCPServerConfig{}, nil)
+	for _, name := range []string{"changed", "removed"} {
+		nameArg := service.NameArg{Workspace: service.DefaultWorkspace, Server: name}
+		mockServiceMgr.On("GetMcpService", mock.Anything, nameArg).Return(existing, nil)
+		mockServiceMgr.On("DeleteServer", mock.Anything, nameArg).Return(nil)
+	}
+	mockServiceMgr.On("GetMcpService", mock.Anything, service.NameArg{Workspace: service.DefaultWorkspace, Server: "added"}).
+		Return(existing, fmt.Errorf("not found"))
+	for _, name := range []string{"changed", "added"} {
+		mockServiceMgr.On("DeployServer", mock.Anything, service.NameArg{Workspace: service.DefaultWorkspace, Server: name}, mock.Anything).
+			Return(service.AddMcpServiceResultDeployed, nil)
+	}
+
+	mockServiceMgr.On("UpdateConfig", mock.Anything, cfg).Return()
+
+	assert.NoError(t, serverMgr.Reload(cfg))
+
+	mockServiceMgr.AssertExpectations(t)
+	mockServiceMgr.AssertNotCalled(t, "DeployServer", mock.Anything, service.NameArg{Workspace: service.DefaultWorkspace, Server: "same"}, mock.Anything)
+	assert.Len(t, serverMgr.fileServers, 3)
+	assert.Equal(t, []string{"new"}, serverMgr.fileServers["changed"].Args)
+
+	// 文件内容不变时只更新配置，不部署或删除服务
+	mockServiceMgr.Calls = nil
+	assert.NoError(t, serverMgr.Reload(cfg))
+	if assert.Len(t, mockServiceMgr.Calls, 1) {
+		assert.Equal(t, "UpdateConfig", mockServiceMgr.Calls[0].Method)
+	}
+}
+
+// unixSocketService 只实现 API 测试用到的方法，通过 unix socket 访问
+type unixSocketService struct {
+	service.ExportMcpService
+	client *http.Client
+}
+
+func (s *unixSocketService) GetUrl() string           { return "http://unix" }
+func (s *unixSocketService) IsIsolated() bool         { return false }
+func (s *unixSocketService) IsRemote() bool           { return false }
+func (s *unixSocketService) HTTPClient() *http.Client { return s.client }
+
+func TestHandleTestAPI_ServiceOverUnixSocket(t *testing.T) {
+	e := echo.New()
+	serverMgr, mockServiceMgr := createTestServerManager()
+
+	socketPath := filepath.Join(t.TempDir(), "svc.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to listen on unix socket: %v", err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 请求服务的桥接器时不转发网关的 API Key
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"path":          r.URL.Path,
+			"authorization": r.Header.Get("Authorization"),
+		})
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	svc := &unixSocketService{client: &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		},
+	}}}
+	mockServiceMgr.On("GetMcpService", mock.Anything, service.NameArg{Workspace: service.DefaultWorkspace, Server: "svc"}).Return(svc, nil)
+
+	body := `{"method": "GET", "path": "/svc/sse", "service": "svc"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/debug/apis/test", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer gateway-key")
+	rec := httptest.NewRecorder()
+	assert.NoError(t, serverMgr.handleTestAPI(e.NewContext(req, rec)))
+
+	var response APITestResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.True(t, response.Success, response.Error)
+	assert.Equal(t, map[string]interface{}{"path": "/svc/sse", "authorization": ""}, response.Response)
+}
+
+// remoteService 远程服务，测试接口不允许访问
+type remoteService struct {
+	service.ExportMcpService
+}
+
+func (s *remoteService) IsIsolated() bool { return false }
+func (s *remoteService) IsRemote() bool   { return true }
+
+func TestHandleTestAPI_RemoteServiceRejected(t *testing.T) {
+	e := echo.New()
+	serverMgr, mockServiceMgr := createTestServerManager()
+	mockServiceMgr.On("GetMcpService", mock.Anything, service.NameArg{Workspace: service.DefaultWorkspace, Server: "remote"}).Return(&remoteService{}, nil)
+
+	body := `{"method": "GET", "path": "/admin", "service": "remote"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/debug/apis/test", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	assert.NoError(t, serverMgr.handleTestAPI(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+// isolatedService 按会话隔离的服务，没有共享的地址
+type isolatedService struct {
+	service.ExportMcpService
+}
+
+func (s *isolatedService) IsIsolated() bool { return true }
+
+func TestProxyHandler_IsolatedService(t *testing.T) {
+	e := echo.New()
+	serverMgr, mockServiceMgr := createTestServerManager()
+	mockServiceMgr.On("GetMcpService", mock.Anything, service.NameArg{Workspace: service.DefaultWorkspace, Server: "stateful"}).Return(&isolatedService{}, nil)
+
+	for _, path := range []string{"/stateful/sse", "/stateful/message", "/stateful/mcp"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, serverMgr.proxyHandler()(e.NewContext(req, rec)))
+		assert.Equal(t, http.StatusConflict, rec.Code, path)
+		assert.Contains(t, rec.Body.String(), "per-session isolation")
+	}
+}
@@ -43,6 +43,9 @@ func (m *ServerManager) proxyHandler() echo.HandlerFunc {
 		if err != nil {
 			return c.String(http.StatusNotFound, "Service not found")
 		}
+		if instance.IsIsolated() {
+			return c.String(http.StatusConflict, fmt.Sprintf("Service %s uses per-session isolation and has no shared address, connect through a gateway session", serviceName))
+		}
 
 		// 获取原始请求的查询参数
 		originalQuery := c.Request().URL.RawQuery
@@ -57,6 +60,12 @@ func (m *ServerManager) proxyHandler() echo.HandlerFunc {
 			// 对于message，使用完整的Message URL
 			baseURL = instance.GetMessageUrl()
 			c.Logger().Infof("Message URL: %s", baseURL)
+		case "mcp":
+			// 对于mcp，使用 Streamable HTTP URL
+			baseURL = instance.GetStreamableHTTPUrl()
+			if baseURL == "" {
+				return c.String(http.StatusNotFound, "Streamable HTTP not enabled for service")
+			}
 		default:
 			// 对于其他路由，使用基础URL加上完整路径
 			if url := instance.GetUrl(); url != "" {
@@ -94,14 +103,13 @@ func (m *ServerManager) proxyHandler() echo.HandlerFunc {
 		for k, v := range c.Request().Header {
 			req.Header[k] = v
 		}
-
-		// 发送请求
-		client := &http.Client{
-			Transport: &http.Transport{
-				ForceAttemptHTTP2: false,
-			},
+		// 远程服务配置的请求头优先于客户端请求头
+		for k, v := range instance.GetUpstreamHeaders() {
+			req.Header.Set(k, v)
 		}
-		resp, err := client.Do(req)
+
+		// 发送请求，本地桥接器可能监听在 unix socket 上，使用服务提供的客户端
+		resp, err := instance.HTTPClient().Do(req)
 		if err != nil {
 			return err
 		}
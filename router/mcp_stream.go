@@ -0,0 +1,205 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/errs"
+	"github.com/lucky-aeon/agentx/plugin-helper/service"
+	"github.com/lucky-aeon/agentx/plugin-helper/utils"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// streamableRequestTimeout 等待聚合会话返回响应的最长时间
+const streamableRequestTimeout = 60 * time.Second
+
+// handleGlobalMCPPost 全局 Streamable HTTP 端点，处理客户端发送的 JSON-RPC 消息
+func (m *ServerManager) handleGlobalMCPPost(c echo.Context) error {
+	xl := xlog.NewLogger("GLOBAL-MCP")
+	workspace := utils.GetWorkspace(c, service.DefaultWorkspace)
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return writeJSONRPCError(c, http.StatusBadRequest, mcp.NewRequestId(nil), mcp.PARSE_ERROR, fmt.Sprintf("read request body error: %v", err))
+	}
+
+	var request mcp.JSONRPCRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return writeJSONRPCError(c, http.StatusBadRequest, mcp.NewRequestId(nil), mcp.PARSE_ERROR, "request body is not valid json-rpc message")
+	}
+	isInitialize := request.Method == string(mcp.MethodInitialize)
+
+	// 获取或创建session
+	var session *service.Session
+	if isInitialize {
+		session, err = m.mcpServiceMgr.CreateProxySession(xl, service.NameArg{
+			Workspace: workspace,
+			ApiKey:    utils.GetApiKey(c),
+		})
+		if errors.Is(err, errs.ErrSessionLimitExceeded) {
+			return writeJSONRPCError(c, http.StatusTooManyRequests, request.ID, mcp.INTERNAL_ERROR, err.Error())
+		}
+		if err != nil {
+			return writeJSONRPCError(c, http.StatusInternalServerError, request.ID, mcp.INTERNAL_ERROR, err.Error())
+		}
+		session.SetTransport(service.SessionTransportStreamableHTTP)
+		xl.Infof("Created new streamable http session: %s", session.Id)
+	} else {
+		sessionId, err := utils.GetMcpSessionId(c)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+		var exists bool
+		session, exists = m.mcpServiceMgr.GetProxySession(xl, service.NameArg{
+			Workspace: workspace,
+			Session:   sessionId,
+		})
+		if !exists {
+			return c.String(http.StatusNotFound, "session not found")
+		}
+	}
+
+	// 通知和客户端响应不需要返回结果，交给会话处理后返回 202
+	if request.Method == "" || request.ID.IsNil() {
+		if err := session.SendMessage(xl, body); err != nil {
+			return writeJSONRPCError(c, http.StatusBadRequest, request.ID, mcp.INVALID_REQUEST, err.Error())
+		}
+		return c.NoContent(http.StatusAccepted)
+	}
+
+	// initialize 失败时客户端拿不到会话ID，关闭创建的会话
+	initialized := false
+	if isInitialize {
+		defer func() {
+			if !initialized {
+				xl.Infof("Initialize failed, closing session: %s", session.Id)
+				m.mcpServiceMgr.CloseProxySession(xl, service.NameArg{
+					Workspace: workspace,
+					Session:   session.Id,
+				})
+			}
+		}()
+	}
+
+	respChan, cancel := session.WaitResponse(request.ID)
+	defer cancel()
+
+	if err := session.SendMessage(xl, body); err != nil {
+		return writeJSONRPCError(c, http.StatusOK, request.ID, mcp.INVALID_REQUEST, err.Error())
+	}
+
+	timeout := time.NewTimer(streamableRequestTimeout)
+	defer timeout.Stop()
+
+	select {
+	case <-c.Request().Context().Done():
+		xl.Infof("Client closed connection before response, sessionId: %s", session.Id)
+		return nil
+	case <-timeout.C:
+		return writeJSONRPCError(c, http.StatusOK, request.ID, mcp.INTERNAL_ERROR, "timeout waiting for response")
+	case event := <-respChan:
+		if isInitialize {
+			if isJSONRPCError(event.Data) {
+				return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, []byte(event.Data))
+			}
+			initialized = true
+			c.Response().Header().Set(utils.McpSessionIdHeader, session.Id)
+		}
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, []byte(event.Data))
+	}
+}
+
+// isJSONRPCError 判断消息是否是 JSON-RPC 错误响应
+func isJSONRPCError(data string) bool {
+	var response struct {
+		Error json.RawMessage `json:"error"`
+	}
+	return json.Unmarshal([]byte(data), &response) == nil && len(response.Error) > 0
+}
+
+// handleGlobalMCPGet 建立服务端事件流，用于推送服务端发起的消息
+func (m *ServerManager) handleGlobalMCPGet(c echo.Context) error {
+	xl := xlog.NewLogger("GLOBAL-MCP-STREAM")
+	workspace := utils.GetWorkspace(c, service.DefaultWorkspace)
+	sessionId, err := utils.GetMcpSessionId(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	session, exists := m.mcpServiceMgr.GetProxySession(xl, service.NameArg{
+		Workspace: workspace,
+		Session:   sessionId,
+	})
+	if !exists {
+		return c.String(http.StatusNotFound, "session not found")
+	}
+
+	c.Response().Header().Set("Content-Type", "text/event-stream")
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	c.Response().Header().Set("Connection", "keep-alive")
+	c.Response().Header().Set(utils.McpSessionIdHeader, session.Id)
+	c.Response().WriteHeader(http.StatusOK)
+	w := c.Response().Writer
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return c.String(http.StatusInternalServerError, "flusher not supported")
+	}
+	flusher.Flush()
+
+	// 断线重连时先重放错过的事件
+	replay, eventChan, closeChan := sessionEventChan(xl, c, session)
+	defer closeChan()
+	for _, event := range replay {
+		writeSessionEvent(w, flusher, event)
+	}
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			xl.Infof("Client closed stream, sessionId: %s", sessionId)
+			return nil
+		case event, ok := <-eventChan:
+			if !ok {
+				return nil
+			}
+			writeSessionEvent(w, flusher, event)
+		}
+	}
+}
+
+// handleGlobalMCPDelete 结束会话
+func (m *ServerManager) handleGlobalMCPDelete(c echo.Context) error {
+	xl := xlog.NewLogger("GLOBAL-MCP-DELETE")
+	workspace := utils.GetWorkspace(c, service.DefaultWorkspace)
+	sessionId, err := utils.GetMcpSessionId(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	nameArg := service.NameArg{
+		Workspace: workspace,
+		Session:   sessionId,
+	}
+	if _, exists := m.mcpServiceMgr.GetProxySession(xl, nameArg); !exists {
+		return c.String(http.StatusNotFound, "session not found")
+	}
+	m.mcpServiceMgr.CloseProxySession(xl, nameArg)
+	return c.NoContent(http.StatusOK)
+}
+
+// writeJSONRPCError 返回 JSON-RPC 错误响应
+func writeJSONRPCError(c echo.Context, status int, id mcp.RequestId, code int, message string) error {
+	response := mcp.JSONRPCError{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		ID:      id,
+	}
+	response.Error.Code = code
+	response.Error.Message = message
+	return c.JSON(status, response)
+}
@@ -0,0 +1,101 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/service"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+// readMcpServers 读取 mcp_servers.json，文件不存在时返回空配置，未指定工作空间的服务属于默认工作空间
+func readMcpServers(path string) (map[string]config.MCPServerConfig, error) {
+	servers := make(map[string]config.MCPServerConfig)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return servers, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &servers); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for name, srv := range servers {
+		if srv.Workspace == "" {
+			srv.Workspace = service.DefaultWorkspace
+			servers[name] = srv
+		}
+	}
+	return servers, nil
+}
+
+// Reload 热加载配置: 重试次数立即应用到现有服务，mcp_servers.json 中只有发生变化的服务会被重新部署或删除
+// 通过接口部署、不在文件中的服务不受影响
+func (m *ServerManager) Reload(cfg config.Config) error {
+	xl := xlog.NewLogger("[Reload]")
+
+	m.reloadMutex.Lock()
+	defer m.reloadMutex.Unlock()
+
+	m.cfg = cfg
+	m.mcpServiceMgr.UpdateConfig(xl, cfg)
+
+	servers, err := readMcpServers(cfg.GetMcpConfigPath())
+	if err != nil {
+		// 文件可能正在写入，保留当前服务，等待下一次变化
+		return err
+	}
+
+	changed := 0
+	for _, name := range sortedNames(servers) {
+		srv := servers[name]
+		previous, ok := m.fileServers[name]
+		if ok && reflect.DeepEqual(previous, srv) {
+			continue
+		}
+		changed++
+		if ok && previous.Workspace != srv.Workspace {
+			m.removeFileServer(xl, name, previous.Workspace)
+		}
+		xl.Infof("Server %s changed in %s, redeploying", name, config.MCP_CONFIG_PATH)
+		m.removeFileServer(xl, name, srv.Workspace)
+		if _, err := m.DeployServer(name, srv); err != nil {
+			xl.Errorf("Error redeploying server %s: %v", name, err)
+		}
+	}
+	for _, name := range sortedNames(m.fileServers) {
+		if _, ok := servers[name]; !ok {
+			changed++
+			xl.Infof("Server %s removed from %s, deleting", name, config.MCP_CONFIG_PATH)
+			m.removeFileServer(xl, name, m.fileServers[name].Workspace)
+		}
+	}
+	m.fileServers = servers
+	xl.Infof("Reloaded config, %d servers changed", changed)
+	return nil
+}
+
+// removeFileServer 删除文件中定义的服务，服务不存在时忽略
+func (m *ServerManager) removeFileServer(xl xlog.Logger, name, workspace string) {
+	nameArg := service.NameArg{Workspace: workspace, Server: name}
+	if _, err := m.mcpServiceMgr.GetMcpService(xl, nameArg); err != nil {
+		return
+	}
+	if err := m.mcpServiceMgr.DeleteServer(xl, nameArg); err != nil {
+		xl.Errorf("Failed to delete server %s: %v", name, err)
+	}
+}
+
+func sortedNames(servers map[string]config.MCPServerConfig) []string {
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
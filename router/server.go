@@ -1,8 +1,7 @@
 package router
 
 import (
-	"encoding/json"
-	"os"
+	"reflect"
 	"sync"
 
 	"github.com/labstack/echo/v4"
@@ -16,12 +15,16 @@ type ServerManager struct {
 	sync.RWMutex
 	mcpServiceMgr service.ServiceManagerI
 	cfg           config.Config
+
+	// reloadMutex 保证加载和热加载 mcp_servers.json 依次进行，fileServers 为最近一次加载的文件内容
+	reloadMutex sync.Mutex
+	fileServers map[string]config.MCPServerConfig
 }
 
 // NewServerManager 初始化服务管理器
 func NewServerManager(cfg config.Config, e *echo.Echo) *ServerManager {
-	portMgr := service.NewPortManager()
-	mcpServiceMgr := service.NewServiceMgr(cfg, portMgr)
+	portMgr := service.NewPortManager(cfg.PortRange.Start, cfg.PortRange.End)
+	mcpServiceMgr := service.NewServiceMgr(cfg, portMgr, service.NewJSONFileStore(cfg.GetStatePath()))
 	m := &ServerManager{
 		mcpServiceMgr: mcpServiceMgr,
 		cfg:           cfg,
@@ -32,8 +35,15 @@ func NewServerManager(cfg config.Config, e *echo.Echo) *ServerManager {
 	e.DELETE("/delete", m.handleDeleteMcpService)             // 删除服务
 	e.GET("/sse", m.handleGlobalSSE)                          // 全局SSE WIP
 	e.POST("/message", m.handleGlobalMessage)                 // 全局消息 WIP
+	e.POST("/mcp", m.handleGlobalMCPPost)                     // 全局 Streamable HTTP 请求
+	e.GET("/mcp", m.handleGlobalMCPGet)                       // 全局 Streamable HTTP 事件流
+	e.DELETE("/mcp", m.handleGlobalMCPDelete)                 // 结束 Streamable HTTP 会话
 	e.GET("/services", m.handleGetAllServices)                // 获取所有服务
 	e.GET("/services/:name/health", m.handleGetServiceHealth) // 获取服务健康状态
+	e.GET("/services/:name/ready", m.handleGetServiceReady)   // 服务是否就绪
+	e.GET("/health", m.handleGatewayHealth)                   // 网关存活检查
+	e.GET("/ready", m.handleGatewayReady)                     // 网关就绪检查
+	e.GET("/health/details", m.handleGatewayHealthDetails)    // 网关及各服务的健康详情，需要鉴权
 
 	// API 路由
 	api := e.Group("/api")
@@ -43,12 +53,14 @@ func NewServerManager(cfg config.Config, e *echo.Echo) *ServerManager {
 	api.POST("/workspaces", m.handleCreateWorkspace)
 	api.DELETE("/workspaces/:id", m.handleDeleteWorkspace)
 	api.GET("/workspaces/:id/services", m.handleGetWorkspaceServices)
+	api.POST("/workspaces/:workspace/apply", m.handleApplyWorkspace)
 
 	// Session 管理
 	api.GET("/workspaces/:workspace/sessions", m.handleGetWorkspaceSessions)
 	api.POST("/workspaces/:workspace/sessions", m.handleCreateSession)
 	api.DELETE("/workspaces/:workspace/sessions/:id", m.handleDeleteSession)
 	api.GET("/sessions/:id/status", m.handleGetSessionStatus)
+	api.GET("/sessions/metrics", m.handleGetSessionMetrics)
 
 	// 增强的服务管理
 	api.POST("/workspaces/:workspace/services", m.handleDeployServiceToWorkspace)
@@ -58,6 +70,7 @@ func NewServerManager(cfg config.Config, e *echo.Echo) *ServerManager {
 	api.POST("/workspaces/:workspace/services/:name/start", m.handleStartService)
 	api.DELETE("/workspaces/:workspace/services/:name", m.handleDeleteServiceFromWorkspace)
 	api.GET("/workspaces/:workspace/services/:name/logs", m.handleGetServiceLogs)
+	api.GET("/workspaces/:workspace/services/:name/status", m.handleGetServiceStatus)
 
 	// 调试功能路由
 	m.setupDebugRoutes(api)
@@ -67,33 +80,58 @@ func NewServerManager(cfg config.Config, e *echo.Echo) *ServerManager {
 
 	// 代理
 	e.Any("/*", m.proxyHandler())
-	m.loadConfig()
+
+	// 先恢复持久化的工作空间和服务，再加载 mcp_servers.json
+	xl := xlog.NewLogger("[ServerManager]")
+	if err := mcpServiceMgr.Restore(xl); err != nil {
+		xl.Errorf("Failed to restore state: %v", err)
+	}
+	if err := m.loadConfig(); err != nil {
+		xl.Errorf("Failed to load %s: %v", config.MCP_CONFIG_PATH, err)
+	}
 	return m
 }
+
+// loadConfig 异步部署 mcp_servers.json 中的服务，然后启动恢复的服务
+// 与持久化状态中配置相同的服务保持恢复的期望状态，配置变化的服务按文件重新部署
 func (m *ServerManager) loadConfig() error {
 	xl := xlog.NewLogger("[ServerManager]")
-	data, err := os.ReadFile(m.cfg.GetMcpConfigPath())
-	if os.IsNotExist(err) {
-		return nil
-	}
+	servers, err := readMcpServers(m.cfg.GetMcpConfigPath())
 	if err != nil {
+		m.mcpServiceMgr.StartRestored(xl)
 		return err
 	}
 
-	var config map[string]config.MCPServerConfig
-	if err := json.Unmarshal(data, &config); err != nil {
-		return err
+	// 复制一份，部署时会修改工作空间的配置
+	restored := make(map[string]config.MCPServerConfig)
+	for name, cfg := range m.mcpServiceMgr.ListServerConfig(xl, service.NameArg{Workspace: service.DefaultWorkspace}) {
+		restored[name] = cfg
 	}
 
-	xl.Infof("Async Loading %d servers", len(config))
+	xl.Infof("Async Loading %d servers", len(servers))
 	go func() {
-		for name, srv := range config {
-			xl.Infof("Loading server %s: %v", name, srv)
+		// 部署完成后才释放，期间的热加载会等待
+		m.reloadMutex.Lock()
+		defer m.reloadMutex.Unlock()
+		if m.fileServers != nil {
+			// 热加载先于初始加载拿到锁，已按最新的文件部署
+			xl.Infof("Servers already loaded by reload, skipping")
+			m.mcpServiceMgr.StartRestored(xl)
+			return
+		}
+		m.fileServers = servers
+		for name, srv := range servers {
+			if existing, ok := restored[name]; ok && srv.Workspace == service.DefaultWorkspace && reflect.DeepEqual(existing, srv) {
+				xl.Infof("Server %s is restored from state, skipping", name)
+				continue
+			}
+			xl.Infof("Loading server %s: %v", name, srv.Redacted())
 			if _, err := m.DeployServer(name, srv); err != nil {
 				xl.Errorf("Error deploying server %s: %v", name, err)
 			}
 		}
-		xl.Infof("Loaded %d servers", len(config))
+		xl.Infof("Loaded %d servers", len(servers))
+		m.mcpServiceMgr.StartRestored(xl)
 	}()
 
 	return nil
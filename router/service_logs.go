@@ -0,0 +1,155 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/service"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+// ServiceLogsResponse 服务日志响应
+type ServiceLogsResponse struct {
+	ServiceName string             `json:"service_name"`
+	Logs        []service.LogEntry `json:"logs"`
+	TotalLines  int                `json:"total_lines"` // 过滤后的总条数，tail 查询时为读取到的条数
+}
+
+// parseLogQuery 解析日志查询参数: tail、offset、limit、level，未指定任何范围参数时使用 defaultTail
+func parseLogQuery(c echo.Context, defaultTail int) (service.LogQuery, error) {
+	query := service.LogQuery{}
+	params := map[string]*int{
+		"tail":   &query.Tail,
+		"offset": &query.Offset,
+		"limit":  &query.Limit,
+	}
+	for name, value := range params {
+		raw := c.QueryParam(name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return query, fmt.Errorf("invalid %s: %s", name, raw)
+		}
+		*value = n
+	}
+	if query.Tail == 0 && query.Offset == 0 && query.Limit == 0 {
+		query.Tail = defaultTail
+	}
+
+	if level := c.QueryParam("level"); level != "" {
+		query.Level = service.NormalizeLogLevel(level)
+		if query.Level == "" {
+			return query, fmt.Errorf("不支持的日志级别: %s", level)
+		}
+	}
+	return query, nil
+}
+
+// serveServiceLogs 按查询参数返回服务日志，follow=true 时先推送历史日志再通过 SSE 持续推送新日志
+func (m *ServerManager) serveServiceLogs(c echo.Context, xl xlog.Logger, defaultTail int) error {
+	workspace := c.Param("workspace")
+	if workspace == "" {
+		workspace = service.DefaultWorkspace
+	}
+	serviceName := c.Param("name")
+
+	mcpService, err := m.mcpServiceMgr.GetMcpService(xl, service.NameArg{
+		Workspace: workspace,
+		Server:    serviceName,
+	})
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": fmt.Sprintf("Service not found: %v", err),
+		})
+	}
+
+	query, err := parseLogQuery(c, defaultTail)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if c.QueryParam("follow") == "true" {
+		return streamServiceLogs(c, xl, mcpService, query)
+	}
+
+	logs, total, err := mcpService.ReadLogs(query)
+	if err != nil {
+		xl.Errorf("Failed to read logs of service %s: %v", serviceName, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, ServiceLogsResponse{
+		ServiceName: serviceName,
+		Logs:        logs,
+		TotalLines:  total,
+	})
+}
+
+// streamServiceLogs 通过 SSE 推送日志，每条日志是一个 log 事件
+func streamServiceLogs(c echo.Context, xl xlog.Logger, mcpService service.ExportMcpService, query service.LogQuery) error {
+	// 先订阅再读取历史日志，避免两者之间产生的日志丢失
+	logChan, unsubscribe := mcpService.SubscribeLogs()
+	defer unsubscribe()
+
+	history, _, err := mcpService.ReadLogs(query)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	c.Response().Header().Set("Content-Type", "text/event-stream")
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	c.Response().Header().Set("Connection", "keep-alive")
+	c.Response().WriteHeader(http.StatusOK)
+	w := c.Response().Writer
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return c.String(http.StatusInternalServerError, "flusher not supported")
+	}
+
+	writeEntry := func(entry service.LogEntry) error {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: log\ndata: %s\n\n", data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	for _, entry := range history {
+		if err := writeEntry(entry); err != nil {
+			return nil
+		}
+	}
+	flusher.Flush()
+
+	// 订阅后、读取前写入的日志已包含在历史日志中，跳过
+	var lastSent service.LogEntry
+	if len(history) > 0 {
+		lastSent = history[len(history)-1]
+	}
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			xl.Debugf("Log follower disconnected")
+			return nil
+		case entry, ok := <-logChan:
+			if !ok {
+				return nil
+			}
+			if !query.Match(entry) || !entry.Timestamp.After(lastSent.Timestamp) {
+				continue
+			}
+			if err := writeEntry(entry); err != nil {
+				return nil
+			}
+		}
+	}
+}
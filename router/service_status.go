@@ -0,0 +1,86 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/service"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+// serviceStatusURL 订阅服务状态变化的地址
+func serviceStatusURL(workspace, name string) string {
+	return fmt.Sprintf("/api/workspaces/%s/services/%s/status?follow=true", url.PathEscape(workspace), url.PathEscape(name))
+}
+
+// handleGetServiceStatus 获取服务当前的状态，follow=true 时通过 SSE 持续推送状态变化
+func (m *ServerManager) handleGetServiceStatus(c echo.Context) error {
+	xl := xlog.NewLogger("GET-SERVICE-STATUS")
+	workspace := c.Param("workspace")
+	serviceName := c.Param("name")
+
+	mcpService, err := m.mcpServiceMgr.GetMcpService(xl, service.NameArg{
+		Workspace: workspace,
+		Server:    serviceName,
+	})
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": fmt.Sprintf("Service not found: %v", err),
+		})
+	}
+
+	if c.QueryParam("follow") != "true" {
+		return c.JSON(http.StatusOK, mcpService.CurrentStatus())
+	}
+	return streamServiceStatus(c, xl, mcpService)
+}
+
+// streamServiceStatus 通过 SSE 推送状态，先推送当前状态，之后每次变化是一个 status 事件
+func streamServiceStatus(c echo.Context, xl xlog.Logger, mcpService service.ExportMcpService) error {
+	// 先订阅再读取当前状态，避免两者之间的变化丢失
+	events, unsubscribe := mcpService.SubscribeStatus()
+	defer unsubscribe()
+
+	c.Response().Header().Set("Content-Type", "text/event-stream")
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	c.Response().Header().Set("Connection", "keep-alive")
+	c.Response().WriteHeader(http.StatusOK)
+	w := c.Response().Writer
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return c.String(http.StatusInternalServerError, "flusher not supported")
+	}
+
+	writeEvent := func(event service.StatusEvent) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: status\ndata: %s\n\n", data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	if err := writeEvent(mcpService.CurrentStatus()); err != nil {
+		return nil
+	}
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			xl.Debugf("Status follower disconnected")
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := writeEvent(event); err != nil {
+				return nil
+			}
+		}
+	}
+}
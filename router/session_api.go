@@ -1,11 +1,14 @@
 package router
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/errs"
 	"github.com/lucky-aeon/agentx/plugin-helper/service"
+	"github.com/lucky-aeon/agentx/plugin-helper/utils"
 	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
 )
 
@@ -17,6 +20,25 @@ type SessionInfo struct {
 	CreatedAt       time.Time `json:"created_at"`
 	LastReceiveTime time.Time `json:"last_receive_time"`
 	IsReady         bool      `json:"is_ready"`
+	// 工作空间中各个服务是否已加入会话，有服务未加入时会话状态为 degraded
+	Servers map[string]service.McpAttachment `json:"servers"`
+}
+
+// newSessionInfo 转换为API响应格式
+func newSessionInfo(workspaceID string, session *service.Session) SessionInfo {
+	status := "active"
+	if session.IsDegraded() {
+		status = "degraded"
+	}
+	return SessionInfo{
+		ID:              session.GetId(),
+		WorkspaceID:     workspaceID,
+		Status:          status,
+		CreatedAt:       session.CreatedAt,
+		LastReceiveTime: session.LastReceiveTime,
+		IsReady:         session.IsToolsListReady(),
+		Servers:         session.Attachments(),
+	}
 }
 
 // handleGetWorkspaceSessions 获取工作空间的会话
@@ -33,15 +55,7 @@ func (m *ServerManager) handleGetWorkspaceSessions(c echo.Context) error {
 	// 转换为API响应格式
 	sessionInfos := make([]SessionInfo, 0, len(sessions))
 	for _, session := range sessions {
-		sessionInfo := SessionInfo{
-			ID:              session.GetId(),
-			WorkspaceID:     workspaceID,
-			Status:          "active",
-			CreatedAt:       session.CreatedAt,
-			LastReceiveTime: session.LastReceiveTime,
-			IsReady:         session.IsToolsListReady(),
-		}
-		sessionInfos = append(sessionInfos, sessionInfo)
+		sessionInfos = append(sessionInfos, newSessionInfo(workspaceID, session))
 	}
 
 	return c.JSON(http.StatusOK, sessionInfos)
@@ -55,23 +69,20 @@ func (m *ServerManager) handleCreateSession(c echo.Context) error {
 
 	session, err := m.mcpServiceMgr.CreateProxySession(xl, service.NameArg{
 		Workspace: workspaceID,
+		ApiKey:    utils.GetApiKey(c),
 	})
+	if errors.Is(err, errs.ErrSessionLimitExceeded) {
+		return c.JSON(http.StatusTooManyRequests, map[string]string{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": err.Error(),
 		})
 	}
 
-	sessionInfo := SessionInfo{
-		ID:              session.GetId(),
-		WorkspaceID:     workspaceID,
-		Status:          "active",
-		CreatedAt:       session.CreatedAt,
-		LastReceiveTime: session.LastReceiveTime,
-		IsReady:         session.IsToolsListReady(),
-	}
-
-	return c.JSON(http.StatusCreated, sessionInfo)
+	return c.JSON(http.StatusCreated, newSessionInfo(workspaceID, session))
 }
 
 // handleDeleteSession 删除会话
@@ -105,15 +116,7 @@ func (m *ServerManager) handleGetSessionStatus(c echo.Context) error {
 		})
 
 		if exists {
-			sessionInfo := SessionInfo{
-				ID:              session.GetId(),
-				WorkspaceID:     workspaceID,
-				Status:          "active",
-				CreatedAt:       session.CreatedAt,
-				LastReceiveTime: session.LastReceiveTime,
-				IsReady:         session.IsToolsListReady(),
-			}
-			return c.JSON(http.StatusOK, sessionInfo)
+			return c.JSON(http.StatusOK, newSessionInfo(workspaceID, session))
 		}
 	}
 
@@ -121,3 +124,9 @@ func (m *ServerManager) handleGetSessionStatus(c echo.Context) error {
 		"error": "Session not found",
 	})
 }
+
+// handleGetSessionMetrics 获取各工作空间的会话统计: 活跃数、创建数、回收数和因上限被拒绝的数量
+func (m *ServerManager) handleGetSessionMetrics(c echo.Context) error {
+	xl := xlog.NewLogger("GET-SESSION-METRICS")
+	return c.JSON(http.StatusOK, m.mcpServiceMgr.GetSessionMetrics(xl))
+}
@@ -1,10 +1,13 @@
 package router
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
+	"github.com/lucky-aeon/agentx/plugin-helper/errs"
 	"github.com/lucky-aeon/agentx/plugin-helper/service"
 	"github.com/lucky-aeon/agentx/plugin-helper/utils"
 	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
@@ -26,7 +29,11 @@ func (m *ServerManager) handleGlobalSSE(c echo.Context) error {
 		session, err := m.mcpServiceMgr.CreateProxySession(xl, service.NameArg{
 			Workspace: workspace,
 			Session:   querySessionId,
+			ApiKey:    utils.GetApiKey(c),
 		})
+		if errors.Is(err, errs.ErrSessionLimitExceeded) {
+			return c.String(http.StatusTooManyRequests, err.Error())
+		}
 		if err != nil {
 			return c.String(http.StatusInternalServerError, err.Error())
 		}
@@ -65,8 +72,12 @@ func (m *ServerManager) handleGlobalSSE(c echo.Context) error {
 	}
 	flusher.Flush()
 
-	// 获取事件通道和关闭函数
-	eventChan, closeChan := session.GetEventChanWithCloser()
+	// 获取事件通道和关闭函数，断线重连时先重放错过的事件
+	replay, eventChan, closeChan := sessionEventChan(xl, c, session)
+	defer closeChan()
+	for _, event := range replay {
+		writeSessionEvent(w, flusher, event)
+	}
 
 	// 转发所有SSE事件
 	for {
@@ -74,16 +85,37 @@ func (m *ServerManager) handleGlobalSSE(c echo.Context) error {
 		case <-c.Request().Context().Done():
 			// client closed connection
 			xl.Infof("Client closed connection, sessionId: %s", querySessionId)
-			// 关闭当前客户端的事件通道
-			closeChan()
 			return nil
-		case event := <-eventChan:
+		case event, ok := <-eventChan:
+			if !ok {
+				return nil
+			}
 			xl.Infof("to sse: %v", event)
-			//ev := fmt.Sprintf("event: message", event.Data)
-			fmt.Fprintf(w, "event: %s\n", event.Event)
-			flusher.Flush()
-			fmt.Fprintf(w, "data: %s\n\n", event.Data)
-			flusher.Flush()
+			writeSessionEvent(w, flusher, event)
 		}
 	}
 }
+
+// sessionEventChan 获取会话的事件通道，请求带有 Last-Event-ID 时同时返回需要重放的事件
+func sessionEventChan(xl xlog.Logger, c echo.Context, session *service.Session) ([]service.SessionMsg, <-chan service.SessionMsg, func()) {
+	lastEventId, ok := utils.GetLastEventId(c)
+	if !ok {
+		eventChan, closeChan := session.GetEventChanWithCloser()
+		return nil, eventChan, closeChan
+	}
+	replay, complete, eventChan, closeChan := session.ResumeEventChan(lastEventId)
+	xl.Infof("Resuming session %s after event %d, replaying %d events", session.Id, lastEventId, len(replay))
+	if !complete {
+		xl.Warnf("Some events of session %s after %d are no longer available", session.Id, lastEventId)
+	}
+	return replay, eventChan, closeChan
+}
+
+// writeSessionEvent 写出会话事件，带上事件序号供客户端断线重连时通过 Last-Event-ID 重放
+func writeSessionEvent(w io.Writer, flusher http.Flusher, event service.SessionMsg) {
+	if event.Id != 0 {
+		fmt.Fprintf(w, "id: %d\n", event.Id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Event, event.Data)
+	flusher.Flush()
+}
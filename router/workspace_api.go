@@ -1,10 +1,12 @@
 package router
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
 	"github.com/lucky-aeon/agentx/plugin-helper/service"
+	"github.com/lucky-aeon/agentx/plugin-helper/types"
 	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
 )
 
@@ -17,6 +19,12 @@ type WorkspaceInfo struct {
 	Services     []service.McpServiceInfo `json:"services,omitempty"`
 }
 
+// ApplyWorkspaceResponse 应用工作空间清单的响应
+type ApplyWorkspaceResponse struct {
+	service.WorkspacePlan
+	DryRun bool `json:"dry_run"`
+}
+
 // handleGetAllWorkspaces 获取所有工作空间
 func (m *ServerManager) handleGetAllWorkspaces(c echo.Context) error {
 	xl := xlog.NewLogger("GET-WORKSPACES")
@@ -105,6 +113,11 @@ func (m *ServerManager) handleDeleteWorkspace(c echo.Context) error {
 		}
 	}
 
+	// 删除工作空间本身，避免重启后恢复
+	if err := m.mcpServiceMgr.DeleteWorkspace(xl, service.NameArg{Workspace: workspaceID}); err != nil {
+		xl.Warnf("Failed to delete workspace %s: %v", workspaceID, err)
+	}
+
 	return c.JSON(http.StatusOK, map[string]string{"status": "success"})
 }
 
@@ -125,3 +138,42 @@ func (m *ServerManager) handleGetWorkspaceServices(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, serviceInfos)
 }
+
+// handleApplyWorkspace 按清单收敛工作空间: 新增服务、替换配置变化的服务、删除清单中不存在的服务
+// dryRun=true 时只返回计划，不做任何修改
+func (m *ServerManager) handleApplyWorkspace(c echo.Context) error {
+	xl := xlog.NewLogger("APPLY-WORKSPACE")
+	workspaceID := c.Param("workspace")
+	dryRun := c.QueryParam("dryRun") == "true"
+	xl.Infof("Apply manifest to workspace: %s, dry run: %v", workspaceID, dryRun)
+
+	var manifest types.WorkspaceManifest
+	if err := c.Bind(&manifest); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	// 先校验全部配置，避免只应用了一部分
+	for name, cfg := range manifest.MCPServers {
+		if err := validateServerConfig(cfg); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("服务 %s 配置错误: %v", name, err),
+			})
+		}
+	}
+
+	name := service.NameArg{Workspace: workspaceID}
+	if dryRun {
+		plan := m.mcpServiceMgr.PlanWorkspace(xl, name, manifest.MCPServers)
+		return c.JSON(http.StatusOK, ApplyWorkspaceResponse{WorkspacePlan: plan, DryRun: true})
+	}
+
+	// 与单个服务的部署互斥
+	m.Lock()
+	plan := m.mcpServiceMgr.ApplyWorkspace(xl, name, manifest.MCPServers)
+	m.Unlock()
+
+	statusCode := http.StatusOK
+	if plan.Failed() > 0 {
+		statusCode = http.StatusPartialContent // 206表示部分成功
+	}
+	return c.JSON(statusCode, ApplyWorkspaceResponse{WorkspacePlan: plan})
+}
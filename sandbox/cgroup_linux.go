@@ -0,0 +1,162 @@
+package sandbox
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+)
+
+const (
+	cgroupMount = "/sys/fs/cgroup"
+	// cgroupServices 网关 cgroup 下存放各服务 cgroup 的目录
+	cgroupServices = "mcp-services"
+	// cgroupGateway 网关所在 cgroup 有其他进程时，网关移入的子目录
+	cgroupGateway = "gateway"
+	// cpuPeriod cpu.max 的周期，单位微秒
+	cpuPeriod = 100000
+)
+
+var (
+	cgroupParentOnce sync.Once
+	cgroupParentDir  string
+	cgroupParentErr  error
+)
+
+// servicesCgroup 初始化存放服务 cgroup 的目录，只执行一次
+// cgroup v2 中有进程的 cgroup 不能为子 cgroup 开启控制器，因此先把网关移到单独的子 cgroup
+func servicesCgroup() (string, error) {
+	cgroupParentOnce.Do(func() {
+		cgroupParentDir, cgroupParentErr = initServicesCgroup()
+	})
+	return cgroupParentDir, cgroupParentErr
+}
+
+func initServicesCgroup() (string, error) {
+	if _, err := os.Stat(filepath.Join(cgroupMount, "cgroup.controllers")); err != nil {
+		return "", errors.New("cgroup v2 is not mounted")
+	}
+	current, err := currentCgroup()
+	if err != nil {
+		return "", err
+	}
+	base := filepath.Join(cgroupMount, current)
+
+	if current != "/" {
+		gateway := filepath.Join(base, cgroupGateway)
+		if err := os.MkdirAll(gateway, 0755); err != nil {
+			return "", err
+		}
+		if err := writeCgroupFile(gateway, "cgroup.procs", strconv.Itoa(os.Getpid())); err != nil {
+			return "", fmt.Errorf("move gateway to %s: %w", gateway, err)
+		}
+	}
+	if err := enableControllers(base); err != nil {
+		return "", err
+	}
+
+	parent := filepath.Join(base, cgroupServices)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return "", err
+	}
+	if err := enableControllers(parent); err != nil {
+		return "", err
+	}
+	return parent, nil
+}
+
+// currentCgroup 网关所在的 cgroup v2 路径
+func currentCgroup() (string, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return path, nil
+		}
+	}
+	return "", errors.New("cgroup v2 path not found")
+}
+
+// enableControllers 为子 cgroup 开启 cpu、memory、pids 中可用的控制器
+func enableControllers(dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+	if err != nil {
+		return err
+	}
+	available := strings.Fields(string(data))
+	var enable []string
+	for _, controller := range []string{"cpu", "memory", "pids"} {
+		if slices.Contains(available, controller) {
+			enable = append(enable, "+"+controller)
+		}
+	}
+	if len(enable) == 0 {
+		return errors.New("no cgroup controllers available")
+	}
+	if err := writeCgroupFile(dir, "cgroup.subtree_control", strings.Join(enable, " ")); err != nil {
+		return fmt.Errorf("enable controllers in %s: %w", dir, err)
+	}
+	return nil
+}
+
+// createCgroup 创建服务的 cgroup 并写入限制，已存在时复用
+func createCgroup(id string, cfg *config.SandboxConfig) (string, error) {
+	parent, err := servicesCgroup()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(parent, id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	limits := map[string]string{}
+	if cfg.MemoryMB > 0 {
+		limits["memory.max"] = strconv.FormatInt(int64(cfg.MemoryMB)<<20, 10)
+	}
+	if cfg.CPUs > 0 {
+		limits["cpu.max"] = fmt.Sprintf("%d %d", int64(cfg.CPUs*cpuPeriod), cpuPeriod)
+	}
+	if cfg.MaxProcesses > 0 {
+		limits["pids.max"] = strconv.Itoa(cfg.MaxProcesses)
+	}
+	for file, value := range limits {
+		if err := writeCgroupFile(dir, file, value); err != nil {
+			_ = os.Remove(dir)
+			return "", fmt.Errorf("set %s: %w", file, err)
+		}
+	}
+	return dir, nil
+}
+
+// removeCgroup 删除服务的 cgroup，cgroup 中仍有进程时失败
+func removeCgroup(dir string) error {
+	if err := os.Remove(dir); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func writeCgroupFile(dir, name, value string) error {
+	return os.WriteFile(filepath.Join(dir, name), []byte(value), 0644)
+}
+
+// cgroupMemory cgroup 当前的内存使用
+func cgroupMemory(dir string) (int64, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return value, err == nil
+}
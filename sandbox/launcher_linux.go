@@ -0,0 +1,158 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+)
+
+const supported = true
+
+// rlimitNproc syscall 包中没有定义的 RLIMIT_NPROC
+const rlimitNproc = 0x6
+
+// runLauncher 依次加入 cgroup、设置 rlimit、创建只读的 mount namespace、切换工作目录和用户，最后 exec 命令
+func runLauncher(args []string) error {
+	sp, command, err := parseLauncherArgs(args)
+	if err != nil {
+		return err
+	}
+	cfg := sp.Config
+
+	if sp.Cgroup != "" {
+		if err := os.WriteFile(filepath.Join(sp.Cgroup, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+			return fmt.Errorf("join cgroup: %w", err)
+		}
+	}
+	if err := setRlimits(cfg, sp.Cgroup != ""); err != nil {
+		return err
+	}
+
+	// 切换用户后可能无法访问命令所在的目录
+	path, err := exec.LookPath(command[0])
+	if err != nil {
+		return err
+	}
+
+	// mount namespace 只对调用 unshare 的线程生效，exec 前不能切换线程
+	runtime.LockOSThread()
+	if cfg.ReadOnlyFS {
+		if err := readOnlyRoot(cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.WorkDir != "" {
+		if err := os.Chdir(cfg.WorkDir); err != nil {
+			return fmt.Errorf("chdir: %w", err)
+		}
+	}
+	if err := switchUser(cfg); err != nil {
+		return err
+	}
+	return syscall.Exec(path, command, os.Environ())
+}
+
+type rlimit struct {
+	name     string
+	resource int
+	value    uint64
+}
+
+// setRlimits 设置 rlimit，加入 cgroup 时进程数由 cgroup 限制
+// 内存只由 cgroup 限制，RLIMIT_AS 限制的是虚拟地址空间，会让 Node/V8 这类运行时无法启动
+func setRlimits(cfg config.SandboxConfig, cgroup bool) error {
+	limits := []rlimit{
+		{"cpu", syscall.RLIMIT_CPU, uint64(cfg.CPUSeconds)},
+		{"open files", syscall.RLIMIT_NOFILE, uint64(cfg.OpenFiles)},
+	}
+	if !cgroup {
+		limits = append(limits, rlimit{"processes", rlimitNproc, uint64(cfg.MaxProcesses)})
+	}
+	for _, limit := range limits {
+		if limit.value == 0 {
+			continue
+		}
+		value := syscall.Rlimit{Cur: limit.value, Max: limit.value}
+		if err := syscall.Setrlimit(limit.resource, &value); err != nil {
+			return fmt.Errorf("set %s limit: %w", limit.name, err)
+		}
+	}
+	return nil
+}
+
+// readOnlyRoot 在新的 mount namespace 中将根文件系统重新挂载为只读，writablePaths、workDir 和临时目录保持可写
+func readOnlyRoot(cfg config.SandboxConfig) error {
+	if err := syscall.Unshare(syscall.CLONE_NEWNS); err != nil {
+		return fmt.Errorf("unshare mount namespace: %w", err)
+	}
+	// 挂载变更不传播回宿主的 namespace
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+
+	writable := append([]string{os.TempDir()}, cfg.WritablePaths...)
+	if cfg.WorkDir != "" {
+		writable = append(writable, cfg.WorkDir)
+	}
+	// 单独挂载的目录不受根目录重新挂载的影响
+	for _, p := range writable {
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		if err := syscall.Mount(p, p, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("bind writable path %s: %w", p, err)
+		}
+	}
+
+	var st syscall.Statfs_t
+	if err := syscall.Statfs("/", &st); err != nil {
+		return fmt.Errorf("statfs /: %w", err)
+	}
+	// 重新挂载时需要保留原有的 nosuid、nodev 等标志，否则在容器中会被拒绝
+	flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY)
+	for _, f := range []struct{ st, ms int64 }{
+		{0x2, syscall.MS_NOSUID},       // ST_NOSUID
+		{0x4, syscall.MS_NODEV},        // ST_NODEV
+		{0x8, syscall.MS_NOEXEC},       // ST_NOEXEC
+		{0x400, syscall.MS_NOATIME},    // ST_NOATIME
+		{0x800, syscall.MS_NODIRATIME}, // ST_NODIRATIME
+		{0x1000, syscall.MS_RELATIME},  // ST_RELATIME
+	} {
+		if st.Flags&f.st != 0 {
+			flags |= uintptr(f.ms)
+		}
+	}
+	if err := syscall.Mount("", "/", "", flags, ""); err != nil {
+		return fmt.Errorf("remount / read-only: %w", err)
+	}
+	return nil
+}
+
+// switchUser 切换用户组和用户，附加组只保留目标用户组
+func switchUser(cfg config.SandboxConfig) error {
+	if cfg.GID != nil {
+		if err := syscall.Setgroups([]int{*cfg.GID}); err != nil {
+			return fmt.Errorf("setgroups: %w", err)
+		}
+		if err := syscall.Setgid(*cfg.GID); err != nil {
+			return fmt.Errorf("setgid: %w", err)
+		}
+	}
+	if cfg.UID != nil {
+		if cfg.GID == nil {
+			if err := syscall.Setgroups(nil); err != nil {
+				return fmt.Errorf("setgroups: %w", err)
+			}
+		}
+		if err := syscall.Setuid(*cfg.UID); err != nil {
+			return fmt.Errorf("setuid: %w", err)
+		}
+	}
+	return nil
+}
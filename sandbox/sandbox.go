@@ -0,0 +1,174 @@
+// Package sandbox 为 stdio 服务的子进程设置资源限制和隔离，并统计子进程的资源使用
+//
+// mcp-go 的 stdio transport 不允许定制子进程，因此需要沙箱时改为启动网关自身，
+// 由网关在子进程中设置 rlimit、加入 cgroup、创建 mount namespace、切换用户后再 exec 实际的命令
+package sandbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+const (
+	// launcherArg 网关以沙箱启动器运行时的第一个参数
+	launcherArg = "__mcp_sandbox"
+	// ServiceIDEnv 写入子进程环境变量的服务标识，用于找到服务的子进程
+	ServiceIDEnv = "MCP_GATEWAY_SERVICE_ID"
+	// usageInterval 后台统计子进程资源使用的间隔，统计需要扫描 /proc，不在读取服务信息时进行
+	usageInterval = 10 * time.Second
+)
+
+// ErrUnsupported 当前系统不支持沙箱
+var ErrUnsupported = errors.New("sandbox is only supported on linux")
+
+// spec 传给沙箱启动器的配置
+type spec struct {
+	Config config.SandboxConfig `json:"config"`
+	Cgroup string               `json:"cgroup,omitempty"` // 子进程加入的 cgroup 目录，为空时只使用 rlimit
+}
+
+// Usage 服务子进程及其后代进程当前的资源使用
+type Usage struct {
+	PID         int     `json:"pid"`
+	Processes   int     `json:"processes"`
+	MemoryBytes int64   `json:"memory_bytes"` // 加入 cgroup 时为 cgroup 的内存使用，否则为各进程 RSS 之和
+	CPUSeconds  float64 `json:"cpu_seconds"`
+	OpenFiles   int     `json:"open_files"`
+}
+
+// Sandbox 单个服务的沙箱，每次启动子进程前创建，停止后关闭
+type Sandbox struct {
+	id       string
+	cfg      *config.SandboxConfig
+	cgroup   string
+	interval time.Duration // 统计资源使用的间隔
+	stop     chan struct{}
+	stopped  chan struct{} // 统计协程退出后关闭
+	closer   sync.Once
+	closeErr error
+
+	mu    sync.Mutex
+	usage *Usage
+}
+
+// New 创建服务的沙箱，id 需要在网关内唯一，cfg 为 nil 时不做限制，只用于统计资源使用
+// cgroup v2 不可用时只通过 rlimit 限制进程数，内存和 CPU 核数的限制不生效
+func New(logger xlog.Logger, id string, cfg *config.SandboxConfig) (*Sandbox, error) {
+	return newSandbox(logger, id, cfg, usageInterval)
+}
+
+// newSandbox 创建沙箱并按 interval 在后台统计资源使用
+func newSandbox(logger xlog.Logger, id string, cfg *config.SandboxConfig, interval time.Duration) (*Sandbox, error) {
+	s := &Sandbox{id: id, cfg: cfg, interval: interval, stop: make(chan struct{}), stopped: make(chan struct{})}
+	if cfg.Enabled() {
+		if !supported {
+			return nil, ErrUnsupported
+		}
+		if cfg.MemoryMB > 0 || cfg.CPUs > 0 || cfg.MaxProcesses > 0 {
+			cgroup, err := createCgroup(id, cfg)
+			if err != nil {
+				logger.Warnf("cgroup v2 is not available for %s, memory and cpu limits are not enforced: %v", id, err)
+			} else {
+				s.cgroup = cgroup
+			}
+		}
+	}
+	go s.sampleUsage()
+	return s, nil
+}
+
+// Command 返回实际启动的命令和参数，需要沙箱时为网关自身
+func (s *Sandbox) Command(command string, args []string) (string, []string, error) {
+	if !s.cfg.Enabled() {
+		return command, args, nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to locate gateway executable: %w", err)
+	}
+	data, err := json.Marshal(spec{Config: *s.cfg, Cgroup: s.cgroup})
+	if err != nil {
+		return "", nil, err
+	}
+	return exe, append([]string{launcherArg, string(data), "--", command}, args...), nil
+}
+
+// Env 返回需要追加到子进程的环境变量
+func (s *Sandbox) Env() []string {
+	return []string{ServiceIDEnv + "=" + s.id}
+}
+
+// Usage 返回最近一次后台统计的资源使用，子进程未运行时返回 nil
+func (s *Sandbox) Usage() *Usage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.usage
+}
+
+// sampleUsage 按间隔统计子进程的资源使用，直到沙箱关闭
+// 找到子进程后记住它的 pid，之后只在子进程不存在时重新按环境变量查找
+func (s *Sandbox) sampleUsage() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	root := 0
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		current := usage(s.id, s.cgroup, root)
+		root = 0
+		if current != nil {
+			root = current.PID
+		}
+		s.mu.Lock()
+		s.usage = current
+		s.mu.Unlock()
+	}
+}
+
+// Close 停止统计资源使用并删除服务的 cgroup，需要在子进程退出后调用，可以重复调用
+func (s *Sandbox) Close() error {
+	s.closer.Do(func() {
+		close(s.stop)
+		<-s.stopped
+		if s.cgroup != "" {
+			s.closeErr = removeCgroup(s.cgroup)
+		}
+	})
+	return s.closeErr
+}
+
+// IsLauncher 当前进程是否作为沙箱启动器运行
+func IsLauncher() bool {
+	return len(os.Args) > 1 && os.Args[1] == launcherArg
+}
+
+// RunLauncher 按参数设置沙箱后 exec 实际的命令，成功时不会返回，失败时输出错误并退出
+// 需要在 main 的最开始调用，此时不能有其他初始化
+func RunLauncher() {
+	err := runLauncher(os.Args[2:])
+	fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+	os.Exit(126)
+}
+
+// parseLauncherArgs 解析 spec -- command args...
+func parseLauncherArgs(args []string) (spec, []string, error) {
+	var sp spec
+	if len(args) < 3 || args[1] != "--" {
+		return sp, nil, fmt.Errorf("usage: %s <spec> -- <command> [args...]", launcherArg)
+	}
+	if err := json.Unmarshal([]byte(args[0]), &sp); err != nil {
+		return sp, nil, fmt.Errorf("invalid spec: %w", err)
+	}
+	return sp, args[2:], nil
+}
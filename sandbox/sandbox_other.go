@@ -0,0 +1,24 @@
+//go:build !linux
+
+package sandbox
+
+import "github.com/lucky-aeon/agentx/plugin-helper/config"
+
+const supported = false
+
+func runLauncher(args []string) error {
+	return ErrUnsupported
+}
+
+func createCgroup(id string, cfg *config.SandboxConfig) (string, error) {
+	return "", ErrUnsupported
+}
+
+func removeCgroup(dir string) error {
+	return nil
+}
+
+// usage 其他系统上不统计资源使用
+func usage(id, cgroup string, root int) *Usage {
+	return nil
+}
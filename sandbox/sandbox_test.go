@@ -0,0 +1,146 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+// TestMain 测试二进制同时作为沙箱启动器
+func TestMain(m *testing.M) {
+	if IsLauncher() {
+		RunLauncher()
+	}
+	os.Exit(m.Run())
+}
+
+// runInSandbox 在沙箱中运行 sh -c script，返回输出
+func runInSandbox(t *testing.T, cfg *config.SandboxConfig, script string) (string, error) {
+	t.Helper()
+	sb, err := New(xlog.NewLogger("test"), "test", cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer sb.Close()
+	command, args, err := sb.Command("sh", []string{"-c", script})
+	if err != nil {
+		t.Fatalf("Command() failed: %v", err)
+	}
+	output, err := exec.Command(command, args...).CombinedOutput()
+	return strings.TrimSpace(string(output)), err
+}
+
+func TestSandbox_Rlimits(t *testing.T) {
+	dir := t.TempDir()
+	output, err := runInSandbox(t, &config.SandboxConfig{OpenFiles: 64, CPUSeconds: 5, WorkDir: dir}, "ulimit -n; ulimit -t; pwd")
+	if err != nil {
+		t.Fatalf("sandboxed command failed: %v: %s", err, output)
+	}
+	if want := "64\n5\n" + dir; output != want {
+		t.Errorf("Unexpected output %q, want %q", output, want)
+	}
+}
+
+func TestSandbox_Disabled(t *testing.T) {
+	sb, err := New(xlog.NewLogger("test"), "test", nil)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer sb.Close()
+	command, args, err := sb.Command("uvx", []string{"mcp-server-time"})
+	if err != nil || command != "uvx" || len(args) != 1 {
+		t.Errorf("Expected command to be unchanged, got %s %v (%v)", command, args, err)
+	}
+	// 可以重复关闭
+	if err := sb.Close(); err != nil {
+		t.Errorf("Close() failed: %v", err)
+	}
+}
+
+func TestSandbox_ReadOnlyFS(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("read-only filesystem requires root")
+	}
+	writable := t.TempDir()
+	target := filepath.Join("/", "sandbox-test-"+filepath.Base(writable))
+	t.Cleanup(func() { os.Remove(target) })
+
+	output, err := runInSandbox(t, &config.SandboxConfig{ReadOnlyFS: true, WritablePaths: []string{writable}},
+		"touch "+target+" 2>/dev/null && echo root-writable; touch "+writable+"/ok && echo ok")
+	if err != nil && strings.Contains(output, "sandbox:") {
+		t.Skipf("mount namespace is not permitted here: %s", output)
+	}
+	if output != "ok" {
+		t.Errorf("Unexpected output %q", output)
+	}
+	if _, err := os.Stat(target); err == nil {
+		t.Error("Expected root filesystem to be read-only")
+	}
+}
+
+func TestSandbox_SwitchUser(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("switching user requires root")
+	}
+	nobody := 65534
+	output, err := runInSandbox(t, &config.SandboxConfig{UID: &nobody, GID: &nobody}, "id -u; id -g")
+	if err != nil {
+		t.Fatalf("sandboxed command failed: %v: %s", err, output)
+	}
+	if output != "65534\n65534" {
+		t.Errorf("Unexpected output %q", output)
+	}
+}
+
+func TestSandbox_LauncherError(t *testing.T) {
+	exe, _ := os.Executable()
+	cmd := exec.Command(exe, launcherArg, "{}", "--", "command-that-does-not-exist")
+	output, err := cmd.CombinedOutput()
+	if cmd.ProcessState.ExitCode() != 126 || !strings.Contains(string(output), "sandbox:") {
+		t.Errorf("Expected launcher to fail with 126, got %v: %s", err, output)
+	}
+}
+
+func TestSandbox_Usage(t *testing.T) {
+	sb, err := newSandbox(xlog.NewLogger("test"), "usage-test", nil, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer sb.Close()
+	if sb.Usage() != nil {
+		t.Fatal("Expected no usage before the process is started")
+	}
+
+	cmd := exec.Command("sh", "-c", "sleep 10 & wait")
+	cmd.Env = append(os.Environ(), sb.Env()...)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		usage := sb.Usage()
+		if usage != nil && usage.Processes == 2 {
+			if usage.PID != cmd.Process.Pid || usage.MemoryBytes <= 0 || usage.OpenFiles <= 0 {
+				t.Errorf("Unexpected usage: %+v", usage)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timeout waiting for usage of the process tree, last: %+v", usage)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
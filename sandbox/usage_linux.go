@@ -0,0 +1,130 @@
+package sandbox
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// clockTicks /proc/<pid>/stat 中 CPU 时间的单位，Linux 上固定为 100
+const clockTicks = 100
+
+// procStat /proc/<pid>/stat 中需要的字段
+type procStat struct {
+	pid      int
+	ppid     int
+	cpuTicks uint64 // utime + stime
+	rssPages int64
+	children []int
+}
+
+// usage 找到带有服务标识的网关子进程，统计它及其后代进程的资源使用
+// root 为上次找到的子进程，仍是网关的子进程时直接使用，避免读取所有进程的环境变量
+func usage(id, cgroup string, root int) *Usage {
+	procs := readProcs()
+	self := os.Getpid()
+	marker := []byte(ServiceIDEnv + "=" + id)
+
+	if p, ok := procs[root]; !ok || p.ppid != self {
+		root = 0
+		for pid, p := range procs {
+			if p.ppid == self && hasEnv(pid, marker) {
+				root = pid
+				break
+			}
+		}
+	}
+	if root == 0 {
+		return nil
+	}
+
+	for pid, p := range procs {
+		if parent, ok := procs[p.ppid]; ok {
+			parent.children = append(parent.children, pid)
+		}
+	}
+	result := &Usage{PID: root}
+	var cpuTicks uint64
+	queue := []int{root}
+	for len(queue) > 0 {
+		p := procs[queue[0]]
+		queue = append(queue[1:], p.children...)
+		result.Processes++
+		result.MemoryBytes += p.rssPages * int64(os.Getpagesize())
+		result.OpenFiles += countFds(p.pid)
+		cpuTicks += p.cpuTicks
+	}
+	result.CPUSeconds = float64(cpuTicks) / clockTicks
+	if cgroup != "" {
+		if memory, ok := cgroupMemory(cgroup); ok {
+			result.MemoryBytes = memory
+		}
+	}
+	return result
+}
+
+// readProcs 读取所有进程的 stat
+func readProcs() map[int]*procStat {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	procs := make(map[int]*procStat, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if p, ok := readStat(pid); ok {
+			procs[pid] = p
+		}
+	}
+	return procs
+}
+
+// readStat 解析 /proc/<pid>/stat，进程名可能包含空格和括号，从最后一个 ) 之后开始解析
+func readStat(pid int) (*procStat, bool) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return nil, false
+	}
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return nil, false
+	}
+	// 从 state 开始: state ppid pgrp session tty_nr tpgid flags minflt cminflt majflt cmajflt utime stime ... rss 为第 22 个
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 22 {
+		return nil, false
+	}
+	ppid, _ := strconv.Atoi(fields[1])
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	rss, _ := strconv.ParseInt(fields[21], 10, 64)
+	return &procStat{pid: pid, ppid: ppid, cpuTicks: utime + stime, rssPages: rss}, true
+}
+
+// hasEnv 进程启动时的环境变量中是否包含 entry
+func hasEnv(pid int, entry []byte) bool {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "environ"))
+	if err != nil {
+		return false
+	}
+	for _, env := range bytes.Split(data, []byte{0}) {
+		if bytes.Equal(env, entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// countFds 进程打开的文件数，没有权限时为 0
+func countFds(pid int) int {
+	entries, err := os.ReadDir(filepath.Join("/proc", strconv.Itoa(pid), "fd"))
+	if err != nil {
+		return 0
+	}
+	return len(entries)
+}
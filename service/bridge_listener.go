@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// unixBridgeURL 通过 unix socket 访问桥接器时使用的地址，主机名不参与连接
+const unixBridgeURL = "http://unix"
+
+// directHTTPClient 访问远程服务和 TCP 桥接器的客户端
+var directHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		ForceAttemptHTTP2: false,
+	},
+}
+
+// unixSocketClient 通过 unix socket 连接本地桥接器的客户端，忽略请求地址中的主机名
+func unixSocketClient(path string) *http.Client {
+	dialer := &net.Dialer{}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+}
+
+// bridgeTCPHost tcp 模式下桥接器监听的地址，只接受本机的连接，外部只能通过网关访问
+const bridgeTCPHost = "127.0.0.1"
+
+// listen 创建本地桥接器的监听，默认为配置目录下的 unix socket，tcp 模式下分配端口，调用方需持有锁
+func (s *McpService) listen() (net.Listener, error) {
+	if !s.bridgeListen.UseUnix() {
+		if s.Port == 0 {
+			port, err := s.portMgr.AllocatePort(s.portOwner())
+			if err != nil {
+				return nil, fmt.Errorf("failed to allocate port: %w", err)
+			}
+			s.Port = port
+		}
+		listener, err := net.Listen("tcp", net.JoinHostPort(bridgeTCPHost, strconv.Itoa(s.Port)))
+		if err != nil {
+			s.releasePort()
+			return nil, err
+		}
+		return listener, nil
+	}
+
+	path := s.bridgeListen.SocketPath(s.Config.Workspace, s.Name)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create socket dir: %w", err)
+	}
+	// 清理上次异常退出时残留的 socket 文件
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	s.socketPath = path
+	s.httpClient = unixSocketClient(path)
+	return listener, nil
+}
+
+// listenAddr 桥接器的监听地址，用于日志
+func (s *McpService) listenAddr() string {
+	if s.socketPath != "" {
+		return "unix:" + s.socketPath
+	}
+	return net.JoinHostPort(bridgeTCPHost, strconv.Itoa(s.Port))
+}
+
+// releaseListener 归还端口并断开到 unix socket 的空闲连接，socket 文件在监听关闭时删除，调用方需持有锁
+func (s *McpService) releaseListener() {
+	s.releasePort()
+	if s.httpClient != nil {
+		s.httpClient.CloseIdleConnections()
+		s.httpClient = nil
+	}
+	s.socketPath = ""
+}
+
+// HTTPClient 访问服务地址使用的客户端，unix socket 桥接器通过 socket 连接
+func (s *McpService) HTTPClient() *http.Client {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.httpClient != nil {
+		return s.httpClient
+	}
+	return directHTTPClient
+}
+
+// abandonListener 关闭被放弃的启动创建的监听，socket 文件已被新的启动使用时保留，调用方需持有锁
+func (s *McpService) abandonListener(listener net.Listener, socketPath string) {
+	if unixListener, ok := listener.(*net.UnixListener); ok {
+		unixListener.SetUnlinkOnClose(false)
+	}
+	_ = listener.Close()
+	if socketPath != "" && socketPath != s.socketPath {
+		_ = os.Remove(socketPath)
+	}
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// HealthState 主动健康检查的结果
+type HealthState string
+
+const (
+	HealthUnknown   HealthState = "unknown"   // 尚未探测或服务未运行
+	HealthHealthy   HealthState = "healthy"   // 最近一次探测成功
+	HealthDegraded  HealthState = "degraded"  // 探测失败但未达到失败次数，或探测耗时过长
+	HealthUnhealthy HealthState = "unhealthy" // 连续失败达到失败次数
+)
+
+// HealthStatus 主动健康检查的状态
+type HealthStatus struct {
+	State               HealthState `json:"state"`
+	LatencyMs           int64       `json:"latency_ms"` // 最近一次成功探测的耗时
+	ConsecutiveFailures int         `json:"consecutive_failures"`
+	LastError           string      `json:"last_error,omitempty"`
+	LastCheckAt         time.Time   `json:"last_check_at,omitempty"`
+	LastSuccessAt       time.Time   `json:"last_success_at,omitempty"`
+}
+
+// ServiceHealth 服务的存活和就绪状态
+type ServiceHealth struct {
+	Name   string       `json:"name"`
+	Status CmdStatus    `json:"status"`
+	Live   bool         `json:"live"`  // 服务正在运行且未被判定为不健康
+	Ready  bool         `json:"ready"` // 服务正在运行且最近一次探测成功，关闭健康检查或隔离服务只要求正在运行
+	Health HealthStatus `json:"health"`
+}
+
+// Health 返回服务的存活和就绪状态
+func (s *McpService) Health() ServiceHealth {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.healthLocked()
+}
+
+func (s *McpService) healthLocked() ServiceHealth {
+	health := s.health
+	if health.State == "" {
+		health.State = HealthUnknown
+	}
+	running := s.Status == Running
+	ready := running && (health.State == HealthHealthy || health.State == HealthDegraded)
+	// 隔离服务本身没有子进程，由各个实例探测
+	if !s.Config.HealthCheck.Enabled() || s.Config.Isolation.Isolated() {
+		ready = running
+	}
+	return ServiceHealth{
+		Name:   s.Name,
+		Status: s.Status,
+		Live:   (running || s.Status == Starting) && health.State != HealthUnhealthy,
+		Ready:  ready,
+		Health: health,
+	}
+}
+
+// startHealthCheck 服务启动后开始定期探测，调用方需持有锁
+func (s *McpService) startHealthCheck(logger xlog.Logger) {
+	s.stopHealthCheck()
+	s.health = HealthStatus{State: HealthUnknown}
+	if !s.Config.HealthCheck.Enabled() {
+		return
+	}
+	stop := make(chan struct{})
+	s.healthStop = stop
+	go s.runHealthCheck(logger, stop, s.Config.HealthCheck)
+}
+
+// stopHealthCheck 停止定期探测，调用方需持有锁
+func (s *McpService) stopHealthCheck() {
+	if s.healthStop != nil {
+		close(s.healthStop)
+		s.healthStop = nil
+	}
+	s.health.State = HealthUnknown
+}
+
+// runHealthCheck 启动后立即探测一次，之后按间隔探测，直到 stop 关闭
+// 探测使用独立的 MCP 客户端，经过与会话相同的地址，同时覆盖桥接器和上游服务
+func (s *McpService) runHealthCheck(logger xlog.Logger, stop <-chan struct{}, cfg *config.HealthCheckConfig) {
+	var cli *client.Client
+	defer func() {
+		if cli != nil {
+			cli.Close()
+		}
+	}()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout())
+		start := time.Now()
+		err := s.probe(ctx, &cli, cfg)
+		latency := time.Since(start)
+		cancel()
+		if err != nil && cli != nil {
+			// 下次探测重新建立连接
+			cli.Close()
+			cli = nil
+		}
+		if !s.recordProbe(logger, stop, cfg, latency, err) {
+			return
+		}
+		timer.Reset(cfg.Interval())
+	}
+}
+
+// probe 发送 ping，配置了 canaryTool 时再调用该工具，*cli 为空时先建立连接
+func (s *McpService) probe(ctx context.Context, cli **client.Client, cfg *config.HealthCheckConfig) error {
+	if *cli == nil {
+		probeClient, err := s.newProbeClient(ctx)
+		if err != nil {
+			return err
+		}
+		*cli = probeClient
+	}
+	if err := (*cli).Ping(ctx); err != nil {
+		return fmt.Errorf("ping: %w", err)
+	}
+
+	tool, arguments := cfg.GetCanaryTool()
+	if tool == "" {
+		return nil
+	}
+	request := mcp.CallToolRequest{}
+	request.Params.Name = tool
+	request.Params.Arguments = arguments
+	result, err := (*cli).CallTool(ctx, request)
+	if err != nil {
+		return fmt.Errorf("canary tool %s: %w", tool, err)
+	}
+	if result.IsError {
+		return fmt.Errorf("canary tool %s returned an error", tool)
+	}
+	return nil
+}
+
+// newProbeClient 连接服务并完成初始化，优先使用 SSE
+func (s *McpService) newProbeClient(ctx context.Context) (*client.Client, error) {
+	var cli *client.Client
+	var err error
+	if sseUrl := s.GetSSEUrl(); sseUrl != "" {
+		cli, err = client.NewSSEMCPClient(sseUrl, transport.WithHeaders(s.GetUpstreamHeaders()), transport.WithHTTPClient(s.HTTPClient()))
+	} else if streamUrl := s.GetStreamableHTTPUrl(); streamUrl != "" {
+		cli, err = client.NewStreamableHttpClient(streamUrl, transport.WithHTTPHeaders(s.GetUpstreamHeaders()), transport.WithHTTPBasicClient(s.HTTPClient()))
+	} else {
+		return nil, fmt.Errorf("service %s has no reachable endpoint", s.Name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create probe client: %w", err)
+	}
+	if err := cli.Start(ctx); err != nil {
+		cli.Close()
+		return nil, fmt.Errorf("start probe client: %w", err)
+	}
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{
+		Name:    "mcp-gateway-health-check",
+		Version: "1.0.0",
+	}
+	if _, err := cli.Initialize(ctx, initRequest); err != nil {
+		cli.Close()
+		return nil, fmt.Errorf("initialize probe client: %w", err)
+	}
+	return cli, nil
+}
+
+// recordProbe 更新探测结果，连续失败达到失败次数时交给重启流程处理，返回是否继续探测
+func (s *McpService) recordProbe(logger xlog.Logger, stop <-chan struct{}, cfg *config.HealthCheckConfig, latency time.Duration, err error) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// 服务已被停止或重启
+	if s.healthStop != stop || s.Status != Running {
+		return false
+	}
+
+	now := time.Now()
+	s.health.LastCheckAt = now
+	if err == nil {
+		s.health.ConsecutiveFailures = 0
+		s.health.LastError = ""
+		s.health.LastSuccessAt = now
+		s.health.LatencyMs = latency.Milliseconds()
+		s.health.State = HealthHealthy
+		if degraded := cfg.DegradedLatency(); degraded > 0 && latency > degraded {
+			s.health.State = HealthDegraded
+		}
+		return true
+	}
+
+	s.health.ConsecutiveFailures++
+	s.health.LastError = err.Error()
+	logger.Warnf("Health check of service %s failed (%d/%d): %v", s.Name, s.health.ConsecutiveFailures, cfg.GetFailureThreshold(), err)
+	if s.health.ConsecutiveFailures < cfg.GetFailureThreshold() {
+		s.health.State = HealthDegraded
+		return true
+	}
+	s.health.State = HealthUnhealthy
+	// 没有本地桥接器的远程服务无法重启，继续探测等待恢复
+	if s.bridge == nil {
+		return true
+	}
+	s.recoverLocked(logger, CrashRecord{
+		Reason:   CrashReasonHealthCheck,
+		ExitCode: -1,
+		Error:    err.Error(),
+	}, fmt.Sprintf("health check failed %d times: %v", s.health.ConsecutiveFailures, err), "Health check failed")
+	return false
+}
+
+// WorkspaceServiceHealth 工作空间中单个服务的健康状态
+type WorkspaceServiceHealth struct {
+	Workspace string `json:"workspace"`
+	ServiceHealth
+}
+
+// GatewayHealth 网关下所有服务的健康状态，手动停止的服务不影响就绪
+type GatewayHealth struct {
+	Ready    bool                     `json:"ready"`
+	Services []WorkspaceServiceHealth `json:"services"`
+}
+
+// CheckHealth 汇总所有工作空间中服务的健康状态，按工作空间和服务名称排序
+func (s *ServiceManager) CheckHealth(logger xlog.Logger) GatewayHealth {
+	result := GatewayHealth{Ready: true, Services: make([]WorkspaceServiceHealth, 0)}
+	workspaces := s.workSpaceMgr.GetWorkspaces()
+	for _, workId := range sortedKeys(workspaces) {
+		services := workspaces[workId].getMcpServices()
+		for _, name := range sortedKeys(services) {
+			health := services[name].Health()
+			if health.Status != Stopped && !health.Ready {
+				result.Ready = false
+			}
+			result.Services = append(result.Services, WorkspaceServiceHealth{Workspace: workId, ServiceHealth: health})
+		}
+	}
+	return result
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+func newProbedService(cfg *config.HealthCheckConfig) (*McpService, chan struct{}) {
+	stop := make(chan struct{})
+	return &McpService{
+		Name:          "probe-service",
+		Status:        Running,
+		RetryCount:    3,
+		RetryMax:      3,
+		LastStartedAt: time.Now(),
+		bridge:        &fakeProcessBridge{},
+		Config:        config.MCPServerConfig{Command: "invalid-command", HealthCheck: cfg},
+		healthStop:    stop,
+	}, stop
+}
+
+func TestMcpService_RecordProbe(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	cfg := &config.HealthCheckConfig{FailureThreshold: 2, DegradedLatencyMs: 100}
+	service, stop := newProbedService(cfg)
+
+	if health := service.Health(); health.Ready || health.Health.State != HealthUnknown {
+		t.Fatalf("Expected service not to be ready before the first probe, got %+v", health)
+	}
+
+	if !service.recordProbe(xl, stop, cfg, 10*time.Millisecond, nil) {
+		t.Fatal("Expected probing to continue after a success")
+	}
+	if health := service.Health(); !health.Ready || health.Health.State != HealthHealthy || health.Health.LatencyMs != 10 {
+		t.Fatalf("Expected healthy service, got %+v", health)
+	}
+
+	service.recordProbe(xl, stop, cfg, 200*time.Millisecond, nil)
+	if health := service.Health(); !health.Ready || health.Health.State != HealthDegraded {
+		t.Fatalf("Expected slow probe to degrade the service, got %+v", health)
+	}
+
+	// 未达到失败次数时仍然就绪
+	if !service.recordProbe(xl, stop, cfg, 0, errors.New("ping: timeout")) {
+		t.Fatal("Expected probing to continue below the failure threshold")
+	}
+	if health := service.Health(); !health.Live || health.Health.State != HealthDegraded || health.Health.ConsecutiveFailures != 1 {
+		t.Fatalf("Expected degraded service after one failure, got %+v", health)
+	}
+
+	// 达到失败次数后交给重启流程
+	if service.recordProbe(xl, stop, cfg, 0, errors.New("ping: timeout")) {
+		t.Fatal("Expected probing to stop after the service is restarted")
+	}
+	crashes := service.CrashHistory()
+	if len(crashes) != 1 || crashes[0].Reason != CrashReasonHealthCheck {
+		t.Fatalf("Unexpected crash history: %+v", crashes)
+	}
+	info := service.Info()
+	if info.Status != Failed || info.FailureReason != "Health check failed" || info.NextRestartAt.IsZero() {
+		t.Errorf("Expected a scheduled restart, got %s (%s) next=%v", info.Status, info.FailureReason, info.NextRestartAt)
+	}
+	if health := service.Health(); health.Live || health.Ready {
+		t.Errorf("Expected failed service to be neither live nor ready, got %+v", health)
+	}
+
+	// 旧的探测结果不再生效
+	if service.recordProbe(xl, stop, cfg, 0, nil) {
+		t.Error("Expected stale probe to be ignored")
+	}
+	service.Stop(xl)
+}
+
+func TestMcpService_HealthCheckDisabled(t *testing.T) {
+	service, _ := newProbedService(&config.HealthCheckConfig{Disabled: true})
+	if health := service.Health(); !health.Ready || !health.Live {
+		t.Errorf("Expected running service to be ready without health checks, got %+v", health)
+	}
+}
+
+func TestMcpService_RemoteHealthCheck(t *testing.T) {
+	ts, _ := newRemoteStreamableServer(t)
+	logger := xlog.NewLogger("test")
+
+	svc := NewMcpService("remote", config.MCPServerConfig{
+		URL:         ts.URL,
+		HealthCheck: &config.HealthCheckConfig{CanaryTool: "echo"},
+	}, mockPortMgr)
+	if err := svc.Start(logger); err != nil {
+		t.Fatalf("Failed to start remote service: %v", err)
+	}
+	defer svc.Stop(logger)
+	waitHealth(t, svc, func(h ServiceHealth) bool { return h.Ready && h.Health.State == HealthHealthy })
+
+	// 远程服务无法重启，canary 工具失败后标记为不健康并继续探测
+	broken := NewMcpService("broken", config.MCPServerConfig{
+		URL:         ts.URL,
+		HealthCheck: &config.HealthCheckConfig{CanaryTool: "missing", FailureThreshold: 1},
+	}, mockPortMgr)
+	if err := broken.Start(logger); err != nil {
+		t.Fatalf("Failed to start remote service: %v", err)
+	}
+	defer broken.Stop(logger)
+	health := waitHealth(t, broken, func(h ServiceHealth) bool { return h.Health.State == HealthUnhealthy })
+	if health.Live || health.Ready || health.Status != Running || health.Health.LastError == "" {
+		t.Errorf("Unexpected health of broken service: %+v", health)
+	}
+}
+
+// waitHealth 等待服务的健康状态满足条件
+func waitHealth(t *testing.T, svc *McpService, cond func(ServiceHealth) bool) ServiceHealth {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		health := svc.Health()
+		if cond(health) {
+			return health
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timeout waiting for health state, last: %+v", health)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
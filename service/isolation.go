@@ -0,0 +1,322 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/sandbox"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+// ErrPoolExhausted 隔离服务的子进程数已达到上限
+var ErrPoolExhausted = errors.New("isolation pool exhausted")
+
+// IsolationInfo per-session 和 pooled 服务的子进程
+type IsolationInfo struct {
+	Mode       config.MCPIsolation `json:"mode"`
+	PoolSize   int                 `json:"pool_size"` // 0 表示不限制
+	WarmSpares int                 `json:"warm_spares"`
+	Instances  []InstanceInfo      `json:"instances"`
+}
+
+// InstanceInfo 为会话启动的子进程
+type InstanceInfo struct {
+	Name      string         `json:"name"`
+	Session   string         `json:"session,omitempty"` // 为空时空闲
+	Status    CmdStatus      `json:"status"`
+	IdleSince time.Time      `json:"idle_since,omitempty"`
+	Usage     *sandbox.Usage `json:"usage,omitempty"`
+}
+
+type idleInstance struct {
+	instance *McpService
+	since    time.Time
+}
+
+// instancePool 为会话分配独立的子进程，每个子进程是一个以 name@序号 命名的服务实例
+// per-session 时会话关闭即停止子进程；pooled 时归还到空闲列表供后续会话复用，空闲超时后停止
+// 配置 warmSpares 时预先启动空闲子进程，新会话无需等待子进程启动
+type instancePool struct {
+	parent      *McpService
+	mode        config.MCPIsolation
+	size        int
+	warmSpares  int
+	idleTimeout time.Duration
+
+	mu       sync.Mutex
+	seq      int
+	starting int
+	leased   map[string]*McpService // 会话 ID -> 实例
+	idle     []idleInstance
+	closed   bool
+	stop     chan struct{}
+	refill   chan struct{} // 空闲子进程被取走或停止后通知补充
+}
+
+func newInstancePool(parent *McpService) *instancePool {
+	return &instancePool{
+		parent:      parent,
+		mode:        parent.Config.Isolation.GetMode(),
+		size:        parent.Config.Isolation.GetPoolSize(),
+		warmSpares:  parent.Config.Isolation.GetWarmSpares(),
+		idleTimeout: parent.Config.Isolation.IdleTimeout(),
+		leased:      make(map[string]*McpService),
+		stop:        make(chan struct{}),
+		refill:      make(chan struct{}, 1),
+	}
+}
+
+// acquire 为会话分配子进程，pooled 时优先复用空闲的子进程，会话已分配时返回原来的子进程
+func (p *instancePool) acquire(logger xlog.Logger, sessionId string) (*McpService, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("service %s is stopped", p.parent.Name)
+	}
+	if instance, ok := p.leased[sessionId]; ok {
+		p.mu.Unlock()
+		return instance, nil
+	}
+	var stale []*McpService
+	for len(p.idle) > 0 {
+		// 优先复用最近归还的子进程，较早归还的更快空闲超时
+		instance := p.idle[len(p.idle)-1].instance
+		p.idle = p.idle[:len(p.idle)-1]
+		if instance.GetStatus() != Running {
+			stale = append(stale, instance)
+			continue
+		}
+		p.leased[sessionId] = instance
+		p.mu.Unlock()
+		stopInstances(logger, stale)
+		p.notifyRefill()
+		logger.Infof("Assigned idle %s to session %s", instance.Name, sessionId)
+		return instance, nil
+	}
+	if p.size > 0 && len(p.leased)+p.starting >= p.size {
+		p.mu.Unlock()
+		stopInstances(logger, stale)
+		return nil, fmt.Errorf("service %s: %w (%d)", p.parent.Name, ErrPoolExhausted, p.size)
+	}
+	p.seq++
+	p.starting++
+	instance := p.parent.newInstance(fmt.Sprintf("%s@%d", p.parent.Name, p.seq))
+	p.mu.Unlock()
+	stopInstances(logger, stale)
+
+	logger.Infof("Starting %s for session %s", instance.Name, sessionId)
+	err := instance.Start(logger)
+
+	p.mu.Lock()
+	p.starting--
+	if err == nil && p.closed {
+		err = fmt.Errorf("service %s is stopped", p.parent.Name)
+	}
+	if err != nil {
+		p.mu.Unlock()
+		_ = instance.Stop(logger)
+		return nil, fmt.Errorf("failed to start %s: %w", instance.Name, err)
+	}
+	p.leased[sessionId] = instance
+	p.mu.Unlock()
+	return instance, nil
+}
+
+// release 会话关闭后释放子进程，pooled 时仍在运行的子进程归还到空闲列表
+func (p *instancePool) release(logger xlog.Logger, sessionId string) {
+	p.mu.Lock()
+	instance, ok := p.leased[sessionId]
+	if !ok {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.leased, sessionId)
+	if p.mode == config.IsolationPooled && !p.closed && instance.GetStatus() == Running {
+		p.idle = append(p.idle, idleInstance{instance: instance, since: time.Now()})
+		p.mu.Unlock()
+		logger.Infof("Returned %s to pool", instance.Name)
+		return
+	}
+	p.mu.Unlock()
+	stopInstances(logger, []*McpService{instance})
+	p.notifyRefill()
+}
+
+// reapIdle 停止空闲超过 idleTimeout 的子进程，保留最近空闲的 warmSpares 个
+func (p *instancePool) reapIdle(logger xlog.Logger, now time.Time) {
+	p.mu.Lock()
+	var expired []*McpService
+	reapable := len(p.idle) - p.warmSpares
+	kept := p.idle[:0]
+	for i, idle := range p.idle {
+		if i < reapable && now.Sub(idle.since) >= p.idleTimeout {
+			expired = append(expired, idle.instance)
+		} else {
+			kept = append(kept, idle)
+		}
+	}
+	p.idle = kept
+	p.mu.Unlock()
+	for _, instance := range expired {
+		logger.Infof("Reaping idle %s", instance.Name)
+	}
+	stopInstances(logger, expired)
+}
+
+// run 补充预先启动的空闲子进程，pooled 时定期回收空闲的子进程，直到 close
+func (p *instancePool) run(logger xlog.Logger) {
+	var reap <-chan time.Time
+	if p.mode == config.IsolationPooled {
+		ticker := time.NewTicker(max(p.idleTimeout/2, time.Second))
+		defer ticker.Stop()
+		reap = ticker.C
+	}
+	p.fillSpares(logger)
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-p.refill:
+			p.fillSpares(logger)
+		case now := <-reap:
+			p.reapIdle(logger, now)
+		}
+	}
+}
+
+// notifyRefill 通知 run 补充空闲子进程
+func (p *instancePool) notifyRefill() {
+	if p.warmSpares == 0 {
+		return
+	}
+	select {
+	case p.refill <- struct{}{}:
+	default:
+	}
+}
+
+// fillSpares 依次启动子进程直到空闲子进程达到 warmSpares 个或达到上限，启动失败时等待下次通知
+func (p *instancePool) fillSpares(logger xlog.Logger) {
+	for {
+		p.mu.Lock()
+		total := len(p.leased) + len(p.idle) + p.starting
+		if p.closed || len(p.idle) >= p.warmSpares || (p.size > 0 && total >= p.size) {
+			p.mu.Unlock()
+			return
+		}
+		p.seq++
+		p.starting++
+		instance := p.parent.newInstance(fmt.Sprintf("%s@%d", p.parent.Name, p.seq))
+		p.mu.Unlock()
+
+		logger.Infof("Starting warm spare %s", instance.Name)
+		err := instance.Start(logger)
+
+		p.mu.Lock()
+		p.starting--
+		if err == nil && !p.closed {
+			p.idle = append(p.idle, idleInstance{instance: instance, since: time.Now()})
+			p.mu.Unlock()
+			continue
+		}
+		p.mu.Unlock()
+		if err != nil {
+			logger.Errorf("Failed to start warm spare %s: %v", instance.Name, err)
+		}
+		_ = instance.Stop(logger)
+		return
+	}
+}
+
+// close 停止所有子进程，之后不再分配
+func (p *instancePool) close(logger xlog.Logger) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.stop)
+	instances := make([]*McpService, 0, len(p.leased)+len(p.idle))
+	for _, instance := range p.leased {
+		instances = append(instances, instance)
+	}
+	for _, idle := range p.idle {
+		instances = append(instances, idle.instance)
+	}
+	p.leased = make(map[string]*McpService)
+	p.idle = nil
+	p.mu.Unlock()
+	stopInstances(logger, instances)
+}
+
+// info 返回所有子进程的状态，按名称排序
+func (p *instancePool) info() *IsolationInfo {
+	type entry struct {
+		info     InstanceInfo
+		instance *McpService
+	}
+	p.mu.Lock()
+	entries := make([]entry, 0, len(p.leased)+len(p.idle))
+	for sessionId, instance := range p.leased {
+		entries = append(entries, entry{InstanceInfo{Name: instance.Name, Session: sessionId}, instance})
+	}
+	for _, idle := range p.idle {
+		entries = append(entries, entry{InstanceInfo{Name: idle.instance.Name, IdleSince: idle.since}, idle.instance})
+	}
+	p.mu.Unlock()
+
+	instances := make([]InstanceInfo, 0, len(entries))
+	for _, e := range entries {
+		info := e.instance.Info()
+		e.info.Status = info.Status
+		e.info.Usage = info.Usage
+		instances = append(instances, e.info)
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].Name < instances[j].Name })
+	return &IsolationInfo{Mode: p.mode, PoolSize: p.size, WarmSpares: p.warmSpares, Instances: instances}
+}
+
+func stopInstances(logger xlog.Logger, instances []*McpService) {
+	for _, instance := range instances {
+		if err := instance.Stop(logger); err != nil {
+			logger.Errorf("Failed to stop %s: %v", instance.Name, err)
+		}
+	}
+}
+
+// newInstance 创建与服务配置相同的实例，用于为会话启动独立的子进程
+func (s *McpService) newInstance(name string) *McpService {
+	cfg := s.Config
+	cfg.Isolation = nil
+	instance := NewMcpService(name, cfg, s.portMgr)
+	instance.envResolver = s.envResolver
+	instance.bridgeListen = s.bridgeListen
+	instance.RetryMax = s.RetryMax
+	return instance
+}
+
+// acquireInstance 为会话分配独立的子进程，服务未运行时返回错误
+func (s *McpService) acquireInstance(logger xlog.Logger, sessionId string) (*McpService, error) {
+	s.mutex.RLock()
+	pool := s.pool
+	s.mutex.RUnlock()
+	if pool == nil {
+		return nil, fmt.Errorf("service %s is not running", s.Name)
+	}
+	return pool.acquire(logger, sessionId)
+}
+
+// releaseInstance 会话关闭后释放分配的子进程
+func (s *McpService) releaseInstance(logger xlog.Logger, sessionId string) {
+	s.mutex.RLock()
+	pool := s.pool
+	s.mutex.RUnlock()
+	if pool != nil {
+		pool.release(logger, sessionId)
+	}
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/sandbox"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// testStdioServerEnv 设置后测试进程作为 stdio MCP 服务运行，pid 工具返回进程号
+	testStdioServerEnv = "SERVICE_TEST_STDIO_SERVER"
+	// testStdioDelayEnv stdio 服务开始响应前等待的时间，模拟首次运行时下载依赖包
+	testStdioDelayEnv = "SERVICE_TEST_STDIO_DELAY"
+)
+
+func TestMain(m *testing.M) {
+	if sandbox.IsLauncher() {
+		sandbox.RunLauncher()
+	}
+	if os.Getenv(testStdioServerEnv) == "1" {
+		if delay, err := time.ParseDuration(os.Getenv(testStdioDelayEnv)); err == nil {
+			time.Sleep(delay)
+		}
+		mcpServer := server.NewMCPServer("fake-stdio", "1.0.0", server.WithToolCapabilities(true))
+		mcpServer.AddTool(mcp.NewTool("pid"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultText(strconv.Itoa(os.Getpid())), nil
+		})
+		_ = server.ServeStdio(mcpServer)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestStdioService 创建以测试进程作为 stdio MCP 服务的服务
+func newTestStdioService(t *testing.T, name string, env map[string]string) *McpService {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to locate test binary: %v", err)
+	}
+	envs := map[string]string{testStdioServerEnv: "1"}
+	for k, v := range env {
+		envs[k] = v
+	}
+	svc := NewMcpService(name, config.MCPServerConfig{
+		Workspace:   "default",
+		Command:     exe,
+		Env:         envs,
+		HealthCheck: &config.HealthCheckConfig{Disabled: true},
+		LogConfig:   config.LogConfig{Path: t.TempDir()},
+	}, mockPortMgr)
+	svc.bridgeListen = config.BridgeListenConfig{Network: config.BridgeNetworkUnix, SocketDir: t.TempDir()}
+	return svc
+}
+
+// newIsolatedService 创建以测试进程作为 stdio MCP 服务的隔离服务
+func newIsolatedService(t *testing.T, isolation *config.IsolationConfig) *McpService {
+	svc := newTestStdioService(t, "stateful", nil)
+	svc.Config.Isolation = isolation
+	return svc
+}
+
+func TestMcpService_PerSessionIsolation(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	svc := newIsolatedService(t, &config.IsolationConfig{Mode: config.IsolationPerSession})
+	if err := svc.Start(xl); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer svc.Stop(xl)
+	if svc.GetSSEUrl() != "" || !svc.Health().Ready {
+		t.Fatalf("Expected isolated service to be ready without its own process")
+	}
+
+	sessions := make([]*Session, 2)
+	for i := range sessions {
+		sessions[i] = NewSession("session-" + strconv.Itoa(i))
+		if err := subscribeService(xl, sessions[i], svc); err != nil {
+			t.Fatalf("Failed to subscribe session %d: %v", i, err)
+		}
+		sessions[i].mu.RLock()
+		_, ok := sessions[i].mcpClients[svc.Name]
+		sessions[i].mu.RUnlock()
+		if !ok {
+			t.Fatalf("Expected session %d to connect to its process", i)
+		}
+	}
+
+	info := svc.Info().Isolation
+	if info == nil || len(info.Instances) != 2 {
+		t.Fatalf("Expected one process per session, got %+v", info)
+	}
+	if first, second := sessionPid(t, sessions[0], svc), sessionPid(t, sessions[1], svc); first == second {
+		t.Fatalf("Expected sessions to use different processes, both got pid %s", first)
+	}
+
+	// 会话关闭时结束对应的子进程
+	instance, _ := svc.acquireInstance(xl, sessions[0].Id)
+	sessions[0].Close()
+	if instance.GetStatus() != Stopped {
+		t.Errorf("Expected process of closed session to be stopped, got %s", instance.GetStatus())
+	}
+	if info := svc.Info().Isolation; len(info.Instances) != 1 || info.Instances[0].Session != sessions[1].Id {
+		t.Errorf("Unexpected instances after closing a session: %+v", info.Instances)
+	}
+
+	// 停止服务时结束所有子进程
+	instance, _ = svc.acquireInstance(xl, sessions[1].Id)
+	svc.Stop(xl)
+	if instance.GetStatus() != Stopped {
+		t.Errorf("Expected all processes to be stopped with the service, got %s", instance.GetStatus())
+	}
+	sessions[1].Close()
+}
+
+func TestMcpService_PooledIsolation(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	svc := newIsolatedService(t, &config.IsolationConfig{Mode: config.IsolationPooled, PoolSize: 1, IdleTimeoutSeconds: 60})
+	if err := svc.Start(xl); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer svc.Stop(xl)
+
+	first, err := svc.acquireInstance(xl, "a")
+	if err != nil {
+		t.Fatalf("Failed to acquire process: %v", err)
+	}
+	if _, err := svc.acquireInstance(xl, "b"); !errors.Is(err, ErrPoolExhausted) {
+		t.Fatalf("Expected pool to be exhausted, got %v", err)
+	}
+
+	// 归还后复用同一个子进程
+	svc.releaseInstance(xl, "a")
+	if first.GetStatus() != Running {
+		t.Fatalf("Expected released process to stay running, got %s", first.GetStatus())
+	}
+	second, err := svc.acquireInstance(xl, "b")
+	if err != nil || second != first {
+		t.Fatalf("Expected idle process to be reused, got %v (%v)", second, err)
+	}
+
+	// 空闲超时后回收
+	svc.releaseInstance(xl, "b")
+	svc.mutex.RLock()
+	pool := svc.pool
+	svc.mutex.RUnlock()
+	pool.reapIdle(xl, time.Now())
+	if first.GetStatus() != Running {
+		t.Fatal("Expected process not to be reaped before idle timeout")
+	}
+	pool.reapIdle(xl, time.Now().Add(time.Minute))
+	if first.GetStatus() != Stopped {
+		t.Errorf("Expected idle process to be reaped, got %s", first.GetStatus())
+	}
+	if info := svc.Info().Isolation; len(info.Instances) != 0 {
+		t.Errorf("Expected no processes after reaping, got %+v", info.Instances)
+	}
+}
+
+func TestMcpService_WarmSpares(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	svc := newIsolatedService(t, &config.IsolationConfig{Mode: config.IsolationPerSession, PoolSize: 2, WarmSpares: 1})
+	if err := svc.Start(xl); err != nil {
+		t.Fatalf("Failed to start service: %v", err)
+	}
+	defer svc.Stop(xl)
+
+	spare := waitIdleInstances(t, svc, 1)[0]
+	instance, err := svc.acquireInstance(xl, "a")
+	if err != nil || instance.Name != spare.Name {
+		t.Fatalf("Expected session to use the warm spare %s, got %v (%v)", spare.Name, instance, err)
+	}
+
+	// 取走后补充新的空闲子进程，达到上限后不再补充
+	next := waitIdleInstances(t, svc, 1)[0]
+	if next.Name == spare.Name {
+		t.Fatalf("Expected a new warm spare, got %s", next.Name)
+	}
+	if _, err := svc.acquireInstance(xl, "b"); err != nil {
+		t.Fatalf("Failed to acquire process: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if info := svc.Info().Isolation; len(info.Instances) != 2 {
+		t.Errorf("Expected pool size to limit warm spares, got %+v", info.Instances)
+	}
+
+	// per-session 会话关闭后子进程停止，空出的位置用于补充空闲子进程
+	svc.releaseInstance(xl, "a")
+	if instance.GetStatus() != Stopped {
+		t.Errorf("Expected process of closed session to be stopped, got %s", instance.GetStatus())
+	}
+	waitIdleInstances(t, svc, 1)
+}
+
+// sessionPid 通过会话连接的子进程调用 pid 工具，返回子进程的 pid
+func sessionPid(t *testing.T, session *Session, svc *McpService) string {
+	t.Helper()
+	session.mu.RLock()
+	cli := session.mcpClients[svc.Name]
+	session.mu.RUnlock()
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "pid"
+	result, err := cli.CallTool(context.Background(), request)
+	if err != nil || len(result.Content) == 0 {
+		t.Fatalf("Failed to call pid tool: %v", err)
+	}
+	text, _ := result.Content[0].(mcp.TextContent)
+	return text.Text
+}
+
+// waitIdleInstances 等待隔离服务的空闲子进程达到 n 个
+func waitIdleInstances(t *testing.T, svc *McpService, n int) []InstanceInfo {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var idle []InstanceInfo
+		for _, instance := range svc.Info().Isolation.Instances {
+			if instance.Session == "" && instance.Status == Running {
+				idle = append(idle, instance)
+			}
+		}
+		if len(idle) == n {
+			return idle
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timeout waiting for %d idle processes, got %+v", n, svc.Info().Isolation.Instances)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+// PlanAction 应用工作空间清单时对单个服务的操作
+type PlanAction string
+
+const (
+	PlanActionAdd       PlanAction = "add"       // 清单中新增的服务
+	PlanActionReplace   PlanAction = "replace"   // 配置变化或已失败的服务，停止后按清单重新部署
+	PlanActionRemove    PlanAction = "remove"    // 清单中不存在的服务
+	PlanActionUnchanged PlanAction = "unchanged" // 配置未变化
+)
+
+// PlanItem 单个服务的计划和执行结果
+type PlanItem struct {
+	Name    string     `json:"name"`
+	Action  PlanAction `json:"action"`
+	Changes []string   `json:"changes,omitempty"` // replace 时发生变化的配置字段
+	Reason  string     `json:"reason,omitempty"`
+	Applied bool       `json:"applied"`         // 是否已执行，dry-run 时总是 false
+	Error   string     `json:"error,omitempty"` // 执行失败的原因
+}
+
+// WorkspacePlan 工作空间清单与当前服务的差异，按服务名称排序
+type WorkspacePlan struct {
+	Workspace string     `json:"workspace"`
+	Items     []PlanItem `json:"items"`
+}
+
+// HasChanges 计划中是否有需要执行的操作
+func (p WorkspacePlan) HasChanges() bool {
+	for _, item := range p.Items {
+		if item.Action != PlanActionUnchanged {
+			return true
+		}
+	}
+	return false
+}
+
+// Failed 执行失败的操作数
+func (p WorkspacePlan) Failed() int {
+	failed := 0
+	for _, item := range p.Items {
+		if item.Error != "" {
+			failed++
+		}
+	}
+	return failed
+}
+
+// PlanManifest 计算清单与工作空间当前服务的差异: 新增、配置变化时替换、清单中不存在时删除
+func (w *WorkSpace) PlanManifest(servers map[string]config.MCPServerConfig) WorkspacePlan {
+	return planManifest(w.Id, w.getMcpServices(), servers)
+}
+
+// planManifest 计算清单与当前服务集合的差异，工作空间不存在时 current 为空，清单中的服务都是新增
+func planManifest(workspace string, current map[string]*McpService, servers map[string]config.MCPServerConfig) WorkspacePlan {
+	plan := WorkspacePlan{Workspace: workspace, Items: make([]PlanItem, 0)}
+
+	for _, name := range sortedKeys(servers) {
+		desired := servers[name]
+		desired.Workspace = workspace
+		instance, ok := current[name]
+		if !ok {
+			plan.Items = append(plan.Items, PlanItem{Name: name, Action: PlanActionAdd})
+			continue
+		}
+		// 按 JSON 比较，空 map 与未设置视为相同
+		changes := configChanges(instance.Config, desired)
+		switch {
+		case len(changes) > 0:
+			plan.Items = append(plan.Items, PlanItem{Name: name, Action: PlanActionReplace, Changes: changes, Reason: "config changed"})
+		case instance.GetStatus() == Failed:
+			plan.Items = append(plan.Items, PlanItem{Name: name, Action: PlanActionReplace, Reason: "service failed"})
+		default:
+			plan.Items = append(plan.Items, PlanItem{Name: name, Action: PlanActionUnchanged})
+		}
+	}
+	for _, name := range sortedKeys(current) {
+		if _, ok := servers[name]; !ok {
+			plan.Items = append(plan.Items, PlanItem{Name: name, Action: PlanActionRemove})
+		}
+	}
+	sort.SliceStable(plan.Items, func(i, j int) bool { return plan.Items[i].Name < plan.Items[j].Name })
+	return plan
+}
+
+// ApplyManifest 按计划依次执行，单个服务失败不影响其他服务，结果记录在返回的计划中
+func (w *WorkSpace) ApplyManifest(xl xlog.Logger, servers map[string]config.MCPServerConfig) WorkspacePlan {
+	plan := w.PlanManifest(servers)
+	for i := range plan.Items {
+		item := &plan.Items[i]
+		var err error
+		switch item.Action {
+		case PlanActionAdd:
+			_, err = w.AddMcpService(xl, item.Name, w.manifestServerConfig(servers[item.Name]))
+		case PlanActionReplace:
+			// 先移除再部署，AddMcpService 遇到运行中的服务不会替换
+			if err = w.removeMcpServiceInternal(xl, item.Name); err == nil {
+				_, err = w.AddMcpService(xl, item.Name, w.manifestServerConfig(servers[item.Name]))
+			}
+		case PlanActionRemove:
+			err = w.RemoveMcpService(xl, item.Name)
+		default:
+			continue
+		}
+		if err != nil {
+			xl.Errorf("Failed to %s service %s: %v", item.Action, item.Name, err)
+			item.Error = err.Error()
+			continue
+		}
+		item.Applied = true
+	}
+	return plan
+}
+
+// manifestServerConfig 补全清单中的服务配置，使其与部署后保存的配置可以直接比较
+func (w *WorkSpace) manifestServerConfig(cfg config.MCPServerConfig) config.MCPServerConfig {
+	cfg.Workspace = w.Id
+	return cfg
+}
+
+// configChanges 返回两个配置中值不同的 JSON 字段名，不包含字段值，避免泄露密钥
+func configChanges(current, desired config.MCPServerConfig) []string {
+	currentFields, desiredFields := configFields(current), configFields(desired)
+	changes := make([]string, 0)
+	for field := range currentFields {
+		if _, ok := desiredFields[field]; !ok {
+			changes = append(changes, field)
+		}
+	}
+	for field, value := range desiredFields {
+		if currentValue, ok := currentFields[field]; !ok || string(currentValue) != string(value) {
+			changes = append(changes, field)
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+func configFields(cfg config.MCPServerConfig) map[string]json.RawMessage {
+	fields := make(map[string]json.RawMessage)
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(data, &fields)
+	return fields
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+func TestWorkSpace_PlanManifest(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	workspace := NewWorkSpace("ws", config.WorkspaceConfig{Servers: make(map[string]config.MCPServerConfig)}, mockPortMgr)
+	workspace.restoreMcpService(xl, "same", ServiceRecord{Config: config.MCPServerConfig{Workspace: "ws", Command: "uvx", Args: []string{"same"}}})
+	workspace.restoreMcpService(xl, "changed", ServiceRecord{Config: config.MCPServerConfig{Workspace: "ws", Command: "uvx", Env: map[string]string{"K": "old"}}})
+	workspace.restoreMcpService(xl, "missing", ServiceRecord{Config: config.MCPServerConfig{Workspace: "ws", Command: "uvx"}})
+
+	plan := workspace.PlanManifest(map[string]config.MCPServerConfig{
+		// 清单中不需要填写 workspace，空 env 与未设置相同
+		"same":    {Command: "uvx", Args: []string{"same"}, Env: map[string]string{}},
+		"changed": {Command: "uvx", Env: map[string]string{"K": "new"}, ToolFilter: &config.ToolFilter{Deny: []string{"delete_*"}}},
+		"new":     {URL: "http://localhost:3000"},
+	})
+
+	expected := []PlanItem{
+		{Name: "changed", Action: PlanActionReplace, Changes: []string{"env", "toolFilter"}, Reason: "config changed"},
+		{Name: "missing", Action: PlanActionRemove},
+		{Name: "new", Action: PlanActionAdd},
+		{Name: "same", Action: PlanActionUnchanged},
+	}
+	if !reflect.DeepEqual(plan.Items, expected) {
+		t.Errorf("PlanManifest() = %+v, want %+v", plan.Items, expected)
+	}
+	if !plan.HasChanges() {
+		t.Error("Expected plan to have changes")
+	}
+	// 计划不修改工作空间
+	if len(workspace.GetMcpServices()) != 3 {
+		t.Error("Expected PlanManifest() to leave services untouched")
+	}
+}
+
+func TestWorkSpace_ApplyManifestRemove(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	store := NewJSONFileStore(filepath.Join(t.TempDir(), "state.json"))
+	mgr := NewWorkspaceManager(config.Config{}, mockPortMgr, store)
+	workspace, _ := mgr.GetWorkspace(xl, "ws", true)
+	workspace.restoreMcpService(xl, "keep", ServiceRecord{Config: config.MCPServerConfig{Workspace: "ws", Command: "uvx"}, DesiredState: DesiredStateStopped})
+	workspace.restoreMcpService(xl, "drop", ServiceRecord{Config: config.MCPServerConfig{Workspace: "ws", Command: "uvx"}, DesiredState: DesiredStateStopped})
+
+	plan := workspace.ApplyManifest(xl, map[string]config.MCPServerConfig{"keep": {Command: "uvx"}})
+	if plan.Failed() != 0 {
+		t.Fatalf("ApplyManifest() failed: %+v", plan.Items)
+	}
+	if item := plan.Items[0]; item.Name != "drop" || item.Action != PlanActionRemove || !item.Applied {
+		t.Errorf("Unexpected plan item: %+v", item)
+	}
+
+	services := workspace.GetMcpServices()
+	if _, ok := services["drop"]; ok || len(services) != 1 {
+		t.Errorf("Expected only keep to remain, got %v", services)
+	}
+	state, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := state.Workspaces["ws"].Services["drop"]; ok {
+		t.Error("Expected removed service to be dropped from the store")
+	}
+
+	// 再次应用时没有变化
+	if workspace.PlanManifest(map[string]config.MCPServerConfig{"keep": {Command: "uvx"}}).HasChanges() {
+		t.Error("Expected converged workspace to have no changes")
+	}
+}
+
+func TestServiceManager_PlanMissingWorkspace(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	mgr := NewServiceMgr(config.Config{SocketDir: t.TempDir()}, mockPortMgr, nil)
+	defer mgr.Close()
+
+	plan := mgr.PlanWorkspace(xl, NameArg{Workspace: "new"}, map[string]config.MCPServerConfig{
+		"a": {Command: "uvx"},
+		"b": {Command: "npx"},
+	})
+	if plan.Workspace != "new" || len(plan.Items) != 2 || plan.Items[0].Action != PlanActionAdd || plan.Items[1].Action != PlanActionAdd {
+		t.Errorf("Expected all services to be added, got %+v", plan)
+	}
+	// 只计算计划，不创建工作空间
+	if _, ok := mgr.GetWorkspaces()["new"]; ok {
+		t.Error("Expected PlanWorkspace() not to create the workspace")
+	}
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+)
+
+// DefaultNamespaceSeparator 默认的MCP名称与原始名称之间的分隔符
+const DefaultNamespaceSeparator = "_"
+
+// nameKind 需要添加前缀的名称类型，不同类型的路由表相互独立
+type nameKind string
+
+const (
+	nameKindTool     nameKind = "tool"
+	nameKindPrompt   nameKind = "prompt"
+	nameKindResource nameKind = "resource"
+)
+
+// nameRoute 对外名称对应的MCP和原始名称
+type nameRoute struct {
+	mcpName McpName
+	name    string
+}
+
+// Namespace 负责聚合会话中工具、提示词、资源的对外名称与路由
+// 聚合列表时登记 对外名称 -> (MCP, 原始名称) 的路由表，调用时按路由表查找，不依赖字符串拆分
+type Namespace struct {
+	separator string
+	aliases   map[McpName]map[string]string // 工具别名: mcpName -> toolName -> 对外名称
+
+	mu     sync.RWMutex
+	routes map[nameKind]map[string]nameRoute // 对外名称 -> 路由
+	names  map[nameKind]map[nameRoute]string // 路由 -> 对外名称
+}
+
+// NewNamespace 根据工作空间配置创建命名空间
+func NewNamespace(cfg config.NamespaceConfig) *Namespace {
+	separator := cfg.Separator
+	if separator == "" {
+		separator = DefaultNamespaceSeparator
+	}
+	return &Namespace{
+		separator: separator,
+		aliases:   cfg.Aliases,
+		routes:    make(map[nameKind]map[string]nameRoute),
+		names:     make(map[nameKind]map[nameRoute]string),
+	}
+}
+
+// Separator 获取分隔符
+func (n *Namespace) Separator() string {
+	return n.separator
+}
+
+// Prefix 为名称添加MCP前缀: mcpName{separator}name
+func (n *Namespace) Prefix(mcpName McpName, name string) string {
+	return mcpName + n.separator + name
+}
+
+// Name 计算对外名称，工具配置了别名时使用别名，否则添加MCP前缀
+func (n *Namespace) Name(kind nameKind, mcpName McpName, name string) string {
+	if kind == nameKindTool {
+		if alias := n.aliases[mcpName][name]; alias != "" {
+			return alias
+		}
+	}
+	return n.Prefix(mcpName, name)
+}
+
+// Register 登记名称并返回对外名称，已登记过的名称保持不变，对外名称与其他MCP冲突时追加序号保证唯一
+// 聚合时按MCP名称和原始名称排序登记，保证重启后对外名称不变
+func (n *Namespace) Register(kind nameKind, mcpName McpName, name string) string {
+	visible := n.Name(kind, mcpName, name)
+	target := nameRoute{mcpName: mcpName, name: name}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	routes, ok := n.routes[kind]
+	if !ok {
+		routes = make(map[string]nameRoute)
+		n.routes[kind] = routes
+		n.names[kind] = make(map[nameRoute]string)
+	}
+	if existing, ok := n.names[kind][target]; ok {
+		return existing
+	}
+
+	candidate := visible
+	for i := 2; ; i++ {
+		if _, ok := routes[candidate]; !ok {
+			routes[candidate] = target
+			n.names[kind][target] = candidate
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s%s%d", visible, n.separator, i)
+	}
+}
+
+// Visible 返回已登记的对外名称，未登记时按 Name 计算，通知和读取结果中的名称与列表中的保持一致
+func (n *Namespace) Visible(kind nameKind, mcpName McpName, name string) string {
+	n.mu.RLock()
+	visible, ok := n.names[kind][nameRoute{mcpName: mcpName, name: name}]
+	n.mu.RUnlock()
+	if ok {
+		return visible
+	}
+	return n.Name(kind, mcpName, name)
+}
+
+// Forget 清除指定MCP在某类名称下登记的全部路由，重新聚合该MCP前调用
+func (n *Namespace) Forget(kind nameKind, mcpName McpName) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for visible, route := range n.routes[kind] {
+		if route.mcpName == mcpName {
+			delete(n.routes[kind], visible)
+			delete(n.names[kind], route)
+		}
+	}
+}
+
+// Resolve 根据对外名称找到所属MCP和原始名称
+// 路由表中没有时（如客户端未先调用列表接口），依次按别名和MCP名称前缀匹配，
+// 前缀取最长匹配，兼容MCP名称或原始名称中包含分隔符的情况
+func (n *Namespace) Resolve(kind nameKind, visible string, mcpNames []McpName) (McpName, string, bool) {
+	n.mu.RLock()
+	route, ok := n.routes[kind][visible]
+	n.mu.RUnlock()
+	if ok {
+		return route.mcpName, route.name, true
+	}
+
+	if kind == nameKindTool {
+		// 按名称排序遍历，多个别名冲突时结果确定
+		for _, mcpName := range sortedKeys(n.aliases) {
+			aliases := n.aliases[mcpName]
+			for _, name := range sortedKeys(aliases) {
+				if aliases[name] == visible {
+					return mcpName, name, true
+				}
+			}
+		}
+	}
+
+	var owner McpName
+	for _, mcpName := range mcpNames {
+		if strings.HasPrefix(visible, n.Prefix(mcpName, "")) && len(mcpName) > len(owner) {
+			owner = mcpName
+		}
+	}
+	if owner == "" {
+		return "", visible, false
+	}
+	return owner, strings.TrimPrefix(visible, n.Prefix(owner, "")), true
+}
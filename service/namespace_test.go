@@ -0,0 +1,94 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+)
+
+func TestNamespace_Resolve(t *testing.T) {
+	namespace := NewNamespace(config.NamespaceConfig{
+		Aliases: map[string]map[string]string{
+			"github": {"search_repositories": "search_code"},
+			// 与 github 的别名冲突时按MCP名称排序取第一个
+			"gitlab": {"search_projects": "search_code"},
+		},
+	})
+	mcpNames := []McpName{"my", "my_server", "github"}
+
+	// 登记过的名称按路由表查找
+	if got := namespace.Register(nameKindTool, "my", "x_tool"); got != "my_x_tool" {
+		t.Fatalf("Register() = %s, want my_x_tool", got)
+	}
+
+	tests := []struct {
+		name        string
+		visible     string
+		wantMcp     McpName
+		wantName    string
+		wantResolve bool
+	}{
+		{name: "registered tool starting with prefix-like segment", visible: "my_x_tool", wantMcp: "my", wantName: "x_tool", wantResolve: true},
+		{name: "server name containing separator", visible: "my_server_read", wantMcp: "my_server", wantName: "read", wantResolve: true},
+		{name: "alias", visible: "search_code", wantMcp: "github", wantName: "search_repositories", wantResolve: true},
+		{name: "unknown server", visible: "other_tool", wantResolve: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mcpName, name, ok := namespace.Resolve(nameKindTool, tt.visible, mcpNames)
+			if ok != tt.wantResolve {
+				t.Fatalf("Resolve(%s) ok = %v, want %v", tt.visible, ok, tt.wantResolve)
+			}
+			if ok && (mcpName != tt.wantMcp || name != tt.wantName) {
+				t.Errorf("Resolve(%s) = (%s, %s), want (%s, %s)", tt.visible, mcpName, name, tt.wantMcp, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestNamespace_RegisterCollision(t *testing.T) {
+	namespace := NewNamespace(config.NamespaceConfig{})
+
+	// a_b + c 与 a + b_c 的对外名称相同
+	first := namespace.Register(nameKindTool, "a", "b_c")
+	second := namespace.Register(nameKindTool, "a_b", "c")
+	if first != "a_b_c" || second != "a_b_c_2" {
+		t.Fatalf("Register() = %s, %s, want a_b_c, a_b_c_2", first, second)
+	}
+	// 重复登记保持不变
+	if again := namespace.Register(nameKindTool, "a_b", "c"); again != second {
+		t.Errorf("Register() again = %s, want %s", again, second)
+	}
+
+	mcpName, name, ok := namespace.Resolve(nameKindTool, second, nil)
+	if !ok || mcpName != "a_b" || name != "c" {
+		t.Errorf("Resolve(%s) = (%s, %s, %v), want (a_b, c, true)", second, mcpName, name, ok)
+	}
+
+	// 通知和读取结果使用登记的对外名称，未登记时添加前缀
+	if got := namespace.Visible(nameKindTool, "a_b", "c"); got != second {
+		t.Errorf("Visible() = %s, want registered name %s", got, second)
+	}
+	if got := namespace.Visible(nameKindTool, "a_b", "d"); got != "a_b_d" {
+		t.Errorf("Visible() of unregistered name = %s, want a_b_d", got)
+	}
+
+	// 其他MCP清除后，已登记的对外名称保持不变
+	namespace.Forget(nameKindTool, "a")
+	if got := namespace.Register(nameKindTool, "a_b", "c"); got != second {
+		t.Errorf("Register() after forget = %s, want existing route %s", got, second)
+	}
+}
+
+func TestNamespace_CustomSeparator(t *testing.T) {
+	namespace := NewNamespace(config.NamespaceConfig{Separator: "__"})
+	if got := namespace.Name(nameKindTool, "fs", "read_file"); got != "fs__read_file" {
+		t.Fatalf("Name() = %s, want fs__read_file", got)
+	}
+
+	mcpName, name, ok := namespace.Resolve(nameKindTool, "fs__read_file", []McpName{"fs"})
+	if !ok || mcpName != "fs" || name != "read_file" {
+		t.Errorf("Resolve() = (%s, %s, %v), want (fs, read_file, true)", mcpName, name, ok)
+	}
+}
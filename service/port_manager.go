@@ -1,28 +1,123 @@
 package service
 
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+const (
+	DefaultPortRangeStart = 10000
+	DefaultPortRangeEnd   = 19999
+)
+
+// ErrNoAvailablePort 端口范围内没有空闲端口
+var ErrNoAvailablePort = errors.New("no available port")
+
 type PortManagerI interface {
-	GetNextAvailablePort() int
+	// AllocatePort 为 owner 分配一个空闲端口，owner 再次申请时优先分配上次的端口
+	AllocatePort(owner string) (int, error)
+	// ReleasePort 释放端口，释放后可以再次分配
 	ReleasePort(port int)
+	// IsPortAvailable 端口在范围内、未被分配且可以绑定
+	IsPortAvailable(port int) bool
 }
 
 type portManager struct {
-	nextPort int
+	mutex sync.Mutex
+	start int
+	end   int
+	next  int // 下一次开始查找的位置，刚释放的端口不会被立即分配
+
+	owners   map[int]string // 已分配的端口 -> owner
+	lastPort map[string]int // owner 最近一次分配的端口
+
+	// probe 检查端口是否可以绑定，测试时替换
+	probe func(port int) bool
 }
 
-func NewPortManager() PortManagerI {
+// NewPortManager 创建端口管理器，在 [start, end] 范围内分配端口，范围无效时使用默认范围
+func NewPortManager(start, end int) PortManagerI {
+	if start <= 0 || end < start || end > 65535 {
+		start, end = DefaultPortRangeStart, DefaultPortRangeEnd
+	}
 	return &portManager{
-		nextPort: 10000,
+		start:    start,
+		end:      end,
+		next:     start,
+		owners:   make(map[int]string),
+		lastPort: make(map[string]int),
+		probe:    canBind,
 	}
 }
 
-func (pm *portManager) GetNextAvailablePort() int {
-	port := pm.nextPort
-	pm.nextPort++
-	return port
+func (pm *portManager) AllocatePort(owner string) (int, error) {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+
+	if port, ok := pm.lastPort[owner]; ok && pm.available(port) {
+		pm.owners[port] = owner
+		return port, nil
+	}
+
+	size := pm.end - pm.start + 1
+	for i := 0; i < size; i++ {
+		port := pm.start + (pm.next-pm.start+i)%size
+		if !pm.available(port) {
+			continue
+		}
+		pm.owners[port] = owner
+		pm.lastPort[owner] = port
+		pm.next = port + 1
+		if pm.next > pm.end {
+			pm.next = pm.start
+		}
+		return port, nil
+	}
+	return 0, fmt.Errorf("%w in range %d-%d", ErrNoAvailablePort, pm.start, pm.end)
 }
 
 func (pm *portManager) ReleasePort(port int) {
-	if port < pm.nextPort {
-		pm.nextPort = port
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	delete(pm.owners, port)
+}
+
+func (pm *portManager) IsPortAvailable(port int) bool {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	return pm.available(port)
+}
+
+// Allocations 返回已分配的端口及其 owner
+func (pm *portManager) Allocations() map[int]string {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	allocations := make(map[int]string, len(pm.owners))
+	for port, owner := range pm.owners {
+		allocations[port] = owner
+	}
+	return allocations
+}
+
+// available 调用方需持有锁
+func (pm *portManager) available(port int) bool {
+	if port < pm.start || port > pm.end {
+		return false
+	}
+	if _, ok := pm.owners[port]; ok {
+		return false
+	}
+	return pm.probe(port)
+}
+
+// canBind 尝试监听端口，端口已被其他进程占用时返回 false
+func canBind(port int) bool {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return false
 	}
+	_ = listener.Close()
+	return true
 }
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+)
+
+func newTestPortManager(start, end int, bound ...int) *portManager {
+	pm := NewPortManager(start, end).(*portManager)
+	pm.probe = func(port int) bool {
+		for _, p := range bound {
+			if p == port {
+				return false
+			}
+		}
+		return true
+	}
+	return pm
+}
+
+func TestPortManager_ConcurrentAllocate(t *testing.T) {
+	pm := newTestPortManager(20000, 20099)
+
+	var wg sync.WaitGroup
+	ports := make(chan int, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			port, err := pm.AllocatePort(fmt.Sprintf("ws/%d", i))
+			if err != nil {
+				t.Errorf("AllocatePort() error = %v", err)
+				return
+			}
+			ports <- port
+		}(i)
+	}
+	wg.Wait()
+	close(ports)
+
+	seen := make(map[int]bool)
+	for port := range ports {
+		if seen[port] {
+			t.Errorf("Port %d allocated twice", port)
+		}
+		seen[port] = true
+	}
+	if len(pm.Allocations()) != 100 {
+		t.Errorf("Expected 100 allocations, got %d", len(pm.Allocations()))
+	}
+
+	if _, err := pm.AllocatePort("ws/extra"); !errors.Is(err, ErrNoAvailablePort) {
+		t.Errorf("Expected ErrNoAvailablePort when range is exhausted, got %v", err)
+	}
+}
+
+func TestPortManager_SkipBoundAndReuse(t *testing.T) {
+	pm := newTestPortManager(20000, 20002, 20000)
+
+	first, _ := pm.AllocatePort("ws/a")
+	if first != 20001 {
+		t.Fatalf("Expected bound port 20000 to be skipped, got %d", first)
+	}
+	second, _ := pm.AllocatePort("ws/b")
+	if second != 20002 {
+		t.Fatalf("Expected 20002, got %d", second)
+	}
+	if owner := pm.Allocations()[first]; owner != "ws/a" {
+		t.Errorf("Expected port %d to be owned by ws/a, got %q", first, owner)
+	}
+
+	pm.ReleasePort(first)
+	if !pm.IsPortAvailable(first) {
+		t.Errorf("Expected port %d to be available after release", first)
+	}
+	// 同一个 owner 重新申请时复用原来的端口
+	port, err := pm.AllocatePort("ws/a")
+	if err != nil || port != first {
+		t.Errorf("AllocatePort() = %d, %v, want %d", port, err, first)
+	}
+}
+
+func TestPortManager_Probe(t *testing.T) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Skipf("listen: %v", err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	pm := NewPortManager(port, port)
+	if pm.IsPortAvailable(port) {
+		t.Errorf("Expected port %d in use to be unavailable", port)
+	}
+	if _, err := pm.AllocatePort("ws/a"); !errors.Is(err, ErrNoAvailablePort) {
+		t.Errorf("Expected ErrNoAvailablePort, got %v", err)
+	}
+}
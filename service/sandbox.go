@@ -0,0 +1,51 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/sandbox"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+// sandboxID 服务子进程的标识，同时作为服务的 cgroup 名称
+func (s *McpService) sandboxID() string {
+	sum := sha256.Sum256([]byte(s.portOwner()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// prepareSandbox 创建子进程的沙箱，返回实际启动的命令、参数和追加的环境变量，调用方需持有锁
+func (s *McpService) prepareSandbox(logger xlog.Logger) (string, []string, []string, error) {
+	s.closeSandbox(logger)
+	sb, err := sandbox.New(logger, s.sandboxID(), s.Config.Sandbox)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to create sandbox: %w", err)
+	}
+	command, args, err := sb.Command(s.Config.Command, s.Config.Args)
+	if err != nil {
+		_ = sb.Close()
+		return "", nil, nil, err
+	}
+	s.sandbox = sb
+	return command, args, sb.Env(), nil
+}
+
+// closeSandbox 子进程退出后删除沙箱的 cgroup，调用方需持有锁
+func (s *McpService) closeSandbox(logger xlog.Logger) {
+	if s.sandbox == nil {
+		return
+	}
+	if err := s.sandbox.Close(); err != nil {
+		logger.Warnf("Failed to remove sandbox of service %s: %v", s.Name, err)
+	}
+	s.sandbox = nil
+}
+
+// usageLocked 子进程当前的资源使用，服务不是 stdio 服务或未运行时为 nil，调用方需持有锁
+func (s *McpService) usageLocked() *sandbox.Usage {
+	if s.sandbox == nil || s.Status != Running {
+		return nil
+	}
+	return s.sandbox.Usage()
+}
@@ -2,9 +2,11 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
-	"os"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
@@ -12,6 +14,7 @@ import (
 
 	"github.com/lucky-aeon/agentx/plugin-helper/bridge"
 	"github.com/lucky-aeon/agentx/plugin-helper/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/sandbox"
 	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
 	"github.com/mark3labs/mcp-go/client/transport"
 )
@@ -32,31 +35,93 @@ type ExportMcpService interface {
 	GetUrl() string
 	GetSSEUrl() string
 	GetMessageUrl() string
+	GetStreamableHTTPUrl() string
+	IsIsolated() bool
+	IsRemote() bool
+	GetUpstreamHeaders() map[string]string
+	HTTPClient() *http.Client
 	GetStatus() CmdStatus
 	SendMessage(message string) error
 	Info() McpServiceInfo
 	GetHealthStatus() map[string]interface{}
+	Health() ServiceHealth
+	ReadLogs(query LogQuery) ([]LogEntry, int, error)
+	SubscribeLogs() (<-chan LogEntry, func())
+	CurrentStatus() StatusEvent
+	SubscribeStatus() (<-chan StatusEvent, func())
+}
+
+// mcpBridge 桥接器的公共行为，具体类型由配置的传输方式决定
+type mcpBridge interface {
+	Serve(listener net.Listener) error
+	Close() error
+	Ping(ctx context.Context) error
+}
+
+// sseEndpoints 暴露 SSE 端点的桥接器
+type sseEndpoints interface {
+	CompleteSseEndpoint() (string, error)
+	CompleteMessageEndpoint() (string, error)
+}
+
+// streamableHTTPEndpoint 暴露 Streamable HTTP 端点的桥接器
+type streamableHTTPEndpoint interface {
+	StreamableHTTPEndpoint() string
 }
 
 // McpService 表示一个运行中的服务实例
 type McpService struct {
-	Name    string
-	Config  config.MCPServerConfig
-	LogFile *os.File
-	logger  xlog.Logger // 用于记录CMD输出
-	Port    int         // 添加端口字段
+	Name     string
+	Config   config.MCPServerConfig
+	logs     *serviceLog // 服务日志: 子进程 stderr 和服务生命周期事件，通过 serviceLogs() 访问
+	logsOnce sync.Once
+	logger   xlog.Logger // 用于记录CMD输出
+	Port     int         // 添加端口字段
 
 	portMgr PortManagerI
 
-	// 状态
-	Status CmdStatus
+	// 本地桥接器的监听方式，unix socket 模式下记录 socket 路径和通过 socket 连接的客户端
+	bridgeListen config.BridgeListenConfig
+	socketPath   string
+	httpClient   *http.Client
+
+	// 启动时解析环境变量中的密钥引用和 envFile
+	envResolver config.EnvResolver
+
+	// 状态，通过 setStatus 修改并通知订阅者
+	Status       CmdStatus
+	statusEvents statusBroadcaster
+
+	// 每次启动和停止时递增，启动期间释放锁后用于判断服务是否已被停止或重新启动
+	startGen uint64
+
+	// 部署后首次启动前预热依赖包
+	warmupPending bool
 
 	// 重试次数
 	RetryCount int
 	RetryMax   int
 
-	// stdio-sse bridge
-	bridge *bridge.StdioToSSEBridge
+	// 桥接器: stdio-sse / stdio-streamable-http / sse-streamable-http
+	bridge mcpBridge
+
+	// 远程服务实际使用的传输协议，启动时确定
+	upstreamType config.MCPUpstreamType
+
+	// 崩溃记录和等待中的自动重启
+	crashes       []CrashRecord
+	restartTimer  *time.Timer
+	nextRestartAt time.Time
+
+	// stdio 子进程的沙箱，用于限制和统计资源使用
+	sandbox *sandbox.Sandbox
+
+	// per-session 和 pooled 服务为会话分配的子进程，服务本身不启动子进程
+	pool *instancePool
+
+	// 主动健康检查的状态，healthStop 关闭时停止探测
+	health     HealthStatus
+	healthStop chan struct{}
 
 	// 状态详情
 	LastError      string    // 最后一次错误信息
@@ -64,7 +129,7 @@ type McpService struct {
 	DeployedAt     time.Time // 部署时间
 	LastStartedAt  time.Time // 最后启动时间
 	LastStoppedAt  time.Time // 最后停止时间
-	HealthCheckURL string    // 健康检查URL
+	HealthCheckURL string    // 网关提供的服务健康检查地址
 
 	mutex sync.RWMutex
 }
@@ -84,110 +149,372 @@ func NewMcpService(name string, cfg config.MCPServerConfig, portMgr PortManagerI
 	}
 }
 
-// IsSSE 判断是否是SSE类型
+// IsRemote 判断是否是通过 URL 接入的远程服务，远程服务无需启动进程
+func (s *McpService) IsRemote() bool {
+	return s.Config.Command == "" && s.Config.URL != ""
+}
+
+// IsSSE 判断是否是远程 SSE 服务
 func (s *McpService) IsSSE() bool {
-	if s.Config.Command == "" && s.Config.URL != "" {
-		s.Status = Running
-		return true
+	return s.IsRemote() && s.getUpstreamType() == config.UpstreamSSE
+}
+
+// IsStreamableHTTP 判断是否是远程 Streamable HTTP 服务
+func (s *McpService) IsStreamableHTTP() bool {
+	return s.IsRemote() && s.getUpstreamType() == config.UpstreamStreamableHTTP
+}
+
+// getUpstreamType 获取远程服务的传输协议，尚未探测时根据配置推断
+func (s *McpService) getUpstreamType() config.MCPUpstreamType {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.upstreamTypeLocked()
+}
+
+// upstreamTypeLocked 同 getUpstreamType，调用方需持有锁
+func (s *McpService) upstreamTypeLocked() config.MCPUpstreamType {
+	if s.upstreamType != config.UpstreamAuto {
+		return s.upstreamType
 	}
-	return false
+	return guessUpstreamType(s.Config)
 }
 
-// Stop 停止服务
+// resolveUpstreamType 确定远程服务的传输协议，配置未指定时通过网络探测，探测失败时不记录结果，下次启动时重新探测
+func (s *McpService) resolveUpstreamType(logger xlog.Logger) (config.MCPUpstreamType, error) {
+	if upstreamType := s.getUpstreamType(); upstreamType != config.UpstreamAuto {
+		s.mutex.Lock()
+		s.upstreamType = upstreamType
+		s.mutex.Unlock()
+		return upstreamType, nil
+	}
+
+	upstreamType, err := detectUpstreamType(context.Background(), s.Config)
+	if err != nil {
+		s.mutex.Lock()
+		s.LastError = err.Error()
+		s.mutex.Unlock()
+		return upstreamType, err
+	}
+	logger.Infof("Detected upstream transport of %s: %s", s.Name, upstreamType)
+	s.mutex.Lock()
+	s.upstreamType = upstreamType
+	s.mutex.Unlock()
+	return upstreamType, nil
+}
+
+// GetUpstreamHeaders 请求远程服务时需要携带的请求头，本地服务返回 nil
+func (s *McpService) GetUpstreamHeaders() map[string]string {
+	if !s.IsRemote() {
+		return nil
+	}
+	return s.Config.GetHeaders()
+}
+
+// needsBridge 判断是否需要启动桥接器: stdio 服务总是需要，远程 SSE 服务仅在需要暴露 Streamable HTTP 时需要
+func (s *McpService) needsBridge() bool {
+	return s.Config.Command != "" || (s.IsSSE() && s.Config.GetTransport().ExposeStreamableHTTP())
+}
+
+// Stop 停止服务，同时取消等待中的自动重启
 func (s *McpService) Stop(logger xlog.Logger) (err error) {
-	if s.IsSSE() {
+	if !s.needsBridge() && s.IsRemote() {
+		// 远程服务没有本地进程，只停止健康检查
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		s.stopHealthCheck()
+		if s.Status == Running {
+			s.LastStoppedAt = time.Now()
+			s.setStatus(Stopped, "")
+		}
 		return
 	}
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
+	s.cancelRestart()
+	return s.stopLocked(logger)
+}
 
+// stopLocked 停止服务，调用方需持有锁
+func (s *McpService) stopLocked(logger xlog.Logger) (err error) {
 	if s.Status != Running && s.Status != Starting {
 		return
 	}
 
 	logger.Infof("Stopping service %s", s.Name)
-	s.Status = Stopping
+	s.stopHealthCheck()
+	s.startGen++
 	s.LastStoppedAt = time.Now()
+	s.setStatus(Stopping, "")
 	defer func() {
 		if s.Status == Stopping {
-			s.Status = Stopped
+			s.setStatus(Stopped, "")
 		}
 		s.bridge = nil
+		s.releaseListener()
+		s.closeSandbox(logger)
 	}()
 
-	// 停止stdio-sse桥接
+	if s.pool != nil {
+		s.pool.close(logger)
+		s.pool = nil
+	}
+
+	// 停止桥接器
 	if s.bridge != nil {
 		if err := s.bridge.Close(); err != nil {
-			logger.Errorf("Failed to stop stdio-sse bridge: %v", err)
+			logger.Errorf("Failed to stop bridge: %v", err)
 		}
 	}
 
 	// 关闭日志文件
-	if s.LogFile != nil {
-		err = s.LogFile.Close()
-		if err != nil {
-			logger.Errorf("Failed to close log file: %v", err)
-		}
-		s.LogFile = nil
+	s.serviceLogs().Logf(LogLevelInfo, "Service %s stopped", s.Name)
+	if err = s.serviceLogs().Close(); err != nil {
+		logger.Errorf("Failed to close log file: %v", err)
 	}
 	return
 }
 
 // Start 启动服务
 func (s *McpService) Start(logger xlog.Logger) error {
-	if s.IsSSE() {
-		logger.Infof("服务 %s 是 SSE 类型，无需启动进程", s.Name)
-		return nil
+	if s.IsRemote() {
+		upstreamType, err := s.resolveUpstreamType(logger)
+		if err != nil {
+			return fmt.Errorf("服务 %s 无法确定远程传输协议: %w", s.Name, err)
+		}
+		if !s.needsBridge() {
+			s.mutex.Lock()
+			s.startGen++
+			s.LastStartedAt = time.Now()
+			s.setStatus(Running, "")
+			s.startHealthCheck(logger)
+			s.mutex.Unlock()
+			logger.Infof("服务 %s 是远程 %s 类型，无需启动进程", s.Name, upstreamType)
+			return nil
+		}
 	}
 
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
+	s.cancelRestart()
+	if err := s.startLocked(logger); err != nil {
+		return err
+	}
+	// 手动启动成功后恢复全部重试次数
+	s.RetryCount = s.RetryMax
+	return nil
+}
 
-	if s.Status == Running {
-		return fmt.Errorf("服务 %s 已运行", s.Name)
+// StartAsync 在后台启动服务，服务进入 Starting 状态后立即返回，启动进展和结果通过 SubscribeStatus 获取
+func (s *McpService) StartAsync(logger xlog.Logger) error {
+	if !s.needsBridge() && s.IsRemote() {
+		// 远程服务没有子进程，直接启动
+		return s.Start(logger)
 	}
-	if s.Status == Failed {
-		return fmt.Errorf("服务 %s 已失败，无法启动", s.Name)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cancelRestart()
+	gen, err := s.beginStartLocked()
+	if err != nil {
+		return err
 	}
+	go func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if err := s.launchLocked(logger, gen); err != nil {
+			logger.Errorf("Failed to start service %s: %v", s.Name, err)
+			return
+		}
+		s.RetryCount = s.RetryMax
+	}()
+	return nil
+}
 
-	s.Status = Starting
+// startLocked 启动服务并等待启动完成，启动成功后开始监视子进程，调用方需持有锁，启动期间会暂时释放锁
+func (s *McpService) startLocked(logger xlog.Logger) error {
+	gen, err := s.beginStartLocked()
+	if err != nil {
+		return err
+	}
+	return s.launchLocked(logger, gen)
+}
+
+// beginStartLocked 进入 Starting 状态，返回本次启动的序号，调用方需持有锁
+func (s *McpService) beginStartLocked() (uint64, error) {
+	switch s.Status {
+	case Running:
+		return 0, fmt.Errorf("服务 %s 已运行", s.Name)
+	case Starting:
+		return 0, fmt.Errorf("服务 %s 正在启动", s.Name)
+	case Failed:
+		return 0, fmt.Errorf("服务 %s 已失败，无法启动", s.Name)
+	}
+
+	s.startGen++
 	s.LastStartedAt = time.Now()
 	s.LastError = ""
 	s.FailureReason = ""
+	s.setStatus(Starting, "")
+	return s.startGen, nil
+}
+
+// failLocked 记录失败原因并进入 Failed 状态，调用方需持有锁
+func (s *McpService) failLocked(lastError, failureReason string) {
+	s.LastError = lastError
+	s.FailureReason = failureReason
+	s.setStatus(Failed, failureReason)
+}
+
+// launchLocked 预热依赖包并启动桥接器，耗时的预热和子进程初始化期间释放锁，调用方需持有锁
+// 释放锁期间服务被停止或重新启动时放弃本次启动，返回 errStartAborted
+func (s *McpService) launchLocked(logger xlog.Logger, gen uint64) error {
+	if s.startGen != gen {
+		return errStartAborted
+	}
 
-	if s.Port == 0 {
-		s.Port = s.portMgr.GetNextAvailablePort()
+	if command, args, ok := s.Config.WarmupCommand(); ok && s.warmupPending {
+		s.publishStatus("Warming up packages")
+		s.mutex.Unlock()
+		s.warmup(logger, command, args)
+		s.mutex.Lock()
+		if s.startGen != gen {
+			return errStartAborted
+		}
+		s.warmupPending = false
 	}
-	logger.Infof("Assigned port: %d", s.Port)
 
-	// 创建日志文件
-	logFile, err := xlog.CreateLogFile(s.Config.LogConfig.Path, s.Name+".log")
+	// 子进程在会话订阅时启动
+	if s.Config.Isolation.Isolated() {
+		s.pool = newInstancePool(s)
+		go s.pool.run(logger)
+		s.setStatus(Running, "")
+		logger.Infof("Service %s uses %s isolation, processes are started per session", s.Name, s.Config.Isolation.GetMode())
+		s.serviceLogs().Logf(LogLevelInfo, "Service %s started with %s isolation", s.Name, s.Config.Isolation.GetMode())
+		return nil
+	}
+
+	listener, err := s.listen()
 	if err != nil {
-		s.LastError = fmt.Sprintf("failed to create log file: %v", err)
-		s.FailureReason = "Log file creation failed"
-		s.Status = Failed
+		s.failLocked(fmt.Sprintf("failed to listen: %v", err), "Bridge listen failed")
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	socketPath := s.socketPath
+	// 启动失败时关闭监听并释放端口，删除沙箱；Failed 状态的服务停止时不会再清理
+	defer func() {
+		if s.Status == Failed && s.startGen == gen {
+			_ = listener.Close()
+			s.releaseListener()
+			s.closeSandbox(logger)
+		}
+	}()
+	logger.Infof("Bridge listening on %s", s.listenAddr())
+
+	// 打开日志文件
+	if err := s.serviceLogs().Open(); err != nil {
+		s.failLocked(fmt.Sprintf("failed to create log file: %v", err), "Log file creation failed")
 		return fmt.Errorf("failed to create log file: %v", err)
 	}
-	logger.Infof("Created log file: %s", logFile.Name())
-	s.LogFile = logFile
+	logger.Infof("Created log file: %s", s.serviceLogs().Name())
+	s.serviceLogs().Logf(LogLevelInfo, "Starting service %s on %s", s.Name, s.listenAddr())
 
-	// 使用stdio-sse桥接代替supergateway
-	logger.Infof("Creating stdio-sse bridge for command: %s %s", s.Config.Command, strings.Join(s.Config.Args, " "))
+	launch, err := s.prepareBridge(logger)
+	if err != nil {
+		s.serviceLogs().Logf(LogLevelError, "Failed to create bridge: %v", err)
+		logger.Warnf("close logfile: %v", s.serviceLogs().Close())
+		s.failLocked(fmt.Sprintf("failed to create bridge: %v", err), "Bridge creation failed")
+		return fmt.Errorf("failed to create bridge: %w", err)
+	}
 
-	// 创建stdio-sse桥接
-	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
-	defer cancel()
+	// 子进程初始化期间释放锁，首次运行 npx/uvx 下载依赖包可能需要较长时间，期间可以查询和订阅服务状态
+	s.publishStatus("Launching process")
+	s.mutex.Unlock()
+	bridgeInstance, failureReason, err := s.serveBridge(logger, launch, listener)
+	s.mutex.Lock()
 
-	bridgeInstance, err := bridge.NewStdioToSSEBridge(ctx, transport.NewStdio(s.Config.Command, s.Config.GetEnvs(), s.Config.Args...), s.Name)
+	if s.startGen != gen {
+		logger.Infof("Service %s was stopped during startup", s.Name)
+		s.abandonListener(listener, socketPath)
+		if bridgeInstance != nil {
+			_ = bridgeInstance.Close()
+		}
+		return errStartAborted
+	}
 	if err != nil {
-		logger.Warnf("close logfile: %v", logFile.Close())
-		s.LastError = fmt.Sprintf("failed to create stdio-sse bridge: %v", err)
-		s.FailureReason = "Bridge creation failed"
-		s.Status = Failed
-		return fmt.Errorf("failed to create stdio-sse bridge: %w", err)
+		s.serviceLogs().Logf(LogLevelError, "%s: %v", failureReason, err)
+		logger.Warnf("close logfile: %v", s.serviceLogs().Close())
+		s.failLocked(err.Error(), failureReason)
+		return err
 	}
 
 	s.bridge = bridgeInstance
+	s.setStatus(Running, "")
+	s.HealthCheckURL = fmt.Sprintf("/services/%s/health?workspaceId=%s", url.PathEscape(s.Name), url.QueryEscape(s.Config.Workspace))
+
+	logger.Infof("Started %s bridge for service %s on %s", s.Config.GetTransport(), s.Name, s.listenAddr())
+	s.serviceLogs().Logf(LogLevelInfo, "Started %s bridge for service %s on %s", s.Config.GetTransport(), s.Name, s.listenAddr())
+
+	// 监控子进程和健康检查，意外退出或连续探测失败时自动重启
+	s.startHealthCheck(logger)
+	go s.supervise(logger, bridgeInstance)
+	return nil
+}
+
+// bridgeStartupGrace 桥接器开始服务后等待的时间，期间 Serve 返回错误视为启动失败
+const bridgeStartupGrace = 100 * time.Millisecond
+
+// errStartAborted 启动期间服务被停止或重新启动，本次启动被放弃
+var errStartAborted = errors.New("service was stopped during startup")
+
+// bridgeLauncher 创建桥接器，会启动并初始化子进程，可能耗时较长，调用时无需持有锁
+type bridgeLauncher func(ctx context.Context) (mcpBridge, error)
+
+// prepareBridge 根据服务类型和传输方式准备创建桥接器，解析环境变量并创建沙箱，调用方需持有锁
+func (s *McpService) prepareBridge(logger xlog.Logger) (bridgeLauncher, error) {
+	mcpTransport := s.Config.GetTransport()
+	name := s.Name
+
+	// 远程 SSE 服务，桥接为 Streamable HTTP
+	if s.Config.Command == "" {
+		logger.Infof("Creating sse-streamable-http bridge for url: %s", s.Config.URL)
+		url, headers := s.Config.URL, s.Config.GetHeaders()
+		return func(ctx context.Context) (mcpBridge, error) {
+			return bridge.NewSSEToHTTPStreamBridge(ctx, url, name, transport.WithHeaders(headers))
+		}, nil
+	}
+
+	logger.Infof("Creating stdio-%s bridge for command: %s %s", mcpTransport, s.Config.Command, strings.Join(s.Config.Args, " "))
+	envs, err := s.envResolver.Resolve(s.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve env for service %s: %w", s.Name, err)
+	}
+	command, args, sandboxEnvs, err := s.prepareSandbox(logger)
+	if err != nil {
+		return nil, err
+	}
+	stdio := transport.NewStdio(command, append(envs, sandboxEnvs...), args...)
+	s.serviceLogs().Logf(LogLevelInfo, "Running command: %s %s", s.Config.Command, strings.Join(s.Config.Args, " "))
+	stderr := bridge.WithStderr(s.serviceLogs())
+	return func(ctx context.Context) (mcpBridge, error) {
+		switch mcpTransport {
+		case config.TransportStreamableHTTP:
+			return bridge.NewStdioToHTTPStreamBridge(ctx, stdio, name, stderr)
+		case config.TransportBoth:
+			return bridge.NewStdioToSSEBridge(ctx, stdio, name, bridge.WithStreamableHTTP(), stderr)
+		default:
+			return bridge.NewStdioToSSEBridge(ctx, stdio, name, stderr)
+		}
+	}, nil
+}
+
+// serveBridge 创建桥接器并在 listener 上开始服务，失败时关闭桥接器并返回失败原因，调用时无需持有锁
+func (s *McpService) serveBridge(logger xlog.Logger, launch bridgeLauncher, listener net.Listener) (mcpBridge, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
+	defer cancel()
+
+	bridgeInstance, err := launch(ctx)
+	if err != nil {
+		return nil, "Bridge creation failed", fmt.Errorf("failed to create bridge: %w", err)
+	}
 
 	// 使用通道来同步服务器启动状态
 	startupChan := make(chan error, 1)
@@ -195,109 +522,116 @@ func (s *McpService) Start(logger xlog.Logger) error {
 	// 在goroutine中启动bridge服务器（会阻塞运行）
 	go func() {
 		defer close(startupChan)
-		logger.Infof("Starting bridge server on port %d", s.Port)
+		logger.Infof("Starting bridge server on %s", listener.Addr())
 
 		// 启动服务器，这里会阻塞
-		if err := bridgeInstance.Start(fmt.Sprintf("0.0.0.0:%d", s.Port)); err != nil {
+		if err := bridgeInstance.Serve(listener); err != nil {
 			logger.Errorf("Bridge server failed: %v", err)
 			startupChan <- err
 			return
 		}
 	}()
 
-	// 等待服务器启动结果，最多等待3秒
-	startupTimeout := time.NewTimer(3 * time.Second)
-	defer startupTimeout.Stop()
-
+	// 监听已经建立，Serve 只会在出错时立即返回，短暂等待后通过 ping 确认子进程可用
 	select {
 	case err := <-startupChan:
 		if err != nil {
-			logger.Warnf("close logfile: %v", logFile.Close())
-			s.LastError = err.Error()
-			s.FailureReason = "Bridge server startup failed"
-			s.Status = Failed
-			return fmt.Errorf("bridge server startup failed: %w", err)
-		}
-		// 这里不应该到达，因为Start()成功时会一直阻塞
-		break
-	case <-startupTimeout.C:
-		// 超时意味着服务器可能正在正常运行（因为Start()会阻塞）
-		// 我们可以通过健康检查来验证
-		logger.Infof("Bridge server startup timeout - checking if server is running")
-
-		// 简单检查：尝试ping bridge
-		if err := bridgeInstance.Ping(context.Background()); err != nil {
-			logger.Warnf("close logfile: %v", logFile.Close())
-			s.LastError = fmt.Sprintf("Bridge health check failed: %v", err)
-			s.FailureReason = "Bridge server not responding"
-			s.Status = Failed
-			return fmt.Errorf("bridge server not responding: %w", err)
+			_ = bridgeInstance.Close()
+			return nil, "Bridge server startup failed", fmt.Errorf("bridge server startup failed: %w", err)
 		}
-
-		logger.Infof("Bridge server is running and responding to ping")
-		break
+	case <-time.After(bridgeStartupGrace):
 	}
-
-	s.Status = Running
-	s.RetryCount = s.RetryMax
-	s.HealthCheckURL = fmt.Sprintf("http://0.0.0.0:%d/health", s.Port)
-
-	logger.Infof("Started stdio-sse bridge for service %s on port %d", s.Name, s.Port)
-
-	// 监控桥接状态
-	return nil
+	if err := bridgeInstance.Ping(ctx); err != nil {
+		_ = bridgeInstance.Close()
+		return nil, "Bridge server not responding", fmt.Errorf("bridge server not responding: %w", err)
+	}
+	logger.Infof("Bridge server is running and responding to ping")
+	return bridgeInstance, "", nil
 }
 
-// Restart 重启服务
+// Restart 重启服务，成功后恢复全部重试次数，失败时按指数退避自动重试，直到用完重试次数
 func (s *McpService) Restart(logger xlog.Logger) {
-	if s.IsSSE() {
-		logger.Infof("服务 %s 是 SSE 类型，无需重启进程", s.Name)
+	s.restart(logger, true)
+}
+
+// restart 停止并重新启动服务，manual 为 false 时是崩溃或启动失败后的自动重启
+func (s *McpService) restart(logger xlog.Logger, manual bool) {
+	if !s.needsBridge() && s.IsRemote() {
+		logger.Infof("服务 %s 是远程服务，无需重启进程", s.Name)
 		return
 	}
 
-	// 检查重试次数，避免在锁内调用自身
 	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.restartLocked(logger, manual)
+}
+
+// restartLocked 停止并重新启动服务，调用方需持有锁
+func (s *McpService) restartLocked(logger xlog.Logger, manual bool) {
+	s.cancelRestart()
 	if s.RetryCount <= 0 {
 		logger.Warnf("No retry restart count left for %s, marking as failed", s.Name)
-		s.Status = Failed
-		s.FailureReason = "Max retry count reached"
-		s.LastError = "Service failed after maximum retry attempts"
-		s.mutex.Unlock()
+		s.failLocked("Service failed after maximum retry attempts", "Max retry count reached")
 		return
 	}
 
 	s.RetryCount--
 	currentAttempt := s.RetryMax - s.RetryCount
-	retryCount := s.RetryCount
 	logger.Infof("Restarting %s (attempt %d/%d)", s.Name, currentAttempt, s.RetryMax)
-	s.mutex.Unlock()
 
-	if err := s.Stop(logger); err != nil {
+	if err := s.stopLocked(logger); err != nil {
 		logger.Errorf("Failed to stop service %s during restart: %v", s.Name, err)
 	}
+	// 允许从失败状态重新启动
+	if s.Status == Failed {
+		s.setStatus(Stopped, "")
+	}
 
-	// 在锁外调用Start
-	err := s.Start(logger)
-	if err != nil {
+	if err := s.startLocked(logger); err != nil {
+		if errors.Is(err, errStartAborted) {
+			// 启动期间被手动停止或重启，不再自动重试
+			return
+		}
 		logger.Errorf("Failed to restart %s: %v", s.Name, err)
-
-		s.mutex.Lock()
 		s.LastError = fmt.Sprintf("Failed to restart: %v", err)
-		if retryCount > 0 {
+		if s.RetryCount > 0 {
 			s.FailureReason = fmt.Sprintf("Restart attempt %d/%d failed", currentAttempt, s.RetryMax)
-			s.mutex.Unlock()
-			// 在锁外延时重启，避免死锁
-			time.AfterFunc(5*time.Second, func() {
-				s.Restart(logger)
-			})
+			s.scheduleRestart(logger, currentAttempt+1)
 		} else {
-			s.Status = Failed
-			s.FailureReason = "All restart attempts failed"
-			s.mutex.Unlock()
+			s.failLocked(s.LastError, "All restart attempts failed")
 		}
+		return
+	}
+	if manual {
+		s.RetryCount = s.RetryMax
 	}
 }
 
+// portOwner 端口的 owner，同名服务可以存在于不同的工作空间
+func (s *McpService) portOwner() string {
+	return s.Config.Workspace + "/" + s.Name
+}
+
+// releasePort 归还端口，调用方需持有锁
+func (s *McpService) releasePort() {
+	if s.Port == 0 || s.portMgr == nil {
+		return
+	}
+	s.portMgr.ReleasePort(s.Port)
+	s.Port = 0
+}
+
+// setRetryMax 更新最大重试次数，已消耗的重试次数保持不变
+func (s *McpService) setRetryMax(retryMax int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.RetryCount += retryMax - s.RetryMax
+	if s.RetryCount < 0 {
+		s.RetryCount = 0
+	}
+	s.RetryMax = retryMax
+}
+
 // setConfig 设置配置, 下次启动时生效
 func (s *McpService) setConfig(cfg config.MCPServerConfig) error {
 	if s.Status != Stopped {
@@ -308,38 +642,106 @@ func (s *McpService) setConfig(cfg config.MCPServerConfig) error {
 }
 
 func (s *McpService) GetUrl() string {
-	if s.GetStatus() != Running {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.urlLocked()
+}
+
+// urlLocked 服务的基础地址，服务未运行时为空，调用方需持有锁
+func (s *McpService) urlLocked() string {
+	if s.Status != Running {
 		return ""
 	}
 	if s.Config.URL != "" {
 		return s.Config.URL
 	}
 	if s.bridge != nil {
-		return "http://127.0.0.1:" + strconv.Itoa(s.Port)
+		return s.bridgeUrl()
 	}
 
 	return ""
 }
 
+// bridgeUrl 本地桥接器的地址，unix socket 模式下需使用 HTTPClient 访问
+func (s *McpService) bridgeUrl() string {
+	if s.socketPath != "" {
+		return unixBridgeURL
+	}
+	return "http://" + net.JoinHostPort(bridgeTCPHost, strconv.Itoa(s.Port))
+}
+
 // GetSSEUrl get sse url
 func (s *McpService) GetSSEUrl() string {
-	if s.GetStatus() != Running {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.sseUrlLocked()
+}
+
+// sseUrlLocked 同 GetSSEUrl，调用方需持有锁
+func (s *McpService) sseUrlLocked() string {
+	if s.Status != Running {
 		return ""
 	}
-	if s.bridge != nil {
-		sseUrl, _ := s.bridge.CompleteSseEndpoint()
-		return s.GetUrl() + sseUrl
+	if s.IsRemote() {
+		if s.upstreamTypeLocked() == config.UpstreamSSE {
+			return s.Config.URL
+		}
+		return ""
+	}
+	if b, ok := s.bridge.(sseEndpoints); ok {
+		sseUrl, _ := b.CompleteSseEndpoint()
+		return s.urlLocked() + sseUrl
 	}
-	return s.GetUrl()
+	return ""
 }
 
 // Message
 func (s *McpService) GetMessageUrl() string {
-	if s.GetStatus() != Running {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.messageUrlLocked()
+}
+
+// messageUrlLocked 同 GetMessageUrl，调用方需持有锁
+func (s *McpService) messageUrlLocked() string {
+	if s.Status != Running {
+		return ""
+	}
+	if b, ok := s.bridge.(sseEndpoints); ok {
+		mesUrl, _ := b.CompleteMessageEndpoint()
+		return s.urlLocked() + mesUrl
+	}
+	return ""
+}
+
+// IsIsolated 是否按会话隔离，隔离的服务没有共享的子进程和地址，只能通过网关会话访问
+func (s *McpService) IsIsolated() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.Config.Isolation.Isolated()
+}
+
+// GetStreamableHTTPUrl get streamable http url, 未暴露时返回空字符串
+func (s *McpService) GetStreamableHTTPUrl() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.streamableHTTPUrlLocked()
+}
+
+// streamableHTTPUrlLocked 同 GetStreamableHTTPUrl，调用方需持有锁
+func (s *McpService) streamableHTTPUrlLocked() string {
+	if s.Status != Running {
 		return ""
 	}
-	mesUrl, _ := s.bridge.CompleteMessageEndpoint()
-	return s.GetUrl() + mesUrl
+	if s.IsRemote() && s.upstreamTypeLocked() == config.UpstreamStreamableHTTP {
+		return s.Config.URL
+	}
+	if b, ok := s.bridge.(streamableHTTPEndpoint); ok {
+		if endpoint := b.StreamableHTTPEndpoint(); endpoint != "" {
+			return s.bridgeUrl() + endpoint
+		}
+	}
+	return ""
 }
 
 func (s *McpService) GetPort() int {
@@ -354,7 +756,7 @@ func (s *McpService) GetStatus() CmdStatus {
 
 func (s *McpService) SendMessage(message string) error {
 	// 发送消息到 MCP 服务
-	resp, err := http.Post(s.GetMessageUrl(), "application/json", strings.NewReader(message))
+	resp, err := s.HTTPClient().Post(s.GetMessageUrl(), "application/json", strings.NewReader(message))
 	if err != nil {
 		return fmt.Errorf("failed to send message: %v", err)
 	}
@@ -376,6 +778,7 @@ type McpServiceInfo struct {
 	Status        CmdStatus              `json:"status"`
 	Config        config.MCPServerConfig `json:"config"`
 	Port          int                    `json:"port"`
+	Socket        string                 `json:"socket,omitempty"` // 桥接器监听的 unix socket
 	LastError     string                 `json:"last_error,omitempty"`
 	FailureReason string                 `json:"failure_reason,omitempty"`
 	DeployedAt    time.Time              `json:"deployed_at"`
@@ -383,13 +786,19 @@ type McpServiceInfo struct {
 	LastStoppedAt time.Time              `json:"last_stopped_at,omitempty"`
 	RetryCount    int                    `json:"retry_count"`
 	RetryMax      int                    `json:"retry_max"`
+	UpstreamType  config.MCPUpstreamType `json:"upstream_type,omitempty"`
 	URLs          ServiceURLs            `json:"urls"`
+	Crashes       []CrashRecord          `json:"crashes,omitempty"`         // 最近的崩溃记录
+	NextRestartAt time.Time              `json:"next_restart_at,omitempty"` // 等待中的自动重启时间
+	Usage         *sandbox.Usage         `json:"usage,omitempty"`           // stdio 子进程当前的资源使用
+	Isolation     *IsolationInfo         `json:"isolation,omitempty"`       // per-session 和 pooled 服务为会话启动的子进程
 }
 
 type ServiceURLs struct {
-	BaseURL    string `json:"base_url,omitempty"`
-	SSEUrl     string `json:"sse_url,omitempty"`
-	MessageUrl string `json:"message_url,omitempty"`
+	BaseURL           string `json:"base_url,omitempty"`
+	SSEUrl            string `json:"sse_url,omitempty"`
+	MessageUrl        string `json:"message_url,omitempty"`
+	StreamableHTTPUrl string `json:"streamable_http_url,omitempty"`
 }
 
 func (s *McpService) Info() McpServiceInfo {
@@ -398,8 +807,9 @@ func (s *McpService) Info() McpServiceInfo {
 	return McpServiceInfo{
 		Name:          s.Name,
 		Status:        s.Status,
-		Config:        s.Config,
+		Config:        s.Config.Redacted(),
 		Port:          s.Port,
+		Socket:        s.socketPath,
 		LastError:     s.LastError,
 		FailureReason: s.FailureReason,
 		DeployedAt:    s.DeployedAt,
@@ -407,12 +817,26 @@ func (s *McpService) Info() McpServiceInfo {
 		LastStoppedAt: s.LastStoppedAt,
 		RetryCount:    s.RetryCount,
 		RetryMax:      s.RetryMax,
+		UpstreamType:  s.upstreamType,
 		URLs: ServiceURLs{
-			BaseURL:    s.GetUrl(),
-			SSEUrl:     s.GetSSEUrl(),
-			MessageUrl: s.GetMessageUrl(),
+			BaseURL:           s.urlLocked(),
+			SSEUrl:            s.sseUrlLocked(),
+			MessageUrl:        s.messageUrlLocked(),
+			StreamableHTTPUrl: s.streamableHTTPUrlLocked(),
 		},
+		Crashes:       append([]CrashRecord(nil), s.crashes...),
+		NextRestartAt: s.nextRestartAt,
+		Usage:         s.usageLocked(),
+		Isolation:     s.isolationLocked(),
+	}
+}
+
+// isolationLocked per-session 和 pooled 服务的子进程，调用方需持有锁
+func (s *McpService) isolationLocked() *IsolationInfo {
+	if s.pool == nil {
+		return nil
 	}
+	return s.pool.info()
 }
 
 // GetHealthStatus returns detailed health information for the service
@@ -420,10 +844,14 @@ func (s *McpService) GetHealthStatus() map[string]interface{} {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
+	serviceHealth := s.healthLocked()
 	health := map[string]interface{}{
 		"name":            s.Name,
 		"status":          s.Status,
-		"healthy":         s.Status == Running,
+		"healthy":         serviceHealth.Live,
+		"live":            serviceHealth.Live,
+		"ready":           serviceHealth.Ready,
+		"health":          serviceHealth.Health,
 		"port":            s.Port,
 		"deployed_at":     s.DeployedAt,
 		"last_started_at": s.LastStartedAt,
@@ -444,6 +872,19 @@ func (s *McpService) GetHealthStatus() map[string]interface{} {
 		health["health_check_url"] = s.HealthCheckURL
 	}
 
+	if s.socketPath != "" {
+		health["socket"] = s.socketPath
+	}
+
+	if len(s.crashes) > 0 {
+		health["crash_count"] = len(s.crashes)
+		health["last_crash"] = s.crashes[len(s.crashes)-1]
+	}
+
+	if !s.nextRestartAt.IsZero() {
+		health["next_restart_at"] = s.nextRestartAt
+	}
+
 	// Calculate uptime if service is running
 	if s.Status == Running && !s.LastStartedAt.IsZero() {
 		health["uptime_seconds"] = time.Since(s.LastStartedAt).Seconds()
@@ -451,3 +892,23 @@ func (s *McpService) GetHealthStatus() map[string]interface{} {
 
 	return health
 }
+
+// serviceLogs 返回服务日志，首次访问时创建
+func (s *McpService) serviceLogs() *serviceLog {
+	s.logsOnce.Do(func() {
+		if s.logs == nil {
+			s.logs = newServiceLog(s.Config.LogConfig.Path, s.Name)
+		}
+	})
+	return s.logs
+}
+
+// ReadLogs 按条件读取服务日志，返回日志和过滤后的总条数
+func (s *McpService) ReadLogs(query LogQuery) ([]LogEntry, int, error) {
+	return s.serviceLogs().Read(query)
+}
+
+// SubscribeLogs 订阅服务的实时日志，返回的函数用于取消订阅
+func (s *McpService) SubscribeLogs() (<-chan LogEntry, func()) {
+	return s.serviceLogs().Subscribe()
+}
@@ -0,0 +1,356 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+// 服务日志的来源
+const (
+	LogSourceStderr  = "stderr"  // 子进程的 stderr 输出
+	LogSourceGateway = "gateway" // 网关记录的服务生命周期事件
+)
+
+// 日志级别，按严重程度从低到高
+const (
+	LogLevelDebug = "DEBUG"
+	LogLevelInfo  = "INFO"
+	LogLevelWarn  = "WARN"
+	LogLevelError = "ERROR"
+)
+
+var logLevelOrder = map[string]int{
+	LogLevelDebug: 0,
+	LogLevelInfo:  1,
+	LogLevelWarn:  2,
+	LogLevelError: 3,
+}
+
+// logSubscriberBuffer 实时日志订阅者的缓冲大小，订阅者处理不及时会丢弃日志
+const logSubscriberBuffer = 256
+
+// LogEntry 服务日志条目
+type LogEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Level     string    `json:"level"`
+	Source    string    `json:"source"`
+	Message   string    `json:"message"`
+}
+
+// LogQuery 日志查询条件，先按级别过滤，再应用 Tail，最后应用 Offset/Limit
+type LogQuery struct {
+	Level  string // 最低日志级别，为空时不过滤
+	Tail   int    // 只保留最后 N 条，0 表示不限制
+	Offset int    // 跳过的条数
+	Limit  int    // 返回的最大条数，0 表示不限制
+}
+
+// NormalizeLogLevel 规范化日志级别，无法识别时返回空字符串
+func NormalizeLogLevel(level string) string {
+	level = strings.ToUpper(strings.TrimSpace(level))
+	if level == "WARNING" {
+		level = LogLevelWarn
+	}
+	if _, ok := logLevelOrder[level]; !ok {
+		return ""
+	}
+	return level
+}
+
+// Match 判断日志条目是否达到最低日志级别
+func (q LogQuery) Match(entry LogEntry) bool {
+	minLevel := NormalizeLogLevel(q.Level)
+	if minLevel == "" {
+		return true
+	}
+	return logLevelOrder[entry.Level] >= logLevelOrder[minLevel]
+}
+
+// serviceLog 服务的日志文件，按大小轮转，同时将新日志推送给实时订阅者
+// 服务停止后文件关闭，但仍可以读取历史日志
+type serviceLog struct {
+	baseDir  string
+	fileName string
+	path     string
+
+	mu          sync.Mutex
+	file        *xlog.RotatingFile
+	partial     []byte // stderr 中尚未换行的部分
+	subscribers map[chan LogEntry]struct{}
+}
+
+func newServiceLog(baseDir, name string) *serviceLog {
+	return &serviceLog{
+		baseDir:     baseDir,
+		fileName:    name + ".log",
+		path:        filepath.Join(baseDir, "logs", name+".log"),
+		subscribers: make(map[chan LogEntry]struct{}),
+	}
+}
+
+// Open 打开日志文件，服务启动时调用
+func (l *serviceLog) Open() error {
+	file, err := xlog.OpenRotatingFile(l.baseDir, l.fileName, xlog.DefaultRotateMaxSize, xlog.DefaultRotateMaxBackups)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		l.file.Close()
+	}
+	l.file = file
+	return nil
+}
+
+// Close 关闭日志文件，服务停止时调用
+func (l *serviceLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.flushPartial()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Name 返回当前日志文件路径
+func (l *serviceLog) Name() string {
+	return l.path
+}
+
+// Write 按行写入子进程的 stderr 输出，实现 io.Writer
+func (l *serviceLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	data := append(l.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimRight(string(data[:i]), "\r")
+		data = data[i+1:]
+		if line != "" {
+			l.write(LogEntry{Timestamp: time.Now(), Level: detectLogLevel(line), Source: LogSourceStderr, Message: line})
+		}
+	}
+	l.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+// flushPartial 写入 stderr 中最后一行未换行的内容
+func (l *serviceLog) flushPartial() {
+	if len(l.partial) == 0 {
+		return
+	}
+	line := string(l.partial)
+	l.partial = nil
+	l.write(LogEntry{Timestamp: time.Now(), Level: detectLogLevel(line), Source: LogSourceStderr, Message: line})
+}
+
+// Logf 记录网关产生的服务事件
+func (l *serviceLog) Logf(level string, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.write(LogEntry{Timestamp: time.Now(), Level: level, Source: LogSourceGateway, Message: fmt.Sprintf(format, args...)})
+}
+
+// write 写入文件并推送给订阅者，调用方需持有锁
+func (l *serviceLog) write(entry LogEntry) {
+	if l.file != nil {
+		_, _ = l.file.Write([]byte(formatLogEntry(entry)))
+	}
+	for ch := range l.subscribers {
+		select {
+		case ch <- entry:
+		default:
+		}
+	}
+}
+
+// Subscribe 订阅实时日志，返回的函数用于取消订阅
+func (l *serviceLog) Subscribe() (<-chan LogEntry, func()) {
+	ch := make(chan LogEntry, logSubscriberBuffer)
+	l.mu.Lock()
+	l.subscribers[ch] = struct{}{}
+	l.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			l.mu.Lock()
+			delete(l.subscribers, ch)
+			l.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Read 按查询条件读取日志（包括已轮转的历史文件），返回日志和过滤后的总条数
+// tail 查询从最新的文件末尾倒序读取，读够 N 条即停止，不再解析更早的日志，此时总条数为读取到的条数
+func (l *serviceLog) Read(query LogQuery) ([]LogEntry, int, error) {
+	files := xlog.RotatedFiles(l.path, xlog.DefaultRotateMaxBackups)
+	var entries []LogEntry
+	if query.Tail > 0 {
+		for i := len(files) - 1; i >= 0 && len(entries) < query.Tail; i-- {
+			fileEntries, err := readLogFileReverse(files[i], query, query.Tail-len(entries))
+			if err != nil {
+				return nil, 0, err
+			}
+			entries = append(entries, fileEntries...)
+		}
+		slices.Reverse(entries)
+	} else {
+		for _, path := range files {
+			fileEntries, err := readLogFile(path, query)
+			if err != nil {
+				return nil, 0, err
+			}
+			entries = append(entries, fileEntries...)
+		}
+	}
+
+	total := len(entries)
+	if query.Offset > 0 {
+		if query.Offset >= len(entries) {
+			return []LogEntry{}, total, nil
+		}
+		entries = entries[query.Offset:]
+	}
+	if query.Limit > 0 && len(entries) > query.Limit {
+		entries = entries[:query.Limit]
+	}
+	if entries == nil {
+		entries = []LogEntry{}
+	}
+	return entries, total, nil
+}
+
+func readLogFile(path string, query LogQuery) ([]LogEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []LogEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			continue
+		}
+		entry := parseLogEntry(scanner.Text())
+		if query.Match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read log file: %w", err)
+	}
+	return entries, nil
+}
+
+// logReadChunkSize 倒序读取日志文件时每次读取的字节数
+const logReadChunkSize = 64 * 1024
+
+// readLogFileReverse 从文件末尾按块向前读取，返回最多 limit 条符合条件的日志，顺序为从新到旧
+func readLogFileReverse(path string, query LogQuery, limit int) ([]LogEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	var entries []LogEntry
+	appendLine := func(line []byte) {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if len(line) == 0 {
+			return
+		}
+		if entry := parseLogEntry(string(line)); query.Match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	offset := info.Size()
+	buf := make([]byte, logReadChunkSize)
+	// head 是已读取部分开头尚不完整的一行，与前一块拼接后再解析
+	var head []byte
+	for offset > 0 && len(entries) < limit {
+		n := min(int64(len(buf)), offset)
+		offset -= n
+		if _, err := file.ReadAt(buf[:n], offset); err != nil {
+			return nil, fmt.Errorf("failed to read log file: %w", err)
+		}
+		lines := bytes.Split(append(slices.Clone(buf[:n]), head...), []byte("\n"))
+		head = lines[0]
+		for i := len(lines) - 1; i > 0 && len(entries) < limit; i-- {
+			appendLine(lines[i])
+		}
+	}
+	if offset == 0 && len(entries) < limit {
+		appendLine(head)
+	}
+	return entries, nil
+}
+
+// formatLogEntry 日志文件中每行的格式: 时间 级别 [来源] 内容
+func formatLogEntry(entry LogEntry) string {
+	return fmt.Sprintf("%s %s [%s] %s\n", entry.Timestamp.Format(time.RFC3339Nano), entry.Level, entry.Source, entry.Message)
+}
+
+// parseLogEntry 解析日志文件中的一行，格式不符合时整行作为 INFO 级别的内容
+func parseLogEntry(line string) LogEntry {
+	parts := strings.SplitN(line, " ", 4)
+	if len(parts) == 4 && strings.HasPrefix(parts[2], "[") && strings.HasSuffix(parts[2], "]") {
+		if ts, err := time.Parse(time.RFC3339Nano, parts[0]); err == nil {
+			return LogEntry{
+				Timestamp: ts,
+				Level:     parts[1],
+				Source:    strings.Trim(parts[2], "[]"),
+				Message:   parts[3],
+			}
+		}
+	}
+	return LogEntry{Level: LogLevelInfo, Message: line}
+}
+
+// detectLogLevel 根据 stderr 内容推断日志级别，npx/uvx 等子进程的输出没有统一格式，只能按关键字判断
+func detectLogLevel(line string) string {
+	lower := strings.ToLower(line)
+	switch {
+	case strings.Contains(lower, "error"), strings.Contains(lower, "fatal"), strings.Contains(lower, "panic"),
+		strings.Contains(lower, "exception"), strings.Contains(lower, "traceback"):
+		return LogLevelError
+	case strings.Contains(lower, "warn"):
+		return LogLevelWarn
+	case strings.Contains(lower, "debug"):
+		return LogLevelDebug
+	}
+	return LogLevelInfo
+}
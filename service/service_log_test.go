@@ -0,0 +1,187 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+func TestServiceLog_WriteAndRead(t *testing.T) {
+	logs := newServiceLog(t.TempDir(), "test")
+	if err := logs.Open(); err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	logs.Logf(LogLevelInfo, "Starting service %s", "test")
+	// stderr 输出可能在任意位置被截断，按行拆分
+	fmt.Fprint(logs, "npm WARN deprecated pkg\nDownloading")
+	fmt.Fprint(logs, " package\r\nError: Cannot find module\n")
+	fmt.Fprint(logs, "last line without newline")
+	if err := logs.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	entries, total, err := logs.Read(LogQuery{})
+	if err != nil {
+		t.Fatalf("Read() error: %v", err)
+	}
+	expected := []LogEntry{
+		{Level: LogLevelInfo, Source: LogSourceGateway, Message: "Starting service test"},
+		{Level: LogLevelWarn, Source: LogSourceStderr, Message: "npm WARN deprecated pkg"},
+		{Level: LogLevelInfo, Source: LogSourceStderr, Message: "Downloading package"},
+		{Level: LogLevelError, Source: LogSourceStderr, Message: "Error: Cannot find module"},
+		{Level: LogLevelInfo, Source: LogSourceStderr, Message: "last line without newline"},
+	}
+	if total != len(expected) || len(entries) != len(expected) {
+		t.Fatalf("Read() returned %d entries (total %d), want %d: %v", len(entries), total, len(expected), entries)
+	}
+	for i, entry := range entries {
+		if entry.Timestamp.IsZero() {
+			t.Errorf("entry %d has no timestamp", i)
+		}
+		entry.Timestamp = time.Time{}
+		if entry != expected[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, entry, expected[i])
+		}
+	}
+
+	tests := []struct {
+		name     string
+		query    LogQuery
+		messages []string
+		total    int
+	}{
+		{name: "tail", query: LogQuery{Tail: 2}, messages: []string{"Error: Cannot find module", "last line without newline"}, total: 2},
+		{name: "offset and limit", query: LogQuery{Offset: 1, Limit: 2}, messages: []string{"npm WARN deprecated pkg", "Downloading package"}, total: 5},
+		{name: "offset out of range", query: LogQuery{Offset: 10}, messages: []string{}, total: 5},
+		{name: "level", query: LogQuery{Level: "warning"}, messages: []string{"npm WARN deprecated pkg", "Error: Cannot find module"}, total: 2},
+		{name: "level and tail", query: LogQuery{Level: LogLevelWarn, Tail: 1}, messages: []string{"Error: Cannot find module"}, total: 1},
+		{name: "tail and limit", query: LogQuery{Tail: 3, Limit: 1}, messages: []string{"Downloading package"}, total: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, total, err := logs.Read(tt.query)
+			if err != nil {
+				t.Fatalf("Read() error: %v", err)
+			}
+			messages := make([]string, 0, len(entries))
+			for _, entry := range entries {
+				messages = append(messages, entry.Message)
+			}
+			if strings.Join(messages, "|") != strings.Join(tt.messages, "|") || total != tt.total {
+				t.Errorf("Read() = %v (total %d), want %v (total %d)", messages, total, tt.messages, tt.total)
+			}
+		})
+	}
+}
+
+func TestServiceLog_Subscribe(t *testing.T) {
+	logs := newServiceLog(t.TempDir(), "test")
+	if err := logs.Open(); err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer logs.Close()
+
+	ch, unsubscribe := logs.Subscribe()
+	fmt.Fprint(logs, "server started\n")
+
+	select {
+	case entry := <-ch:
+		if entry.Message != "server started" || entry.Source != LogSourceStderr {
+			t.Errorf("Unexpected entry: %+v", entry)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for log entry")
+	}
+
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-ch; ok {
+		t.Error("Expected channel to be closed after unsubscribe")
+	}
+	// 取消订阅后写入不应阻塞或 panic
+	logs.Logf(LogLevelInfo, "after unsubscribe")
+}
+
+func TestRotatingFile_Rotate(t *testing.T) {
+	dir := t.TempDir()
+	file, err := xlog.OpenRotatingFile(dir, "rotate.log", 10, 2)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile() error: %v", err)
+	}
+	for i := 1; i <= 5; i++ {
+		if _, err := fmt.Fprintf(file, "line%d\n", i); err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	path := filepath.Join(dir, "logs", "rotate.log")
+	files := xlog.RotatedFiles(path, 2)
+	expected := []string{path + ".2", path + ".1", path}
+	if strings.Join(files, ",") != strings.Join(expected, ",") {
+		t.Fatalf("RotatedFiles() = %v, want %v", files, expected)
+	}
+
+	// 最旧的 line1、line2 超出保留数量被丢弃，读取顺序从旧到新
+	logs := &serviceLog{path: path}
+	entries, _, err := logs.Read(LogQuery{})
+	if err != nil {
+		t.Fatalf("Read() error: %v", err)
+	}
+	var messages []string
+	for _, entry := range entries {
+		messages = append(messages, entry.Message)
+	}
+	if got := strings.Join(messages, ","); got != "line3,line4,line5" {
+		t.Errorf("Read() = %s, want line3,line4,line5", got)
+	}
+
+	// tail 查询从最新的文件倒序读取，跨文件时保持从旧到新的顺序
+	entries, total, err := logs.Read(LogQuery{Tail: 2})
+	if err != nil {
+		t.Fatalf("Read() error: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Message != "line4" || entries[1].Message != "line5" || total != 2 {
+		t.Errorf("Read(tail) = %v (total %d), want line4,line5", entries, total)
+	}
+}
+
+func TestReadLogFileReverse(t *testing.T) {
+	// 行跨越读取块的边界
+	path := filepath.Join(t.TempDir(), "reverse.log")
+	count := 3 * logReadChunkSize / 100
+	line := func(i int) string { return fmt.Sprintf("%04d-%s", i, strings.Repeat("x", 94)) }
+	var content strings.Builder
+	for i := 0; i < count; i++ {
+		content.WriteString(line(i) + "\n")
+	}
+	if err := os.WriteFile(path, []byte(content.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := readLogFileReverse(path, LogQuery{}, count+1)
+	if err != nil {
+		t.Fatalf("readLogFileReverse() error: %v", err)
+	}
+	if len(entries) != count {
+		t.Fatalf("readLogFileReverse() returned %d entries, want %d", len(entries), count)
+	}
+	for i, entry := range entries {
+		if want := line(count - 1 - i); entry.Message != want {
+			t.Fatalf("entry %d = %q, want %q", i, entry.Message, want)
+		}
+	}
+
+	// 读够指定条数即停止
+	if entries, _ = readLogFileReverse(path, LogQuery{}, 2); len(entries) != 2 {
+		t.Errorf("readLogFileReverse() returned %d entries, want 2", len(entries))
+	}
+}
@@ -1,6 +1,9 @@
 package service
 
 import (
+	"fmt"
+	"sync"
+
 	"github.com/lucky-aeon/agentx/plugin-helper/config"
 	"github.com/lucky-aeon/agentx/plugin-helper/errs"
 	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
@@ -8,6 +11,7 @@ import (
 
 type ServiceManagerI interface {
 	DeployServer(logger xlog.Logger, name NameArg, config config.MCPServerConfig) (AddMcpServiceResult, error)
+	DeployServerAsync(logger xlog.Logger, name NameArg, config config.MCPServerConfig) (AddMcpServiceResult, error)
 	StopServer(logger xlog.Logger, name NameArg)
 	RestartServer(logger xlog.Logger, name NameArg) error
 	ListServerConfig(logger xlog.Logger, name NameArg) map[string]config.MCPServerConfig
@@ -18,6 +22,13 @@ type ServiceManagerI interface {
 	GetWorkspaceSessions(logger xlog.Logger, name NameArg) []*Session
 	CloseProxySession(logger xlog.Logger, name NameArg)
 	DeleteServer(logger xlog.Logger, name NameArg) error
+	PlanWorkspace(logger xlog.Logger, name NameArg, servers map[string]config.MCPServerConfig) WorkspacePlan
+	ApplyWorkspace(logger xlog.Logger, name NameArg, servers map[string]config.MCPServerConfig) WorkspacePlan
+	CheckHealth(logger xlog.Logger) GatewayHealth
+	GetSessionMetrics(logger xlog.Logger) map[string]SessionMetrics
+	UpdateConfig(logger xlog.Logger, cfg config.Config)
+	StartRestored(logger xlog.Logger)
+	DeleteWorkspace(logger xlog.Logger, name NameArg) error
 	Close()
 }
 
@@ -25,19 +36,26 @@ type NameArg struct {
 	Workspace string
 	Server    string
 	Session   string
+	ApiKey    string // 创建会话时使用的 API Key
 }
 
 type ServiceManager struct {
 	cfg          config.Config
 	PortMgr      PortManagerI
 	workSpaceMgr *WorkspaceManager
+
+	// 正在创建的会话按 API Key 计数，计入每个 API Key 的会话数上限
+	creatingSessions map[string]int
+	sessionKeysMutex sync.Mutex
 }
 
-func NewServiceMgr(cfg config.Config, portMgr PortManagerI) *ServiceManager {
+// NewServiceMgr 创建服务管理器，工作空间和服务的变更写入 store，store 为 nil 时不持久化
+func NewServiceMgr(cfg config.Config, portMgr PortManagerI, store Store) *ServiceManager {
 	return &ServiceManager{
-		cfg:          cfg,
-		PortMgr:      portMgr,
-		workSpaceMgr: NewWorkspaceManager(cfg, portMgr),
+		cfg:              cfg,
+		PortMgr:          portMgr,
+		workSpaceMgr:     NewWorkspaceManager(cfg, portMgr, store),
+		creatingSessions: make(map[string]int),
 	}
 }
 
@@ -46,6 +64,12 @@ func (s *ServiceManager) DeployServer(logger xlog.Logger, name NameArg, config c
 	return workspace.AddMcpService(logger, name.Server, config)
 }
 
+// DeployServerAsync 部署服务并在后台启动，启动进展通过服务的 SubscribeStatus 获取
+func (s *ServiceManager) DeployServerAsync(logger xlog.Logger, name NameArg, config config.MCPServerConfig) (AddMcpServiceResult, error) {
+	workspace, _ := s.getWorkspace(logger, name.Workspace)
+	return workspace.AddMcpServiceAsync(logger, name.Server, config)
+}
+
 func (s *ServiceManager) StopServer(logger xlog.Logger, name NameArg) {
 	workspace, ok := s.getWorkspace(logger, name.Workspace)
 	if !ok {
@@ -85,9 +109,51 @@ func (s *ServiceManager) GetMcpServices(logger xlog.Logger, name NameArg) map[st
 	return workspace.GetMcpServices()
 }
 
+// CreateProxySession 创建会话，工作空间或 API Key 的会话数达到上限时返回 errs.ErrSessionLimitExceeded
 func (s *ServiceManager) CreateProxySession(logger xlog.Logger, name NameArg) (*Session, error) {
 	workspace, _ := s.getWorkspace(logger, name.Workspace)
-	return workspace.sessionMgr.CreateSession(logger)
+	release, err := s.reserveApiKeySession(logger, name.ApiKey)
+	if err != nil {
+		workspace.sessionMgr.counters.recordRejected(SessionRejectApiKeyLimit)
+		logger.Warnf("reject session: %v", err)
+		return nil, err
+	}
+	defer release()
+	return workspace.sessionMgr.CreateSession(logger, name.ApiKey)
+}
+
+// reserveApiKeySession 检查 API Key 在所有工作空间中的会话数上限并占用一个名额，返回的函数用于释放占用
+func (s *ServiceManager) reserveApiKeySession(logger xlog.Logger, apiKey string) (func(), error) {
+	s.sessionKeysMutex.Lock()
+	defer s.sessionKeysMutex.Unlock()
+	max := s.cfg.MaxSessionsPerApiKey
+	if apiKey == "" || max <= 0 {
+		return func() {}, nil
+	}
+	count := s.creatingSessions[apiKey]
+	for _, workspace := range s.workSpaceMgr.GetWorkspaces() {
+		count += workspace.sessionMgr.countApiKeySessions(logger, apiKey)
+	}
+	if count >= max {
+		return nil, fmt.Errorf("%w: api key allows at most %d sessions", errs.ErrSessionLimitExceeded, max)
+	}
+	s.creatingSessions[apiKey]++
+	return func() {
+		s.sessionKeysMutex.Lock()
+		defer s.sessionKeysMutex.Unlock()
+		if s.creatingSessions[apiKey]--; s.creatingSessions[apiKey] <= 0 {
+			delete(s.creatingSessions, apiKey)
+		}
+	}, nil
+}
+
+// GetSessionMetrics 获取各工作空间的会话统计
+func (s *ServiceManager) GetSessionMetrics(logger xlog.Logger) map[string]SessionMetrics {
+	metrics := make(map[string]SessionMetrics)
+	for id, workspace := range s.workSpaceMgr.GetWorkspaces() {
+		metrics[id] = workspace.sessionMgr.Metrics()
+	}
+	return metrics
 }
 
 func (s *ServiceManager) GetProxySession(logger xlog.Logger, name NameArg) (*Session, bool) {
@@ -116,6 +182,22 @@ func (s *ServiceManager) DeleteServer(logger xlog.Logger, name NameArg) error {
 	return nil
 }
 
+// PlanWorkspace 计算工作空间清单与当前服务的差异，不做任何修改
+func (s *ServiceManager) PlanWorkspace(logger xlog.Logger, name NameArg, servers map[string]config.MCPServerConfig) WorkspacePlan {
+	workspace, ok := s.getWorkspace(logger, name.Workspace, false)
+	if !ok {
+		// 工作空间不存在时与空的服务集合比较，不创建工作空间
+		return planManifest(name.Workspace, nil, servers)
+	}
+	return workspace.PlanManifest(servers)
+}
+
+// ApplyWorkspace 按清单收敛工作空间的服务，工作空间不存在时创建
+func (s *ServiceManager) ApplyWorkspace(logger xlog.Logger, name NameArg, servers map[string]config.MCPServerConfig) WorkspacePlan {
+	workspace, _ := s.getWorkspace(logger, name.Workspace)
+	return workspace.ApplyManifest(logger, servers)
+}
+
 // Close stops all MCP services in all workspaces.
 func (s *ServiceManager) Close() {
 	xl := xlog.NewLogger("servicev2")
@@ -136,6 +218,32 @@ func (s *ServiceManager) getWorkspace(logger xlog.Logger, name string, noCreateI
 	return workspace, true
 }
 
+// UpdateConfig 热加载配置，重试次数和会话限制立即应用到现有服务和会话
+func (s *ServiceManager) UpdateConfig(logger xlog.Logger, cfg config.Config) {
+	s.sessionKeysMutex.Lock()
+	s.cfg = cfg
+	s.sessionKeysMutex.Unlock()
+	s.workSpaceMgr.UpdateConfig(logger, cfg)
+}
+
+// Restore 从存储中恢复工作空间和服务，只注册不启动
+func (s *ServiceManager) Restore(logger xlog.Logger) error {
+	return s.workSpaceMgr.Restore(logger)
+}
+
+// StartRestored 启动恢复的服务中期望运行的服务
+func (s *ServiceManager) StartRestored(logger xlog.Logger) {
+	s.workSpaceMgr.StartRestored(logger)
+}
+
+// DeleteWorkspace 停止并删除工作空间及其所有服务
+func (s *ServiceManager) DeleteWorkspace(logger xlog.Logger, name NameArg) error {
+	if !s.workSpaceMgr.RemoveWorkspace(logger, name.Workspace) {
+		return errs.ErrWorkspaceNotFound
+	}
+	return nil
+}
+
 // GetWorkspaces 获取所有工作空间
 func (s *ServiceManager) GetWorkspaces() map[string]*WorkSpace {
 	return s.workSpaceMgr.GetWorkspaces()
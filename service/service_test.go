@@ -1,7 +1,12 @@
 package service
 
 import (
+	"context"
+	"io"
+	"net"
+	"net/http"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -10,7 +15,7 @@ import (
 	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
 )
 
-var mockPortMgr PortManagerI = NewPortManager()
+var mockPortMgr PortManagerI = NewPortManager(DefaultPortRangeStart, DefaultPortRangeEnd)
 
 func mockMcpServiceFileSystem(t *testing.T) *McpService {
 	pwd, err := os.Getwd()
@@ -29,6 +34,7 @@ func mockMcpServiceFileSystem(t *testing.T) *McpService {
 			"@modelcontextprotocol/server-filesystem",
 			pwd,
 		},
+		LogConfig: config.LogConfig{Path: t.TempDir()},
 	}, mockPortMgr)
 }
 
@@ -41,8 +47,9 @@ func TestMcpService_Restart_DeadlockPrevention(t *testing.T) {
 		portMgr:    mockPortMgr,
 		mutex:      sync.RWMutex{},
 		Config: config.MCPServerConfig{
-			Command: "invalid-command", // 故意使用无效命令
-			Args:    []string{"invalid-args"},
+			Command:   "invalid-command", // 故意使用无效命令
+			Args:      []string{"invalid-args"},
+			LogConfig: config.LogConfig{Path: t.TempDir()},
 			McpServiceMgrConfig: config.McpServiceMgrConfig{
 				McpServiceRetryCount: 3,
 			},
@@ -106,6 +113,9 @@ func TestMcpService_Restart_MaxRetriesReached(t *testing.T) {
 		RetryMax:   3,
 		portMgr:    mockPortMgr,
 		mutex:      sync.RWMutex{},
+		Config: config.MCPServerConfig{
+			LogConfig: config.LogConfig{Path: t.TempDir()},
+		},
 	}
 
 	logger := xlog.NewLogger("test")
@@ -125,3 +135,259 @@ func TestMcpService_Restart_MaxRetriesReached(t *testing.T) {
 		t.Errorf("Expected last error to be 'Service failed after maximum retry attempts', got %s", service.LastError)
 	}
 }
+
+// fakeStreamBridge 仅暴露 Streamable HTTP 端点的桥接器
+type fakeStreamBridge struct{}
+
+func (fakeStreamBridge) Serve(net.Listener) error       { return nil }
+func (fakeStreamBridge) Close() error                   { return nil }
+func (fakeStreamBridge) Ping(ctx context.Context) error { return nil }
+func (fakeStreamBridge) StreamableHTTPEndpoint() string { return "/stream-service" }
+
+func TestMcpService_StreamableHTTPTransportURLs(t *testing.T) {
+	service := &McpService{
+		Name:   "stream-service",
+		Status: Running,
+		Port:   10001,
+		bridge: fakeStreamBridge{},
+		Config: config.MCPServerConfig{
+			Command:   "npx",
+			Transport: config.TransportStreamableHTTP,
+		},
+		portMgr: mockPortMgr,
+	}
+
+	urls := service.Info().URLs
+	if urls.StreamableHTTPUrl != "http://127.0.0.1:10001/stream-service" {
+		t.Errorf("Unexpected streamable http url: %s", urls.StreamableHTTPUrl)
+	}
+	if urls.SSEUrl != "" || urls.MessageUrl != "" {
+		t.Errorf("Expected no SSE urls for streamable-http transport, got %+v", urls)
+	}
+}
+
+func TestMcpService_ListenUnixSocket(t *testing.T) {
+	service := &McpService{
+		Name:         "socket-service",
+		Config:       config.MCPServerConfig{Workspace: "ws", Command: "npx", Transport: config.TransportStreamableHTTP},
+		bridgeListen: config.BridgeListenConfig{SocketDir: t.TempDir()},
+		portMgr:      mockPortMgr,
+	}
+	listener, err := service.listen()
+	if err != nil {
+		t.Skipf("unix socket not supported: %v", err)
+	}
+	defer listener.Close()
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+
+	// 不分配端口，地址解析为 unix socket
+	service.Status = Running
+	service.bridge = fakeStreamBridge{}
+	info := service.Info()
+	if info.Port != 0 || info.Socket == "" {
+		t.Errorf("Expected socket without port, got port=%d socket=%q", info.Port, info.Socket)
+	}
+	if info.URLs.StreamableHTTPUrl != "http://unix/stream-service" {
+		t.Errorf("Unexpected streamable http url: %s", info.URLs.StreamableHTTPUrl)
+	}
+
+	resp, err := service.HTTPClient().Get(info.URLs.StreamableHTTPUrl)
+	if err != nil {
+		t.Fatalf("Failed to request over unix socket: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "/stream-service" {
+		t.Errorf("Unexpected response: %s", body)
+	}
+
+	service.releaseListener()
+	if service.HTTPClient() != directHTTPClient {
+		t.Error("Expected direct client after releasing the listener")
+	}
+}
+
+func TestMcpService_NeedsBridge(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.MCPServerConfig
+		expected bool
+	}{
+		{name: "stdio", cfg: config.MCPServerConfig{Command: "npx"}, expected: true},
+		{name: "remote sse", cfg: config.MCPServerConfig{URL: "http://example.com/sse"}, expected: false},
+		{name: "remote sse exposed as streamable http", cfg: config.MCPServerConfig{URL: "http://example.com/sse", Transport: config.TransportBoth}, expected: true},
+		{name: "remote streamable http", cfg: config.MCPServerConfig{URL: "http://example.com/mcp", Transport: config.TransportBoth}, expected: false},
+		{name: "remote streamable http by type", cfg: config.MCPServerConfig{URL: "http://example.com", Type: config.UpstreamStreamableHTTP, Transport: config.TransportBoth}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &McpService{Config: tt.cfg}
+			if got := service.needsBridge(); got != tt.expected {
+				t.Errorf("needsBridge() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMcpService_PrepareSandbox(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	service := NewMcpService("time", config.MCPServerConfig{
+		Workspace: "default",
+		Command:   "uvx",
+		Args:      []string{"mcp-server-time"},
+		Sandbox:   &config.SandboxConfig{OpenFiles: 128},
+	}, mockPortMgr)
+
+	command, args, envs, err := service.prepareSandbox(xl)
+	if err != nil {
+		t.Fatalf("prepareSandbox() failed: %v", err)
+	}
+	exe, _ := os.Executable()
+	if command != exe || args[len(args)-2] != "uvx" || args[len(args)-1] != "mcp-server-time" {
+		t.Errorf("Expected command to run through the sandbox launcher, got %s %v", command, args)
+	}
+	if len(envs) != 1 || envs[0] != "MCP_GATEWAY_SERVICE_ID="+service.sandboxID() {
+		t.Errorf("Unexpected sandbox envs: %v", envs)
+	}
+
+	// 未配置沙箱时直接运行命令
+	service.Config.Sandbox = nil
+	command, args, _, err = service.prepareSandbox(xl)
+	if err != nil || command != "uvx" || len(args) != 1 {
+		t.Errorf("Expected command to be unchanged, got %s %v (%v)", command, args, err)
+	}
+	service.closeSandbox(xl)
+	if service.sandbox != nil {
+		t.Error("Expected sandbox to be closed")
+	}
+}
+
+func TestWorkspaceManager_UpdateConfigRetryCount(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	mgr := NewWorkspaceManager(config.Config{}, mockPortMgr, nil)
+	workspace, _ := mgr.GetWorkspace(xl, "ws", true)
+	workspace.restoreMcpService(xl, "inherit", ServiceRecord{Config: config.MCPServerConfig{Command: "uvx"}})
+	workspace.restoreMcpService(xl, "own", ServiceRecord{Config: config.MCPServerConfig{
+		Command:             "uvx",
+		McpServiceMgrConfig: config.McpServiceMgrConfig{McpServiceRetryCount: 1},
+	}})
+
+	services := workspace.getMcpServices()
+	if services["inherit"].RetryMax != 3 || services["own"].RetryMax != 1 {
+		t.Fatalf("Unexpected initial retry max: %d, %d", services["inherit"].RetryMax, services["own"].RetryMax)
+	}
+	// 已消耗一次重试
+	services["inherit"].RetryCount = 2
+
+	mgr.UpdateConfig(xl, config.Config{McpServiceMgrConfig: config.McpServiceMgrConfig{McpServiceRetryCount: 5}})
+	if services["inherit"].RetryMax != 5 || services["inherit"].RetryCount != 4 {
+		t.Errorf("Expected inherited retry to be 4/5, got %d/%d", services["inherit"].RetryCount, services["inherit"].RetryMax)
+	}
+	if services["own"].RetryMax != 1 {
+		t.Errorf("Expected service's own retry count to be kept, got %d", services["own"].RetryMax)
+	}
+	if workspace.retryMax(config.MCPServerConfig{}) != 5 {
+		t.Errorf("Expected new services to use updated retry count")
+	}
+}
+
+func TestMcpService_InfoRedactsConfig(t *testing.T) {
+	cfg := config.MCPServerConfig{
+		Command:     "uvx",
+		Env:         map[string]string{"PLAIN": "value", "TOKEN": "${secret:token}"},
+		Headers:     map[string]string{"X-Api-Key": "key"},
+		BearerToken: "token",
+	}
+	svc := NewMcpService("test", cfg, mockPortMgr)
+
+	info := svc.Info()
+	if info.Config.Env["PLAIN"] != config.RedactedValue {
+		t.Errorf("Expected plain env value to be redacted, got %q", info.Config.Env["PLAIN"])
+	}
+	if info.Config.Env["TOKEN"] != "${secret:token}" {
+		t.Errorf("Expected secret reference to be kept, got %q", info.Config.Env["TOKEN"])
+	}
+	if info.Config.Headers["X-Api-Key"] != config.RedactedValue || info.Config.BearerToken != config.RedactedValue {
+		t.Errorf("Expected headers and bearer token to be redacted, got %v %q", info.Config.Headers, info.Config.BearerToken)
+	}
+	// 原始配置不受影响
+	if svc.Config.Env["PLAIN"] != "value" || svc.Config.BearerToken != "token" {
+		t.Errorf("Expected service config to be unchanged")
+	}
+}
+
+func TestMcpService_InfoWithConcurrentWriter(t *testing.T) {
+	svc := NewMcpService("remote", config.MCPServerConfig{URL: "http://127.0.0.1:1/sse", Type: config.UpstreamSSE}, mockPortMgr)
+	svc.Status = Running
+
+	// Info 持有读锁时不能再次获取读锁，否则等待中的写锁会让两者互相等待
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			svc.mutex.Lock()
+			svc.mutex.Unlock()
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+			if info := svc.Info(); info.URLs.SSEUrl != svc.Config.URL {
+				t.Errorf("Unexpected sse url %q", info.URLs.SSEUrl)
+				return
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Info() deadlocked with a concurrent writer")
+	}
+}
+
+func TestMcpService_FailedStartClosesSandbox(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	svc := newTestStdioService(t, "broken", nil)
+	svc.Config.Command = filepath.Join(t.TempDir(), "missing")
+	defer svc.Stop(xl)
+
+	if err := svc.Start(xl); err == nil {
+		t.Fatal("Expected start to fail")
+	}
+	// 启动失败的服务停止时不再清理，需要在失败时删除沙箱
+	svc.mutex.RLock()
+	defer svc.mutex.RUnlock()
+	if svc.Status != Failed || svc.sandbox != nil {
+		t.Errorf("Expected sandbox to be closed after failed start, status %s, sandbox %v", svc.Status, svc.sandbox)
+	}
+}
+
+func TestMcpService_ListenTCPOnLoopback(t *testing.T) {
+	svc := NewMcpService("tcp", config.MCPServerConfig{Workspace: "default", Command: "uvx"}, mockPortMgr)
+	svc.bridgeListen = config.BridgeListenConfig{Network: config.BridgeNetworkTCP}
+
+	svc.mutex.Lock()
+	defer svc.mutex.Unlock()
+	listener, err := svc.listen()
+	if err != nil {
+		t.Fatalf("listen() failed: %v", err)
+	}
+	defer svc.releaseListener()
+	defer listener.Close()
+
+	// tcp 模式下桥接器只监听本机地址
+	addr := listener.Addr().(*net.TCPAddr)
+	if !addr.IP.IsLoopback() || svc.listenAddr() != listener.Addr().String() {
+		t.Errorf("Expected bridge to listen on loopback, got %s (reported %s)", addr, svc.listenAddr())
+	}
+}
@@ -6,33 +6,53 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
 	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
 	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
 type McpName = string
 type McpToolName = string
 
+// SessionTransport 表示下游客户端接入会话所使用的传输方式
+type SessionTransport string
+
+const (
+	SessionTransportSSE            SessionTransport = "sse"             // 传统 /sse + /message
+	SessionTransportStreamableHTTP SessionTransport = "streamable-http" // /mcp
+)
+
+// methodCompletionComplete 参数补全请求，mcp-go 没有定义对应的方法常量
+const methodCompletionComplete mcp.MCPMethod = "completion/complete"
+
 type Session struct {
 	// 使用单一主锁减少死锁风险
 	mu sync.RWMutex
 
 	Id              string
 	CreatedAt       time.Time // 会话创建时间
-	LastReceiveTime time.Time // 最后一次接收消息的时间
+	LastReceiveTime time.Time // 最后一次收到客户端消息或向客户端发送消息的时间
+	Transport       SessionTransport
 
 	// SSE事件通道 - 由主锁保护
 	eventChans []chan SessionMsg
 	doneChan   chan struct{}
 
-	// 清理机制
-	cleanupCallback func(sessionId string) // 清理回调函数
+	// 等待指定请求响应的通道（Streamable HTTP 使用），key 为 RequestId.String() - 由主锁保护
+	responseWaiters map[string]chan SessionMsg
+	// 正在处理的请求，客户端发送 notifications/cancelled 时取消，key 为 RequestId.String() - 由主锁保护
+	inflightRequests map[string]context.CancelCauseFunc
+
+	// 清理机制，空闲和超过存活时间的会话由 SessionManager 统一回收
+	cleanupCallback func(sessionId string) // SSE 客户端断开后未重连时调用
+	apiKey          string                 // 创建会话使用的 API Key，用于限制每个 API Key 的会话数
+	closeHooks      []func()               // 会话关闭后执行，用于释放为会话启动的子进程
 
 	// 工具映射 - 由主锁保护
 	mcpToolsMap       map[McpName]map[McpToolName]mcp.Tool
@@ -43,9 +63,27 @@ type Session struct {
 	// 避免重复返回 - 由主锁保护
 	lastMsg SessionMsg
 
+	// 最近发送的事件，断线重连的客户端通过 Last-Event-ID 重放错过的事件 - 由主锁保护
+	eventLog      []SessionMsg
+	eventLogBytes int // eventLog 中事件数据的总字节数
+	lastEventId   uint64
+	resumeTimer   *time.Timer // SSE 客户端断开后等待重连，超时未重连时清理会话
+
+	// 对外名称与路由表，由所属工作空间的配置决定
+	namespace *Namespace
+	// 响应 initialize 时是否合并各MCP的 instructions - 由主锁保护
+	mergeInstructions bool
+
+	// 每个MCP的工具过滤规则，被过滤的工具不出现在工具列表中，也不能调用 - 由主锁保护
+	toolFilters map[McpName]*config.ToolFilter
+
 	// V2
 	mcpClients           map[McpName]client.MCPClient
 	mcpinitializeResults map[McpName]*mcp.InitializeResult
+	// 订阅时服务的启动序号，与服务当前的序号不同说明服务已重新启动 - 由主锁保护
+	mcpStartGens map[McpName]uint64
+	// 工作空间中各个MCP的订阅情况，包括尚未加入会话的MCP - 由主锁保护
+	attachments map[McpName]McpAttachment
 }
 
 func NewSession(id string) *Session {
@@ -54,18 +92,22 @@ func NewSession(id string) *Session {
 		Id:                   id,
 		CreatedAt:            now,
 		LastReceiveTime:      now,
+		Transport:            SessionTransportSSE,
 		eventChans:           make([]chan SessionMsg, 0),
+		responseWaiters:      make(map[string]chan SessionMsg),
+		inflightRequests:     make(map[string]context.CancelCauseFunc),
 		doneChan:             make(chan struct{}),
 		mcpToolsMap:          make(map[McpName]map[McpToolName]mcp.Tool),
 		aggregatedTools:      make([]mcp.Tool, 0),
 		toolsListComplete:    atomic.Bool{},
 		mcpClients:           make(map[McpName]client.MCPClient),
 		mcpinitializeResults: make(map[McpName]*mcp.InitializeResult),
+		mcpStartGens:         make(map[McpName]uint64),
+		attachments:          make(map[McpName]McpAttachment),
+		namespace:            NewNamespace(config.NamespaceConfig{}),
+		toolFilters:          make(map[McpName]*config.ToolFilter),
 	}
 
-	// 启动监控协程
-	go session.startInactivityMonitor()
-
 	return session
 }
 
@@ -76,39 +118,75 @@ func (s *Session) SetCleanupCallback(callback func(sessionId string)) {
 	s.cleanupCallback = callback
 }
 
-// startInactivityMonitor 启动不活跃监控
-func (s *Session) startInactivityMonitor() {
-	ticker := time.NewTicker(30 * time.Second) // 每30秒检查一次
-	defer ticker.Stop()
+// OnClose 注册会话关闭后执行的函数，在关闭所有MCP客户端之后执行，会话已关闭时立即执行
+func (s *Session) OnClose(hook func()) {
+	s.mu.Lock()
+	if s.isClosed() {
+		s.mu.Unlock()
+		hook()
+		return
+	}
+	s.closeHooks = append(s.closeHooks, hook)
+	s.mu.Unlock()
+}
 
-	for {
-		select {
-		case <-s.doneChan:
-			return
-		case <-ticker.C:
-			s.checkInactivity()
-		}
+// isClosed 会话是否已关闭
+func (s *Session) isClosed() bool {
+	select {
+	case <-s.doneChan:
+		return true
+	default:
+		return false
 	}
 }
 
-// checkInactivity 检查session是否应该被清理
-func (s *Session) checkInactivity() {
+// SetTransport 设置下游客户端使用的传输方式
+func (s *Session) SetTransport(transport SessionTransport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Transport = transport
+}
+
+// GetTransport 获取下游客户端使用的传输方式
+func (s *Session) GetTransport() SessionTransport {
 	s.mu.RLock()
-	hasActiveChans := len(s.eventChans) > 0
-	lastActivity := s.LastReceiveTime
-	cleanupCallback := s.cleanupCallback
-	sessionId := s.Id
-	s.mu.RUnlock()
+	defer s.mu.RUnlock()
+	return s.Transport
+}
 
-	// 如果没有活跃的事件通道且超过5分钟没有活动，则清理session
-	if !hasActiveChans && time.Since(lastActivity) > 5*time.Minute {
-		xl := xlog.NewLogger("session-monitor")
-		xl.Infof("Session %s is inactive, triggering cleanup", sessionId)
+// SetNamespace 设置命名空间，需要在订阅MCP服务之前调用
+func (s *Session) SetNamespace(namespace *Namespace) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.namespace = namespace
+}
 
-		if cleanupCallback != nil {
-			cleanupCallback(sessionId)
-		}
-	}
+// SetMergeInstructions 设置响应 initialize 时是否合并各MCP的 instructions
+func (s *Session) SetMergeInstructions(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mergeInstructions = enabled
+}
+
+// SetToolFilter 设置MCP的工具过滤规则，需在订阅MCP前调用
+func (s *Session) SetToolFilter(mcpName McpName, filter *config.ToolFilter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.toolFilters[mcpName] = filter
+}
+
+// toolAllowed 判断MCP的工具是否允许调用
+func (s *Session) toolAllowed(mcpName McpName, toolName string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.toolFilters[mcpName].Allowed(toolName)
+}
+
+// GetNamespace 获取命名空间
+func (s *Session) GetNamespace() *Namespace {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.namespace
 }
 
 func (s *Session) GetId() string {
@@ -116,6 +194,7 @@ func (s *Session) GetId() string {
 }
 
 func (s *Session) SendMessage(xl xlog.Logger, content json.RawMessage) (err error) {
+	s.touch()
 	// 发送消息到 MCP 服务
 	var request mcp.JSONRPCRequest
 	if err = json.Unmarshal([]byte(content), &request); err != nil {
@@ -127,66 +206,125 @@ func (s *Session) SendMessage(xl xlog.Logger, content json.RawMessage) (err erro
 
 	xl.Debugf("Sending request: %+v", request)
 
-	// xl.Infof("method: %s, content: %s", method, content)
-	var singleMcp McpName
-	switch mcp.MCPMethod(request.Method) {
-	case mcp.MethodToolsCall:
-		req := mcp.CallToolRequest{}
-		err := json.Unmarshal([]byte(content), &req)
-		if err != nil {
-			xl.Errorf("failed to unmarshal request: %v", err)
-			return fmt.Errorf("failed to unmarshal request: %w", err)
-		}
-
-		// mcpName_toolName  ->  toolName
-		if names := strings.Split(req.Params.Name, "_"); len(names) >= 2 {
-			singleMcp = names[0]
-			req.Params.Name = strings.Join(names[1:], "_")
+	// 通知和客户端的响应由网关处理，不转发到MCP
+	if method == "" || request.ID.IsNil() {
+		return s.handleClientMessage(xl, request, content)
+	}
 
-			// 重新序列化请求以更新工具名
-			updatedContent, err := json.Marshal(req)
-			if err != nil {
-				xl.Errorf("failed to marshal updated request: %v", err)
-				return fmt.Errorf("failed to marshal updated request: %w", err)
-			}
-			content = updatedContent
-		}
+	// 需要路由到单个MCP的请求，去掉名称中的MCP前缀
+	singleMcp, content, err := s.routeToOwner(request, content)
+	if err != nil {
+		xl.Errorf("failed to route request: %v", err)
+		s.sendErrorResponse(request.ID, err)
+		return err
 	}
 
 	// 对所有 MCP 服务器发送消息
 	if singleMcp == "" {
+		// initialize 由网关作为聚合会话的 MCP 服务器直接响应
+		if method == string(mcp.MethodInitialize) {
+			return s.handleInitializeRequest(xl, request, content)
+		}
 		// 如果是tools/list请求，需要特殊处理来聚合所有MCP的工具
 		if method == "tools/list" {
 			return s.handleToolsListRequest(xl, request)
 		}
-
-		// 其他请求照常处理
-		s.mu.RLock()
-		mcpNames := make([]McpName, 0, len(s.mcpClients))
-		for mcpName := range s.mcpClients {
-			mcpNames = append(mcpNames, mcpName)
+		// 资源、资源模板、提示词列表同样需要合并为一个响应
+		if isAggregatedListMethod(method) {
+			return s.handleAggregatedListRequest(xl, request)
 		}
-		s.mu.RUnlock()
 
-		for _, mcpName := range mcpNames {
-			err = s.sendToMcp(xl, mcpName, request, content)
-			if err != nil {
-				xl.Errorf("failed to send to allmcp: %v", err)
-				continue
-			}
-		}
-	} else {
-		// xl.Infof("send to single MCP server: %s, content: %s", singleMcp, content)
-		err = s.sendToMcp(xl, singleMcp, request, content)
-		if err != nil {
-			xl.Errorf("failed to send to singlemcp: %v", err)
-			return err
-		}
+		// 其他请求发送到所有MCP，只返回一个响应
+		return s.handleBroadcastRequest(xl, request, content)
+	}
+
+	// xl.Infof("send to single MCP server: %s, content: %s", singleMcp, content)
+	err = s.sendToMcp(xl, singleMcp, request, content)
+	if err != nil {
+		xl.Errorf("failed to send to singlemcp: %v", err)
+		return err
 	}
 
 	return nil
 }
 
+// routeToOwner 根据对外的工具名、资源URI或提示词名称找到所属MCP，并改写为上游原始名称
+// 不需要路由到单个MCP的请求返回空的MCP名称
+func (s *Session) routeToOwner(request mcp.JSONRPCRequest, content json.RawMessage) (McpName, json.RawMessage, error) {
+	var req interface{}
+	var name *string
+	var kind nameKind
+	switch mcp.MCPMethod(request.Method) {
+	case mcp.MethodToolsCall:
+		callReq := &mcp.CallToolRequest{}
+		req, name, kind = callReq, &callReq.Params.Name, nameKindTool
+	case mcp.MethodResourcesRead:
+		readReq := &mcp.ReadResourceRequest{}
+		req, name, kind = readReq, &readReq.Params.URI, nameKindResource
+	case mcp.MethodPromptsGet:
+		promptReq := &mcp.GetPromptRequest{}
+		req, name, kind = promptReq, &promptReq.Params.Name, nameKindPrompt
+	case methodCompletionComplete:
+		return s.routeCompletion(request, content)
+	default:
+		return "", content, nil
+	}
+
+	if err := json.Unmarshal(content, req); err != nil {
+		return "", nil, fmt.Errorf("failed to unmarshal request: %w", err)
+	}
+
+	// 对外名称  ->  mcpName, 原始名称
+	mcpName, upstreamName, ok := s.GetNamespace().Resolve(kind, *name, s.getMcpNames())
+	if !ok {
+		return "", nil, fmt.Errorf("%s: no mcp server found for %q", request.Method, *name)
+	}
+	if kind == nameKindTool && !s.toolAllowed(mcpName, upstreamName) {
+		return "", nil, fmt.Errorf("%s: tool %q is not exposed by the gateway", request.Method, *name)
+	}
+	*name = upstreamName
+
+	// 重新序列化请求以更新名称
+	updatedContent, err := json.Marshal(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal updated request: %w", err)
+	}
+	return mcpName, updatedContent, nil
+}
+
+// routeCompletion 根据补全请求引用的提示词名称或资源URI找到所属MCP，并改写为上游原始名称
+func (s *Session) routeCompletion(request mcp.JSONRPCRequest, content json.RawMessage) (McpName, json.RawMessage, error) {
+	completeReq := &mcp.CompleteRequest{}
+	if err := json.Unmarshal(content, completeReq); err != nil {
+		return "", nil, fmt.Errorf("failed to unmarshal request: %w", err)
+	}
+
+	ref, _ := completeReq.Params.Ref.(map[string]interface{})
+	var kind nameKind
+	var field string
+	switch ref["type"] {
+	case "ref/prompt":
+		kind, field = nameKindPrompt, "name"
+	case "ref/resource":
+		kind, field = nameKindResource, "uri"
+	default:
+		return "", nil, fmt.Errorf("%s: unsupported reference type %v", request.Method, ref["type"])
+	}
+
+	visible, _ := ref[field].(string)
+	mcpName, upstreamName, ok := s.GetNamespace().Resolve(kind, visible, s.getMcpNames())
+	if !ok {
+		return "", nil, fmt.Errorf("%s: no mcp server found for %q", request.Method, visible)
+	}
+	ref[field] = upstreamName
+
+	updatedContent, err := json.Marshal(completeReq)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal updated request: %w", err)
+	}
+	return mcpName, updatedContent, nil
+}
+
 func (s *Session) sendToMcp(xl xlog.Logger, mcpName McpName, baseReq mcp.JSONRPCRequest, reqRaw json.RawMessage) error {
 	xl = xlog.WithChildName(mcpName, xl)
 
@@ -196,13 +334,20 @@ func (s *Session) sendToMcp(xl xlog.Logger, mcpName McpName, baseReq mcp.JSONRPC
 	if !ok {
 		err := fmt.Errorf("failed to find mcpClient for %s", mcpName)
 		xl.Error(err)
+		s.sendErrorResponse(baseReq.ID, err)
 		return err
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
+	ctx, done := s.trackRequest(ctx, baseReq.ID)
+	defer done()
 
 	result, err := s.handleMCPMethod(ctx, xl, mCli, mcpName, baseReq.Method, reqRaw)
+	if isCancelledByClient(ctx) {
+		xl.Infof("Request %s cancelled by client, dropping response", baseReq.ID.String())
+		return nil
+	}
 	if err != nil {
 		xl.Errorf("failed to call MCP method %s: %v", baseReq.Method, err)
 		s.sendErrorResponse(baseReq.ID, err)
@@ -217,14 +362,32 @@ func (s *Session) sendToMcp(xl xlog.Logger, mcpName McpName, baseReq mcp.JSONRPC
 }
 
 // SubscribeSSE 订阅MCP服务的SSE事件
-func (s *Session) SubscribeSSE(xl xlog.Logger, mcpName McpName, sseUrl string) error {
-	cli, err := client.NewSSEMCPClient(sseUrl)
+func (s *Session) SubscribeSSE(xl xlog.Logger, mcpName McpName, sseUrl string, options ...transport.ClientOption) error {
+	cli, err := client.NewSSEMCPClient(sseUrl, options...)
 	if err != nil {
 		return fmt.Errorf("failed to create SSE client: %w", err)
 	}
+	return s.subscribe(xl, mcpName, cli)
+}
+
+// SubscribeStreamableHTTP 通过 Streamable HTTP 订阅MCP服务
+func (s *Session) SubscribeStreamableHTTP(xl xlog.Logger, mcpName McpName, url string, options ...transport.StreamableHTTPCOption) error {
+	cli, err := client.NewStreamableHttpClient(url, options...)
+	if err != nil {
+		return fmt.Errorf("failed to create streamable http client: %w", err)
+	}
+	return s.subscribe(xl, mcpName, cli)
+}
 
-	if err = cli.Start(context.TODO()); err != nil {
-		return fmt.Errorf("failed to start SSE client: %w", err)
+// subscribe 启动并初始化MCP客户端，成功后加入会话
+func (s *Session) subscribe(xl xlog.Logger, mcpName McpName, cli *client.Client) error {
+	// 在启动前注册，避免丢失初始化期间的通知
+	cli.OnNotification(func(notification mcp.JSONRPCNotification) {
+		s.forwardNotification(xl, mcpName, notification)
+	})
+
+	if err := cli.Start(context.TODO()); err != nil {
+		return fmt.Errorf("failed to start client: %w", err)
 	}
 
 	result, err := cli.Initialize(context.TODO(), mcp.InitializeRequest{
@@ -237,27 +400,77 @@ func (s *Session) SubscribeSSE(xl xlog.Logger, mcpName McpName, sseUrl string) e
 		},
 	})
 	if err != nil {
-		return fmt.Errorf("failed to initialize SSE client: %w", err)
+		cli.Close()
+		return fmt.Errorf("failed to initialize client: %w", err)
 	}
 
 	if err = cli.Ping(context.TODO()); err != nil {
-		return fmt.Errorf("failed to ping SSE client: %w", err)
+		cli.Close()
+		return fmt.Errorf("failed to ping client: %w", err)
 	}
 
-	xl.Info("SSE client initialized and connected successfully")
+	xl.Infof("MCP client for %s initialized and connected successfully", mcpName)
 
 	// 优化：批量更新状态，减少锁竞争
 	s.mu.Lock()
+	if s.isClosed() {
+		s.mu.Unlock()
+		cli.Close()
+		return fmt.Errorf("session %s is closed", s.Id)
+	}
+	previous := s.mcpClients[mcpName]
 	s.mcpClients[mcpName] = cli
 	s.mcpinitializeResults[mcpName] = result
+	// 重新订阅后工具列表需要重新获取
+	delete(s.mcpToolsMap, mcpName)
 	s.mu.Unlock()
 
+	if previous != nil {
+		xl.Infof("Replacing MCP client for %s", mcpName)
+		if err := previous.Close(); err != nil {
+			xl.Warnf("Error closing previous MCP client %s: %v", mcpName, err)
+		}
+	}
 	return nil
 }
 
+// forwardNotification 将上游MCP服务主动发出的通知转发到会话的事件通道，工具和资源标识改写为带前缀的形式
+func (s *Session) forwardNotification(xl xlog.Logger, mcpName McpName, notification mcp.JSONRPCNotification) {
+	params := notification.Params.AdditionalFields
+	namespace := s.GetNamespace()
+	switch notification.Method {
+	case mcp.MethodNotificationToolsListChanged:
+		// 上游工具列表已变化，已聚合的工具列表失效
+		s.toolsListComplete.Store(false)
+	case mcp.MethodNotificationResourceUpdated:
+		if uri, ok := params["uri"].(string); ok {
+			params["uri"] = namespace.Visible(nameKindResource, mcpName, uri)
+		}
+	case "notifications/message":
+		if logger, ok := params["logger"].(string); ok && logger != "" {
+			params["logger"] = namespace.Prefix(mcpName, logger)
+		} else if params != nil {
+			params["logger"] = mcpName
+		}
+	}
+
+	data, err := json.Marshal(notification)
+	if err != nil {
+		xl.Errorf("failed to marshal notification %s from %s: %v", notification.Method, mcpName, err)
+		return
+	}
+	xl.Debugf("Forwarding notification %s from %s", notification.Method, mcpName)
+	// 上游可能连续发出相同的通知（如多次 list_changed 或相同的日志），都需要转发
+	s.sendEventNoDedup(SessionMsg{
+		Event: "message",
+		Data:  string(data),
+	})
+}
+
 type SessionMsg struct {
 	proxyId  int64
 	clientId int64
+	Id       uint64 `json:"id,omitempty"` // 事件序号，会话内单调递增
 	Event    string `json:"event"`
 	Data     string `json:"data"`
 }
@@ -282,15 +495,25 @@ func (s *Session) Close() {
 	xl.Infof("Closing session: %s", s.Id)
 
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	hooks := s.closeHooks
+	s.closeHooks = nil
+	defer func() {
+		s.mu.Unlock()
+		for _, hook := range hooks {
+			hook()
+		}
+	}()
 
-	// 关闭监控协程
+	// 标记会话已关闭
 	select {
 	case <-s.doneChan:
 		// 已经关闭
 	default:
 		close(s.doneChan)
 	}
+	if s.resumeTimer != nil {
+		s.resumeTimer.Stop()
+	}
 
 	// 关闭所有MCP客户端
 	for mcpName, client := range s.mcpClients {
@@ -312,33 +535,39 @@ func (s *Session) Close() {
 
 // SendEvent 发送SSE事件
 func (s *Session) SendEvent(event SessionMsg) {
+	s.sendEvent(event, true)
+}
+
+// sendEventNoDedup 发送SSE事件，不检查重复，用于网关主动发出、内容相同但需要多次发送的通知
+func (s *Session) sendEventNoDedup(event SessionMsg) {
+	s.sendEvent(event, false)
+}
+
+// sendEvent 为事件分配序号并记录到事件日志，然后发送到所有事件通道，dedup 为 true 时丢弃与上一条相同的事件
+func (s *Session) sendEvent(event SessionMsg, dedup bool) {
 	xl := xlog.NewLogger("session-" + s.Id)
 	xl.Infof("Sending event: %s, data: %s", event.Event, event.Data)
 
-	// 优化：一次性获取需要的数据，减少锁持有时间
-	s.mu.RLock()
-	isDuplicate := s.lastMsg.isDuplicate(&event)
-	eventChans := make([]chan SessionMsg, len(s.eventChans))
-	copy(eventChans, s.eventChans)
-	s.mu.RUnlock()
-
-	if isDuplicate {
+	s.mu.Lock()
+	if dedup && s.lastMsg.isDuplicate(&event) {
+		s.mu.Unlock()
 		xl.Debugf("Event already sent: %s", event.Event)
 		return
 	}
-
-	// 优化：并发发送事件到所有通道
-	sentToChannels := s.broadcastEvent(eventChans, event, xl)
-
-	// 只在成功发送后更新状态
+	// 在锁内分配序号并广播，保证各通道收到事件的顺序与序号一致
+	event = s.appendEventLocked(event)
+	sentToChannels := s.broadcastEvent(s.eventChans, event, xl)
+	totalChannels := len(s.eventChans)
+	s.lastMsg = event
 	if sentToChannels > 0 {
-		s.mu.Lock()
 		s.LastReceiveTime = time.Now()
-		s.lastMsg = event
-		s.mu.Unlock()
-		xl.Infof("Event sent to %d channels", sentToChannels)
+	}
+	s.mu.Unlock()
+
+	if sentToChannels > 0 {
+		xl.Infof("Event %d sent to %d channels", event.Id, sentToChannels)
 	} else {
-		xl.Warnf("Event not sent to any channels (total channels: %d)", len(eventChans))
+		xl.Warnf("Event %d not sent to any channels (total channels: %d), kept for replay", event.Id, totalChannels)
 	}
 }
 
@@ -363,49 +592,67 @@ func (s *Session) broadcastEvent(eventChans []chan SessionMsg, event SessionMsg,
 func (s *Session) GetEventChan() <-chan SessionMsg {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	curChan := make(chan SessionMsg, 100)
-	s.eventChans = append(s.eventChans, curChan)
-
-	return curChan
+	return s.addEventChanLocked()
 }
 
 // GetEventChanWithCloser 获取事件通道并返回关闭函数
 func (s *Session) GetEventChanWithCloser() (<-chan SessionMsg, func()) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	curChan := make(chan SessionMsg, 100)
-	s.eventChans = append(s.eventChans, curChan)
+	curChan := s.addEventChanLocked()
 
 	closer := func() {
-		close(curChan)
-		// 关闭后立即从列表中移除
+		// 从列表中移除并关闭，会话关闭时通道已被统一关闭，这里不会重复关闭
 		s.removeEventChan(curChan)
 	}
 
 	return curChan, closer
 }
 
+// addEventChanLocked 创建并登记事件通道，客户端重新连接时取消等待重连的清理，调用方需持有锁
+func (s *Session) addEventChanLocked() chan SessionMsg {
+	if s.resumeTimer != nil {
+		s.resumeTimer.Stop()
+		s.resumeTimer = nil
+	}
+	curChan := make(chan SessionMsg, 100)
+	s.eventChans = append(s.eventChans, curChan)
+	return curChan
+}
+
 // removeEventChan 从事件通道列表中移除指定通道
 func (s *Session) removeEventChan(targetChan chan SessionMsg) {
 	s.mu.Lock()
-	var shouldTriggerCleanup bool
-	var cleanupCallback func(sessionId string)
-	var sessionId string
+	defer s.mu.Unlock()
 
 	for i, ch := range s.eventChans {
 		if ch == targetChan {
 			// 移除通道
 			s.eventChans = append(s.eventChans[:i], s.eventChans[i+1:]...)
+			close(ch)
 			break
 		}
 	}
 
-	// 检查是否所有通道都已关闭
-	if len(s.eventChans) == 0 {
-		shouldTriggerCleanup = true
-		cleanupCallback = s.cleanupCallback
-		sessionId = s.Id
+	// 检查是否所有通道都已关闭，SSE 客户端可能因网络波动断开，等待重连后再清理
+	// Streamable HTTP 会话的 GET 流是可选的，断开后会话仍然有效，由不活跃监控负责清理
+	if len(s.eventChans) == 0 && s.Transport == SessionTransportSSE && !s.isClosed() && s.resumeTimer == nil {
+		var timer *time.Timer
+		timer = time.AfterFunc(sessionResumeWindow, func() { s.expireResume(timer) })
+		s.resumeTimer = timer
+	}
+}
+
+// expireResume 等待重连超时，客户端没有重新连接时清理会话
+func (s *Session) expireResume(timer *time.Timer) {
+	s.mu.Lock()
+	// 期间客户端重新连接过，计时器已被取消或替换
+	shouldTriggerCleanup := s.resumeTimer == timer
+	if shouldTriggerCleanup {
+		s.resumeTimer = nil
 	}
+	cleanupCallback := s.cleanupCallback
+	sessionId := s.Id
 	s.mu.Unlock()
 
 	// 如果没有活跃通道，触发清理
@@ -416,6 +663,45 @@ func (s *Session) removeEventChan(targetChan chan SessionMsg) {
 	}
 }
 
+// WaitResponse 注册一个等待指定请求响应的通道，响应将直接投递到该通道而不会广播到事件通道
+// 返回的函数用于取消等待
+func (s *Session) WaitResponse(id mcp.RequestId) (<-chan SessionMsg, func()) {
+	key := id.String()
+	waiter := make(chan SessionMsg, 1)
+
+	s.mu.Lock()
+	s.responseWaiters[key] = waiter
+	s.mu.Unlock()
+
+	cancel := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if cur, ok := s.responseWaiters[key]; ok && cur == waiter {
+			delete(s.responseWaiters, key)
+		}
+	}
+	return waiter, cancel
+}
+
+// deliverToWaiter 将响应投递给等待者，没有等待者时返回false
+func (s *Session) deliverToWaiter(id mcp.RequestId, msg SessionMsg) bool {
+	key := id.String()
+
+	s.mu.Lock()
+	waiter, ok := s.responseWaiters[key]
+	if ok {
+		delete(s.responseWaiters, key)
+		s.LastReceiveTime = time.Now()
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+	waiter <- msg
+	return true
+}
+
 // GetMcpTools 获取指定 MCP 的所有工具
 func (s *Session) GetMcpTools(mcpName McpName) map[McpToolName]mcp.Tool {
 	s.mu.RLock()
@@ -448,7 +734,10 @@ func (s *Session) sendResponse(requestId interface{}, result interface{}, err er
 	var responseData []byte
 	var marshalErr error
 
-	reqId := mcp.NewRequestId(requestId)
+	reqId, ok := requestId.(mcp.RequestId)
+	if !ok {
+		reqId = mcp.NewRequestId(requestId)
+	}
 
 	if err != nil {
 		// 发送错误响应
@@ -481,10 +770,14 @@ func (s *Session) sendResponse(requestId interface{}, result interface{}, err er
 		return
 	}
 
-	s.SendEvent(SessionMsg{
+	msg := SessionMsg{
 		Event: "message",
 		Data:  string(responseData),
-	})
+	}
+	if s.deliverToWaiter(reqId, msg) {
+		return
+	}
+	s.SendEvent(msg)
 }
 
 // sendSuccessResponse 发送成功响应到SSE
@@ -560,14 +853,7 @@ func (s *Session) sendToolsListToMcp(xl xlog.Logger, mcpName McpName, baseReq mc
 	}
 
 	// 处理工具列表响应
-	s.mu.Lock()
-	if s.mcpToolsMap[mcpName] == nil {
-		s.mcpToolsMap[mcpName] = make(map[McpToolName]mcp.Tool)
-	}
-	for _, tool := range result.Tools {
-		s.mcpToolsMap[mcpName][tool.Name] = tool
-	}
-	s.mu.Unlock()
+	s.updateToolsMap(mcpName, result)
 
 	xl.Debugf("Received %d tools from MCP %s", len(result.Tools), mcpName)
 
@@ -604,14 +890,17 @@ func (s *Session) handleAllToolsRequests(xl xlog.Logger, requestId interface{},
 		xl.Warn("Timeout waiting for MCP tools list responses")
 	}
 
-	// 聚合所有工具并添加MCP名称前缀
+	// 聚合所有工具并添加MCP名称前缀，按名称排序保证对外名称稳定
 	s.mu.Lock()
 	s.aggregatedTools = make([]mcp.Tool, 0)
-	for mcpName, tools := range s.mcpToolsMap {
-		for _, tool := range tools {
-			// 创建带前缀的工具副本
+	for _, mcpName := range sortedKeys(s.mcpToolsMap) {
+		tools := s.mcpToolsMap[mcpName]
+		s.namespace.Forget(nameKindTool, mcpName)
+		for _, toolName := range sortedKeys(tools) {
+			tool := tools[toolName]
+			// 创建带前缀的工具副本，并登记路由
 			prefixedTool := mcp.Tool{
-				Name:        fmt.Sprintf("%s_%s", mcpName, tool.Name),
+				Name:        s.namespace.Register(nameKindTool, mcpName, tool.Name),
 				Description: fmt.Sprintf("[%s] %s", mcpName, tool.Description),
 				InputSchema: tool.InputSchema,
 			}
@@ -694,7 +983,11 @@ func (s *Session) handleMCPMethod(ctx context.Context, xl xlog.Logger, mCli clie
 		if err := json.Unmarshal(reqRaw, &request); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal readResource request: %w", err)
 		}
-		return mCli.ReadResource(ctx, request)
+		result, err := mCli.ReadResource(ctx, request)
+		if err == nil {
+			namespacedResourceContents(s.GetNamespace(), mcpName, result)
+		}
+		return result, err
 
 	case mcp.MethodPromptsList:
 		var request mcp.ListPromptsRequest
@@ -728,6 +1021,13 @@ func (s *Session) handleMCPMethod(ctx context.Context, xl xlog.Logger, mCli clie
 		}
 		return mCli.CallTool(ctx, request)
 
+	case methodCompletionComplete:
+		var request mcp.CompleteRequest
+		if err := json.Unmarshal(reqRaw, &request); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal complete request: %w", err)
+		}
+		return mCli.Complete(ctx, request)
+
 	default:
 		return nil, fmt.Errorf("unsupported method: %s", method)
 	}
@@ -740,7 +1040,11 @@ func (s *Session) updateToolsMap(mcpName McpName, result *mcp.ListToolsResult) {
 	if s.mcpToolsMap[mcpName] == nil {
 		s.mcpToolsMap[mcpName] = make(map[McpToolName]mcp.Tool)
 	}
+	filter := s.toolFilters[mcpName]
 	for _, tool := range result.Tools {
+		if !filter.Allowed(tool.Name) {
+			continue
+		}
 		s.mcpToolsMap[mcpName][tool.Name] = tool
 	}
 }
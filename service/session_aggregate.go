@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// aggregateListTimeout 聚合列表请求时等待所有MCP响应的最长时间
+const aggregateListTimeout = 30 * time.Second
+
+// isAggregatedListMethod 判断是否是需要合并所有MCP结果的列表请求（tools/list 单独处理）
+func isAggregatedListMethod(method string) bool {
+	switch mcp.MCPMethod(method) {
+	case mcp.MethodResourcesList, mcp.MethodResourcesTemplatesList, mcp.MethodPromptsList:
+		return true
+	}
+	return false
+}
+
+// sortedKeys 按key排序返回，保证聚合结果顺序稳定
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// getMcpNames 获取会话中所有MCP名称，按名称排序保证聚合结果顺序稳定
+func (s *Session) getMcpNames() []McpName {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	mcpNames := make([]McpName, 0, len(s.mcpClients))
+	for mcpName := range s.mcpClients {
+		mcpNames = append(mcpNames, mcpName)
+	}
+	sort.Strings(mcpNames)
+	return mcpNames
+}
+
+// handleAggregatedListRequest 向所有MCP请求列表，合并为一个带前缀的响应
+func (s *Session) handleAggregatedListRequest(xl xlog.Logger, request mcp.JSONRPCRequest) error {
+	mcpNames := s.getMcpNames()
+	namespace := s.GetNamespace()
+	xl.Debugf("Handling aggregated %s request for %d MCPs", request.Method, len(mcpNames))
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), aggregateListTimeout)
+		defer cancel()
+
+		var result interface{}
+		switch mcp.MCPMethod(request.Method) {
+		case mcp.MethodResourcesList:
+			forgetAll(namespace, nameKindResource, mcpNames)
+			result = &mcp.ListResourcesResult{
+				Resources: collectFromMcps(ctx, s, xl, mcpNames, listResources,
+					func(mcpName McpName, resource mcp.Resource) (mcp.Resource, error) {
+						resource.URI = namespace.Register(nameKindResource, mcpName, resource.URI)
+						return resource, nil
+					}),
+			}
+		case mcp.MethodResourcesTemplatesList:
+			// 模板展开后的URI按前缀路由，不登记路由表
+			result = &mcp.ListResourceTemplatesResult{
+				ResourceTemplates: collectFromMcps(ctx, s, xl, mcpNames, listResourceTemplates,
+					func(mcpName McpName, template mcp.ResourceTemplate) (mcp.ResourceTemplate, error) {
+						if template.URITemplate == nil {
+							return template, nil
+						}
+						uriTemplate, err := namespacedURITemplate(namespace, mcpName, template.URITemplate)
+						if err != nil {
+							return template, fmt.Errorf("invalid uri template %s: %w", template.URITemplate.Raw(), err)
+						}
+						template.URITemplate = uriTemplate
+						return template, nil
+					}),
+			}
+		case mcp.MethodPromptsList:
+			forgetAll(namespace, nameKindPrompt, mcpNames)
+			result = &mcp.ListPromptsResult{
+				Prompts: collectFromMcps(ctx, s, xl, mcpNames, listPrompts,
+					func(mcpName McpName, prompt mcp.Prompt) (mcp.Prompt, error) {
+						prompt.Name = namespace.Register(nameKindPrompt, mcpName, prompt.Name)
+						return prompt, nil
+					}),
+			}
+		}
+		s.sendSuccessResponse(request.ID, result)
+	}()
+	return nil
+}
+
+// forgetAll 重新聚合前清除这些MCP登记的路由
+func forgetAll(namespace *Namespace, kind nameKind, mcpNames []McpName) {
+	for _, mcpName := range mcpNames {
+		namespace.Forget(kind, mcpName)
+	}
+}
+
+// handleBroadcastRequest 向所有支持该方法的MCP发送请求，只返回一个响应: 任一MCP失败时返回错误，否则返回第一个结果
+func (s *Session) handleBroadcastRequest(xl xlog.Logger, request mcp.JSONRPCRequest, content json.RawMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	ctx, done := s.trackRequest(ctx, request.ID)
+	defer done()
+
+	var result interface{}
+	var firstErr error
+	for _, mcpName := range s.getMcpNames() {
+		if !s.supportsMethod(mcpName, request.Method) {
+			continue
+		}
+		s.mu.RLock()
+		mCli, ok := s.mcpClients[mcpName]
+		s.mu.RUnlock()
+		if !ok {
+			continue
+		}
+
+		res, err := s.handleMCPMethod(ctx, xlog.WithChildName(mcpName, xl), mCli, mcpName, request.Method, content)
+		if err != nil {
+			xl.Errorf("failed to call MCP method %s on %s: %v", request.Method, mcpName, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", mcpName, err)
+			}
+			continue
+		}
+		if result == nil {
+			result = res
+		}
+	}
+
+	// 通知不需要响应，被客户端取消的请求不再响应
+	if request.ID.IsNil() || isCancelledByClient(ctx) {
+		return nil
+	}
+	if firstErr != nil {
+		s.sendErrorResponse(request.ID, firstErr)
+		return nil
+	}
+	if result == nil {
+		result = &mcp.EmptyResult{}
+	}
+	s.sendSuccessResponse(request.ID, result)
+	return nil
+}
+
+// collectFromMcps 并发调用所有MCP的列表方法，再按MCP名称顺序依次改写名称并合并
+// 改写名称（登记路由）按固定顺序进行，保证名称冲突时的对外名称稳定；单个MCP失败不影响其他结果
+func collectFromMcps[T any](ctx context.Context, s *Session, xl xlog.Logger, mcpNames []McpName,
+	list func(ctx context.Context, mCli client.MCPClient) ([]T, error),
+	rename func(mcpName McpName, item T) (T, error)) []T {
+	results := make([][]T, len(mcpNames))
+
+	var wg sync.WaitGroup
+	for i, mcpName := range mcpNames {
+		s.mu.RLock()
+		mCli, ok := s.mcpClients[mcpName]
+		s.mu.RUnlock()
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, mcpName McpName, mCli client.MCPClient) {
+			defer wg.Done()
+			items, err := list(ctx, mCli)
+			if err != nil {
+				xl.Errorf("Failed to list from MCP %s: %v", mcpName, err)
+				return
+			}
+			results[i] = items
+		}(i, mcpName, mCli)
+	}
+	wg.Wait()
+
+	merged := make([]T, 0)
+	for i, items := range results {
+		for _, item := range items {
+			renamed, err := rename(mcpNames[i], item)
+			if err != nil {
+				xl.Errorf("Skip item from MCP %s: %v", mcpNames[i], err)
+				continue
+			}
+			merged = append(merged, renamed)
+		}
+	}
+	return merged
+}
+
+// listResources 获取单个MCP的全部资源（处理分页）
+func listResources(ctx context.Context, mCli client.MCPClient) ([]mcp.Resource, error) {
+	var resources []mcp.Resource
+	request := mcp.ListResourcesRequest{}
+	for {
+		result, err := mCli.ListResources(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, result.Resources...)
+		if result.NextCursor == "" {
+			return resources, nil
+		}
+		request.Params.Cursor = result.NextCursor
+	}
+}
+
+// listResourceTemplates 获取单个MCP的全部资源模板（处理分页）
+func listResourceTemplates(ctx context.Context, mCli client.MCPClient) ([]mcp.ResourceTemplate, error) {
+	var templates []mcp.ResourceTemplate
+	request := mcp.ListResourceTemplatesRequest{}
+	for {
+		result, err := mCli.ListResourceTemplates(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, result.ResourceTemplates...)
+		if result.NextCursor == "" {
+			return templates, nil
+		}
+		request.Params.Cursor = result.NextCursor
+	}
+}
+
+// listPrompts 获取单个MCP的全部提示词（处理分页）
+func listPrompts(ctx context.Context, mCli client.MCPClient) ([]mcp.Prompt, error) {
+	var prompts []mcp.Prompt
+	request := mcp.ListPromptsRequest{}
+	for {
+		result, err := mCli.ListPrompts(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		prompts = append(prompts, result.Prompts...)
+		if result.NextCursor == "" {
+			return prompts, nil
+		}
+		request.Params.Cursor = result.NextCursor
+	}
+}
+
+// namespacedURITemplate 为资源模板添加MCP前缀
+func namespacedURITemplate(namespace *Namespace, mcpName McpName, template *mcp.URITemplate) (*mcp.URITemplate, error) {
+	raw, err := json.Marshal(namespace.Prefix(mcpName, template.Raw()))
+	if err != nil {
+		return nil, err
+	}
+	result := &mcp.URITemplate{}
+	if err := result.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// namespacedResourceContents 将读取到的资源内容URI改写为 resources/list 中登记的对外名称
+func namespacedResourceContents(namespace *Namespace, mcpName McpName, result *mcp.ReadResourceResult) {
+	for i, content := range result.Contents {
+		switch c := content.(type) {
+		case mcp.TextResourceContents:
+			c.URI = namespace.Visible(nameKindResource, mcpName, c.URI)
+			result.Contents[i] = c
+		case mcp.BlobResourceContents:
+			c.URI = namespace.Visible(nameKindResource, mcpName, c.URI)
+			result.Contents[i] = c
+		}
+	}
+}
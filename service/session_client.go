@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// methodNotificationInitialized 客户端完成初始化的通知，mcp-go 没有定义对应的方法常量
+const methodNotificationInitialized = "notifications/initialized"
+
+// methodNotificationCancelled 客户端取消请求的通知
+const methodNotificationCancelled = "notifications/cancelled"
+
+// errRequestCancelled 客户端通过 notifications/cancelled 取消了请求
+var errRequestCancelled = errors.New("request cancelled by client")
+
+// handleClientMessage 处理客户端发来的通知和对服务端请求的响应，这些消息不需要返回结果
+func (s *Session) handleClientMessage(xl xlog.Logger, request mcp.JSONRPCRequest, content json.RawMessage) error {
+	if request.Method == "" {
+		// 网关不会向客户端发起请求，客户端的响应没有对应的请求
+		xl.Warnf("Ignore client response %s: gateway has no pending request to client", request.ID.String())
+		return nil
+	}
+
+	switch request.Method {
+	case methodNotificationInitialized:
+		// 各MCP在加入会话时已经由网关完成初始化
+		xl.Infof("Client initialized session %s", s.Id)
+	case methodNotificationCancelled:
+		var notification mcp.CancelledNotification
+		if err := json.Unmarshal(content, &notification); err != nil {
+			return fmt.Errorf("failed to unmarshal cancelled notification: %w", err)
+		}
+		if s.cancelRequest(notification.Params.RequestId) {
+			xl.Infof("Client cancelled request %s: %s", notification.Params.RequestId.String(), notification.Params.Reason)
+		} else {
+			xl.Debugf("Cancelled request %s is not in progress", notification.Params.RequestId.String())
+		}
+	default:
+		xl.Debugf("Ignore client notification %s", request.Method)
+	}
+	return nil
+}
+
+// trackRequest 登记正在处理的请求，客户端取消请求时返回的上下文被取消，请求处理完成后调用返回的函数
+func (s *Session) trackRequest(ctx context.Context, id mcp.RequestId) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	if id.IsNil() {
+		return ctx, func() { cancel(nil) }
+	}
+	key := id.String()
+	s.mu.Lock()
+	s.inflightRequests[key] = cancel
+	s.mu.Unlock()
+	return ctx, func() {
+		s.mu.Lock()
+		delete(s.inflightRequests, key)
+		s.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancelRequest 取消正在处理的请求，请求不存在或已完成时返回 false
+func (s *Session) cancelRequest(id mcp.RequestId) bool {
+	s.mu.Lock()
+	cancel, ok := s.inflightRequests[id.String()]
+	delete(s.inflightRequests, id.String())
+	s.mu.Unlock()
+	if ok {
+		cancel(errRequestCancelled)
+	}
+	return ok
+}
+
+// isCancelledByClient 判断请求是否被客户端取消，被取消的请求不再返回响应
+func isCancelledByClient(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errRequestCancelled)
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestSession_CancelRequest(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	session := NewSession("cancel")
+	defer session.Close()
+
+	ctx, done := session.trackRequest(context.Background(), mcp.NewRequestId(int64(7)))
+	defer done()
+	if err := session.SendMessage(xl, json.RawMessage(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":7,"reason":"user"}}`)); err != nil {
+		t.Fatalf("SendMessage() failed: %v", err)
+	}
+	if !isCancelledByClient(ctx) {
+		t.Error("Expected request to be cancelled by client")
+	}
+
+	// 已完成的请求不能再被取消
+	_, finish := session.trackRequest(context.Background(), mcp.NewRequestId(int64(8)))
+	finish()
+	if session.cancelRequest(mcp.NewRequestId(int64(8))) {
+		t.Error("Expected finished request not to be cancelled")
+	}
+}
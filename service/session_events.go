@@ -0,0 +1,55 @@
+package service
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// sessionEventLogSize 每个会话保留的最近事件数，重连的客户端最多可以重放这么多事件
+	sessionEventLogSize = 1000
+	// sessionEventLogBytes 每个会话保留的事件数据的总字节数，避免较大的工具调用结果占用过多内存
+	sessionEventLogBytes = 4 << 20
+	// sessionResumeWindow SSE 客户端断开后等待重连的时间，超时未重连时清理会话
+	sessionResumeWindow = 30 * time.Second
+)
+
+// appendEventLocked 为事件分配序号并记录到事件日志，超出事件数或字节数上限时丢弃最早的事件，调用方需持有锁
+// 单个事件超过字节数上限时不保留，重连的客户端会得到事件不完整的提示
+func (s *Session) appendEventLocked(event SessionMsg) SessionMsg {
+	s.lastEventId++
+	event.Id = s.lastEventId
+	s.eventLog = append(s.eventLog, event)
+	s.eventLogBytes += len(event.Data)
+	for len(s.eventLog) > 0 && (len(s.eventLog) > sessionEventLogSize || s.eventLogBytes > sessionEventLogBytes) {
+		s.eventLogBytes -= len(s.eventLog[0].Data)
+		s.eventLog[0] = SessionMsg{}
+		s.eventLog = s.eventLog[1:]
+	}
+	return event
+}
+
+// eventsAfterLocked 返回序号大于 lastEventId 的事件，complete 为 false 表示其中一部分事件已被丢弃，调用方需持有锁
+func (s *Session) eventsAfterLocked(lastEventId uint64) (events []SessionMsg, complete bool) {
+	if lastEventId > s.lastEventId {
+		return nil, false
+	}
+	i := sort.Search(len(s.eventLog), func(i int) bool { return s.eventLog[i].Id > lastEventId })
+	events = make([]SessionMsg, len(s.eventLog)-i)
+	copy(events, s.eventLog[i:])
+	complete = lastEventId == s.lastEventId || (len(s.eventLog) > 0 && s.eventLog[0].Id <= lastEventId+1)
+	return events, complete
+}
+
+// ResumeEventChan 获取事件通道，同时返回序号大于 lastEventId 的事件供断线重连的客户端重放
+// 重放的事件和通道中的事件之间不会遗漏或重复，complete 为 false 表示部分错过的事件已超出保留范围
+func (s *Session) ResumeEventChan(lastEventId uint64) (replay []SessionMsg, complete bool, eventChan <-chan SessionMsg, closer func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	replay, complete = s.eventsAfterLocked(lastEventId)
+	curChan := s.addEventChanLocked()
+	closer = func() {
+		s.removeEventChan(curChan)
+	}
+	return replay, complete, curChan, closer
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSession_ResumeEvents(t *testing.T) {
+	session := NewSession("resume")
+	defer session.Close()
+
+	// 没有客户端连接时事件保留在事件日志中
+	for i := 1; i <= 3; i++ {
+		session.SendEvent(SessionMsg{Event: "message", Data: fmt.Sprintf(`{"n":%d}`, i)})
+	}
+	replay, complete, eventChan, closer := session.ResumeEventChan(1)
+	defer closer()
+	if !complete || len(replay) != 2 || replay[0].Id != 2 || replay[1].Id != 3 || replay[1].Data != `{"n":3}` {
+		t.Fatalf("Unexpected replay after event 1: %+v, complete: %v", replay, complete)
+	}
+
+	// 重放之后的事件从通道收到，序号连续
+	session.SendEvent(SessionMsg{Event: "message", Data: `{"n":4}`})
+	select {
+	case event := <-eventChan:
+		if event.Id != 4 {
+			t.Errorf("Expected event 4 after replay, got %d", event.Id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for event after replay")
+	}
+
+	if replay, complete, _, closer := session.ResumeEventChan(4); !complete || len(replay) != 0 {
+		t.Errorf("Expected nothing to replay for up-to-date client, got %+v", replay)
+	} else {
+		closer()
+	}
+}
+
+func TestSession_EventLogBounded(t *testing.T) {
+	session := NewSession("bounded")
+	defer session.Close()
+
+	for i := 0; i < sessionEventLogSize+5; i++ {
+		session.SendEvent(SessionMsg{Event: "message", Data: fmt.Sprintf(`{"n":%d}`, i)})
+	}
+	replay, complete, _, closer := session.ResumeEventChan(1)
+	defer closer()
+	if complete {
+		t.Error("Expected replay to report dropped events")
+	}
+	if len(replay) != sessionEventLogSize || replay[0].Id != 6 {
+		t.Errorf("Expected last %d events starting at 6, got %d starting at %d", sessionEventLogSize, len(replay), replay[0].Id)
+	}
+}
+
+func TestSession_EventLogByteBudget(t *testing.T) {
+	session := NewSession("budget")
+	defer session.Close()
+
+	// 按字节数丢弃最早的事件
+	data := strings.Repeat("x", sessionEventLogBytes/4-1)
+	for i := 0; i < 5; i++ {
+		session.SendEvent(SessionMsg{Event: "message", Data: fmt.Sprint(i) + data})
+	}
+	replay, complete, _, closer := session.ResumeEventChan(0)
+	closer()
+	if complete || len(replay) != 4 || replay[0].Id != 2 {
+		t.Errorf("Expected the last 4 events starting at 2, got %d events, complete: %v", len(replay), complete)
+	}
+
+	// 超过上限的单个事件不保留
+	session.SendEvent(SessionMsg{Event: "message", Data: strings.Repeat("x", sessionEventLogBytes+1)})
+	replay, complete, _, closer = session.ResumeEventChan(5)
+	closer()
+	if complete || len(replay) != 0 {
+		t.Errorf("Expected oversized event to be dropped, got %d events, complete: %v", len(replay), complete)
+	}
+}
+
+func TestSession_SSEDisconnectKeepsSession(t *testing.T) {
+	session := NewSession("disconnect")
+	defer session.Close()
+	cleaned := make(chan string, 1)
+	session.SetCleanupCallback(func(sessionId string) { cleaned <- sessionId })
+
+	// 客户端断开后不立即清理，等待重连
+	_, closer := session.GetEventChanWithCloser()
+	closer()
+	select {
+	case <-cleaned:
+		t.Fatal("Expected disconnected SSE session to wait for reconnection")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	_, closer = session.GetEventChanWithCloser()
+	defer closer()
+	session.mu.RLock()
+	pending := session.resumeTimer != nil
+	session.mu.RUnlock()
+	if pending {
+		t.Error("Expected reconnection to cancel pending cleanup")
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 网关作为聚合会话的 MCP 服务器时使用的 serverInfo
+const (
+	gatewayServerName    = "mcp-gateway"
+	gatewayServerVersion = "1.0.0"
+)
+
+// handleInitializeRequest 网关作为聚合会话的 MCP 服务器响应 initialize，返回一个合并了所有MCP的结果
+func (s *Session) handleInitializeRequest(xl xlog.Logger, request mcp.JSONRPCRequest, content json.RawMessage) error {
+	var initRequest mcp.InitializeRequest
+	if err := json.Unmarshal(content, &initRequest); err != nil {
+		err = fmt.Errorf("failed to unmarshal initialize request: %w", err)
+		s.sendErrorResponse(request.ID, err)
+		return err
+	}
+	result := s.initializeResult(xl, initRequest.Params.ProtocolVersion)
+	xl.Infof("Initialize session %s with protocol version %s", s.Id, result.ProtocolVersion)
+	s.sendSuccessResponse(request.ID, result)
+	return nil
+}
+
+// initializeResult 使用网关自己的 serverInfo，与客户端和各个MCP协商协议版本，合并各MCP的能力和说明
+func (s *Session) initializeResult(xl xlog.Logger, requestedVersion string) *mcp.InitializeResult {
+	s.mu.RLock()
+	upstreams := make(map[McpName]*mcp.InitializeResult, len(s.mcpinitializeResults))
+	for mcpName, result := range s.mcpinitializeResults {
+		if result != nil {
+			upstreams[mcpName] = result
+		}
+	}
+	merge := s.mergeInstructions
+	s.mu.RUnlock()
+
+	result := &mcp.InitializeResult{
+		ProtocolVersion: negotiateProtocolVersion(xl, requestedVersion, upstreams),
+		Capabilities:    mergeCapabilities(upstreams),
+		ServerInfo: mcp.Implementation{
+			Name:    gatewayServerName,
+			Version: gatewayServerVersion,
+		},
+	}
+	if merge {
+		result.Instructions = mergeInstructions(s.GetNamespace(), upstreams)
+	}
+	return result
+}
+
+// negotiateProtocolVersion 协商对客户端使用的协议版本: 使用客户端请求的版本，网关不支持时使用最新版本；
+// MCP使用更早的版本时降到所有MCP都支持的版本。协议版本是日期，按字符串比较即可确定先后
+func negotiateProtocolVersion(xl xlog.Logger, requested string, upstreams map[McpName]*mcp.InitializeResult) string {
+	version := mcp.LATEST_PROTOCOL_VERSION
+	if slices.Contains(mcp.ValidProtocolVersions, requested) {
+		version = requested
+	} else {
+		xl.Warnf("Unsupported protocol version %q requested, using %s", requested, version)
+	}
+	for _, mcpName := range sortedKeys(upstreams) {
+		upstream := upstreams[mcpName].ProtocolVersion
+		if !slices.Contains(mcp.ValidProtocolVersions, upstream) {
+			xl.Warnf("MCP %s uses unknown protocol version %q, ignored in negotiation", mcpName, upstream)
+			continue
+		}
+		if upstream < version {
+			xl.Infof("MCP %s uses protocol version %s, downgrading from %s", mcpName, upstream, version)
+			version = upstream
+		}
+	}
+	return version
+}
+
+// mergeCapabilities 合并各MCP的能力，任一MCP支持即视为支持
+// 服务加入或离开会话时网关会发送 tools/list_changed，因此始终声明 tools.listChanged；
+// 网关不转发 resources/subscribe，不声明 resources.subscribe
+func mergeCapabilities(upstreams map[McpName]*mcp.InitializeResult) mcp.ServerCapabilities {
+	capabilities := mcp.ServerCapabilities{
+		Tools: &struct {
+			ListChanged bool `json:"listChanged,omitempty"`
+		}{ListChanged: true},
+	}
+	for _, mcpName := range sortedKeys(upstreams) {
+		upstream := upstreams[mcpName].Capabilities
+		if upstream.Logging != nil {
+			capabilities.Logging = &struct{}{}
+		}
+		if upstream.Prompts != nil {
+			if capabilities.Prompts == nil {
+				prompts := *upstream.Prompts
+				capabilities.Prompts = &prompts
+			} else if upstream.Prompts.ListChanged {
+				capabilities.Prompts.ListChanged = true
+			}
+		}
+		if upstream.Resources != nil {
+			if capabilities.Resources == nil {
+				resources := *upstream.Resources
+				resources.Subscribe = false
+				capabilities.Resources = &resources
+			} else if upstream.Resources.ListChanged {
+				capabilities.Resources.ListChanged = true
+			}
+		}
+		// 同名的实验性能力以按名称排序的第一个MCP为准
+		for name, value := range upstream.Experimental {
+			if capabilities.Experimental == nil {
+				capabilities.Experimental = make(map[string]any)
+			}
+			if _, ok := capabilities.Experimental[name]; !ok {
+				capabilities.Experimental[name] = value
+			}
+		}
+	}
+	return capabilities
+}
+
+// mergeInstructions 按MCP名称排序，将各MCP的 instructions 合并为分段的说明，每段注明该MCP对外名称的前缀
+func mergeInstructions(namespace *Namespace, upstreams map[McpName]*mcp.InitializeResult) string {
+	var sections []string
+	for _, mcpName := range sortedKeys(upstreams) {
+		instructions := strings.TrimSpace(upstreams[mcpName].Instructions)
+		if instructions == "" {
+			continue
+		}
+		sections = append(sections, fmt.Sprintf("## %s\nTools, prompts and resources from this server are prefixed with %q.\n\n%s",
+			mcpName, namespace.Prefix(mcpName, ""), instructions))
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// supportsMethod 根据MCP初始化时声明的能力判断是否支持该方法，合并后的能力包含任一MCP支持的方法，
+// 例如 logging/setLevel 只发送到声明了 logging 的MCP
+func (s *Session) supportsMethod(mcpName McpName, method string) bool {
+	if mcp.MCPMethod(method) != mcp.MethodSetLogLevel {
+		return true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := s.mcpinitializeResults[mcpName]
+	return result != nil && result.Capabilities.Logging != nil
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestSession_InitializeResult(t *testing.T) {
+	session := NewSession("init")
+	defer session.Close()
+	session.SetMergeInstructions(true)
+
+	var git, fs mcp.InitializeResult
+	if err := json.Unmarshal([]byte(`{
+		"protocolVersion": "2025-03-26",
+		"capabilities": {"tools": {}, "resources": {"subscribe": true}, "logging": {}},
+		"serverInfo": {"name": "git-server", "version": "0.1.0"},
+		"instructions": "Use git_status before committing."
+	}`), &git); err != nil {
+		t.Fatalf("Failed to unmarshal result: %v", err)
+	}
+	if err := json.Unmarshal([]byte(`{
+		"protocolVersion": "2024-11-05",
+		"capabilities": {"prompts": {"listChanged": true}, "resources": {"listChanged": true}, "experimental": {"fs": {}}},
+		"serverInfo": {"name": "fs-server", "version": "0.2.0"}
+	}`), &fs); err != nil {
+		t.Fatalf("Failed to unmarshal result: %v", err)
+	}
+	session.mcpinitializeResults["git"] = &git
+	session.mcpinitializeResults["fs"] = &fs
+
+	eventChan, closer := session.GetEventChanWithCloser()
+	defer closer()
+	if err := session.SendMessage(xlog.NewLogger("test"), json.RawMessage(`{
+		"jsonrpc": "2.0", "id": 1, "method": "initialize",
+		"params": {"protocolVersion": "2025-03-26", "capabilities": {}, "clientInfo": {"name": "client", "version": "1.0.0"}}
+	}`)); err != nil {
+		t.Fatalf("SendMessage() failed: %v", err)
+	}
+
+	var response struct {
+		ID     int                  `json:"id"`
+		Result mcp.InitializeResult `json:"result"`
+	}
+	select {
+	case event := <-eventChan:
+		if err := json.Unmarshal([]byte(event.Data), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response %s: %v", event.Data, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for initialize response")
+	}
+
+	// 一个请求只返回一个结果，使用网关自己的 serverInfo
+	result := response.Result
+	if response.ID != 1 || result.ServerInfo.Name != gatewayServerName {
+		t.Errorf("Unexpected initialize response: %+v", response)
+	}
+	// 有MCP只支持更早的协议版本时降级
+	if result.ProtocolVersion != "2024-11-05" {
+		t.Errorf("Expected negotiated protocol version 2024-11-05, got %s", result.ProtocolVersion)
+	}
+
+	caps := result.Capabilities
+	if caps.Tools == nil || !caps.Tools.ListChanged {
+		t.Error("Expected tools.listChanged to be declared")
+	}
+	if caps.Logging == nil || caps.Prompts == nil || !caps.Prompts.ListChanged {
+		t.Errorf("Expected union of logging and prompts capabilities, got %+v", caps)
+	}
+	if caps.Resources == nil || !caps.Resources.ListChanged || caps.Resources.Subscribe {
+		t.Errorf("Expected resources.listChanged without subscribe, got %+v", caps.Resources)
+	}
+	if _, ok := caps.Experimental["fs"]; !ok {
+		t.Errorf("Expected experimental capabilities to be merged, got %v", caps.Experimental)
+	}
+
+	// 只合并有 instructions 的MCP，并注明对外名称的前缀
+	if !strings.HasPrefix(result.Instructions, "## git\n") || !strings.Contains(result.Instructions, `"git_"`) ||
+		!strings.Contains(result.Instructions, "Use git_status before committing.") || strings.Contains(result.Instructions, "## fs") {
+		t.Errorf("Unexpected merged instructions: %q", result.Instructions)
+	}
+}
+
+func TestNegotiateProtocolVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested string
+		upstreams []string
+		expected  string
+	}{
+		{"requested version", "2024-11-05", []string{"2025-03-26"}, "2024-11-05"},
+		{"unsupported request", "1999-01-01", nil, mcp.LATEST_PROTOCOL_VERSION},
+		{"older upstream", "2025-03-26", []string{"2025-03-26", "2024-11-05"}, "2024-11-05"},
+		{"unknown upstream", "2025-03-26", []string{"2000-01-01"}, "2025-03-26"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreams := make(map[McpName]*mcp.InitializeResult)
+			for i, version := range tt.upstreams {
+				upstreams[string(rune('a'+i))] = &mcp.InitializeResult{ProtocolVersion: version}
+			}
+			if version := negotiateProtocolVersion(xlog.NewLogger("test"), tt.requested, upstreams); version != tt.expected {
+				t.Errorf("negotiateProtocolVersion() = %s, want %s", version, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// AttachState 会话对单个MCP服务的订阅状态
+type AttachState string
+
+const (
+	AttachStateAttached AttachState = "attached" // 已订阅
+	AttachStatePending  AttachState = "pending"  // 服务未运行，服务运行后自动加入
+	AttachStateFailed   AttachState = "failed"   // 订阅失败，在后台重试
+)
+
+// McpAttachment 会话对单个MCP服务的订阅情况
+type McpAttachment struct {
+	State     AttachState `json:"state"`
+	Error     string      `json:"error,omitempty"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// Attachments 返回会话对工作空间中各个MCP服务的订阅情况
+func (s *Session) Attachments() map[McpName]McpAttachment {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	attachments := make(map[McpName]McpAttachment, len(s.attachments))
+	for mcpName, attachment := range s.attachments {
+		attachments[mcpName] = attachment
+	}
+	return attachments
+}
+
+// IsDegraded 是否有MCP服务尚未加入会话
+func (s *Session) IsDegraded() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, attachment := range s.attachments {
+		if attachment.State != AttachStateAttached {
+			return true
+		}
+	}
+	return false
+}
+
+// startGen 返回订阅MCP时服务的启动序号，未订阅时返回 0
+func (s *Session) startGen(mcpName McpName) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.mcpStartGens[mcpName]
+}
+
+// markAttached 记录MCP已订阅及订阅时服务的启动序号
+func (s *Session) markAttached(mcpName McpName, gen uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mcpStartGens[mcpName] = gen
+	s.attachments[mcpName] = McpAttachment{State: AttachStateAttached, UpdatedAt: time.Now()}
+}
+
+// markUnattached 记录MCP未能加入会话的原因，状态和原因不变时保留原来的更新时间
+func (s *Session) markUnattached(mcpName McpName, state AttachState, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur, ok := s.attachments[mcpName]; ok && cur.State == state && cur.Error == reason {
+		return
+	}
+	s.attachments[mcpName] = McpAttachment{State: state, Error: reason, UpdatedAt: time.Now()}
+}
+
+// attachment 返回会话对MCP的订阅情况
+func (s *Session) attachment(mcpName McpName) (McpAttachment, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	attachment, ok := s.attachments[mcpName]
+	return attachment, ok
+}
+
+// detach 关闭并移除MCP客户端，清除该MCP的工具和路由，未订阅时返回 false
+func (s *Session) detach(xl xlog.Logger, mcpName McpName) bool {
+	s.mu.Lock()
+	cli, ok := s.mcpClients[mcpName]
+	delete(s.mcpClients, mcpName)
+	delete(s.mcpinitializeResults, mcpName)
+	delete(s.mcpToolsMap, mcpName)
+	delete(s.mcpStartGens, mcpName)
+	delete(s.attachments, mcpName)
+	namespace := s.namespace
+	s.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	for _, kind := range []nameKind{nameKindTool, nameKindResource, nameKindPrompt} {
+		namespace.Forget(kind, mcpName)
+	}
+	xl.Infof("Detaching MCP client: %s", mcpName)
+	if err := cli.Close(); err != nil {
+		xl.Warnf("Error closing MCP client %s: %v", mcpName, err)
+	}
+	return true
+}
+
+// notifyToolsListChanged 会话订阅的MCP发生变化后通知下游客户端重新获取工具列表
+func (s *Session) notifyToolsListChanged(xl xlog.Logger) {
+	s.toolsListComplete.Store(false)
+
+	notification := mcp.JSONRPCNotification{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		Notification: mcp.Notification{
+			Method: string(mcp.MethodNotificationToolsListChanged),
+		},
+	}
+	data, err := json.Marshal(notification)
+	if err != nil {
+		xl.Errorf("failed to marshal %s notification: %v", notification.Method, err)
+		return
+	}
+	// 连续的变化通知内容相同，不能按重复消息丢弃
+	s.sendEventNoDedup(SessionMsg{
+		Event: "message",
+		Data:  string(data),
+	})
+}
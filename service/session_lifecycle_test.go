@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestSessionManager_FollowServiceLifecycle(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to locate test binary: %v", err)
+	}
+	workspace := NewWorkSpace("default", config.WorkspaceConfig{
+		Servers: make(map[string]config.MCPServerConfig),
+		Bridge:  config.BridgeListenConfig{Network: config.BridgeNetworkUnix, SocketDir: t.TempDir()},
+	}, mockPortMgr)
+	defer workspace.Close(xl)
+	deploy := func(name string) {
+		t.Helper()
+		_, err := workspace.AddMcpService(xl, name, config.MCPServerConfig{
+			Workspace:   "default",
+			Command:     exe,
+			Env:         map[string]string{testStdioServerEnv: "1"},
+			HealthCheck: &config.HealthCheckConfig{Disabled: true},
+			LogConfig:   config.LogConfig{Path: t.TempDir()},
+		})
+		if err != nil {
+			t.Fatalf("AddMcpService(%s) failed: %v", name, err)
+		}
+	}
+
+	deploy("first")
+	session, err := workspace.sessionMgr.CreateSession(xl, "")
+	if err != nil {
+		t.Fatalf("CreateSession() failed: %v", err)
+	}
+	events, closeEvents := session.GetEventChanWithCloser()
+	defer closeEvents()
+	firstPid := callSessionPid(t, session, "first")
+
+	// 新部署的服务加入已有会话
+	deploy("second")
+	waitToolsListChanged(t, events)
+	if callSessionPid(t, session, "second") == "" {
+		t.Fatal("Expected session to attach newly deployed service")
+	}
+
+	// 重启的服务重新订阅新的子进程
+	if err := workspace.RestartMcpService(xl, "first"); err != nil {
+		t.Fatalf("RestartMcpService() failed: %v", err)
+	}
+	waitToolsListChanged(t, events)
+	if pid := callSessionPid(t, session, "first"); pid == firstPid {
+		t.Errorf("Expected session to reconnect to restarted process, still using pid %s", pid)
+	}
+
+	// 移除的服务从会话中取消订阅
+	if err := workspace.RemoveMcpService(xl, "second"); err != nil {
+		t.Fatalf("RemoveMcpService() failed: %v", err)
+	}
+	waitToolsListChanged(t, events)
+	if names := session.getMcpNames(); len(names) != 1 || names[0] != "first" {
+		t.Errorf("Expected only first service to remain, got %v", names)
+	}
+}
+
+func TestSessionManager_DegradedSession(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to locate test binary: %v", err)
+	}
+	workspace := NewWorkSpace("default", config.WorkspaceConfig{
+		Servers: make(map[string]config.MCPServerConfig),
+		Bridge:  config.BridgeListenConfig{Network: config.BridgeNetworkUnix, SocketDir: t.TempDir()},
+	}, mockPortMgr)
+	defer workspace.Close(xl)
+	for _, name := range []string{"good", "late"} {
+		if _, err := workspace.AddMcpService(xl, name, config.MCPServerConfig{
+			Workspace:   "default",
+			Command:     exe,
+			Env:         map[string]string{testStdioServerEnv: "1"},
+			HealthCheck: &config.HealthCheckConfig{Disabled: true},
+			LogConfig:   config.LogConfig{Path: t.TempDir()},
+		}); err != nil {
+			t.Fatalf("AddMcpService(%s) failed: %v", name, err)
+		}
+	}
+	// 远程服务地址不可用，订阅失败
+	if _, err := workspace.AddMcpService(xl, "broken", config.MCPServerConfig{
+		Workspace:   "default",
+		URL:         "http://127.0.0.1:1/sse",
+		Type:        config.UpstreamSSE,
+		HealthCheck: &config.HealthCheckConfig{Disabled: true},
+		LogConfig:   config.LogConfig{Path: t.TempDir()},
+	}); err != nil {
+		t.Fatalf("AddMcpService(broken) failed: %v", err)
+	}
+	if err := workspace.StopMcpService(xl, "late"); err != nil {
+		t.Fatalf("StopMcpService() failed: %v", err)
+	}
+
+	// 未配置时为 strict
+	if _, err := workspace.sessionMgr.CreateSession(xl, ""); err == nil {
+		t.Fatal("Expected strict policy to reject session with unavailable service")
+	}
+
+	workspace.cfg.SessionPolicy = config.SessionPolicyBestEffort
+	session, err := workspace.sessionMgr.CreateSession(xl, "")
+	if err != nil {
+		t.Fatalf("CreateSession() failed: %v", err)
+	}
+	events, closeEvents := session.GetEventChanWithCloser()
+	defer closeEvents()
+	attachments := session.Attachments()
+	if attachments["good"].State != AttachStateAttached || attachments["broken"].State != AttachStateFailed ||
+		attachments["late"].State != AttachStatePending || !session.IsDegraded() {
+		t.Fatalf("Unexpected attachments: %+v", attachments)
+	}
+	callSessionPid(t, session, "good")
+
+	// 未运行的服务启动后在后台加入会话
+	late, _ := workspace.getMcpService("late")
+	if err := late.Start(xl); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	waitToolsListChanged(t, events)
+	if state := session.Attachments()["late"].State; state != AttachStateAttached {
+		t.Errorf("Expected late service to join session, got %s", state)
+	}
+	callSessionPid(t, session, "late")
+}
+
+// callSessionPid 通过会话订阅的客户端调用 pid 工具
+func callSessionPid(t *testing.T, session *Session, mcpName McpName) string {
+	t.Helper()
+	session.mu.RLock()
+	cli, ok := session.mcpClients[mcpName]
+	session.mu.RUnlock()
+	if !ok {
+		t.Fatalf("Expected session to subscribe %s", mcpName)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := cli.CallTool(ctx, mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "pid"}})
+	if err != nil {
+		t.Fatalf("Failed to call pid tool of %s: %v", mcpName, err)
+	}
+	return result.Content[0].(mcp.TextContent).Text
+}
+
+// waitToolsListChanged 等待会话向下游发送 tools/list_changed 通知
+func waitToolsListChanged(t *testing.T, events <-chan SessionMsg) {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case msg := <-events:
+			if strings.Contains(msg.Data, string(mcp.MethodNotificationToolsListChanged)) {
+				return
+			}
+		case <-timeout:
+			t.Fatal("Timeout waiting for tools/list_changed notification")
+		}
+	}
+}
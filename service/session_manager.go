@@ -1,11 +1,22 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
 	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+	"github.com/mark3labs/mcp-go/client/transport"
+)
+
+const (
+	// sessionStartWait 创建会话时等待正在启动的服务的最长时间
+	sessionStartWait = 60 * time.Second
+	// attachRetryInterval 服务运行中但会话订阅失败时重试的间隔
+	attachRetryInterval = 30 * time.Second
 )
 
 type SessionManager struct {
@@ -13,10 +24,28 @@ type SessionManager struct {
 	sessions      map[string]*Session
 	sessionsMutex sync.RWMutex
 	curWorkspace  *WorkSpace
+
+	// 监听工作空间中服务的状态变化，调用返回的函数停止监听
+	watchers      map[McpName]func()
+	watchersMutex sync.Mutex
+
+	// 会话的回收和数量限制，limits 和 creating 由 sessionsMutex 保护
+	limits     config.SessionLimits
+	creating   int // 正在创建的会话数，计入会话数上限
+	counters   sessionCounters
+	reaperOnce sync.Once
+	closeOnce  sync.Once
+	stopReaper chan struct{}
 }
 
 func NewSessionManager(curWorkspace *WorkSpace) *SessionManager {
-	return &SessionManager{curWorkspace: curWorkspace, sessions: make(map[string]*Session)}
+	return &SessionManager{
+		curWorkspace: curWorkspace,
+		sessions:     make(map[string]*Session),
+		watchers:     make(map[McpName]func()),
+		limits:       curWorkspace.cfg.Sessions,
+		stopReaper:   make(chan struct{}),
+	}
 }
 
 // GetSession returns the session with the given id.
@@ -31,52 +60,219 @@ func (m *SessionManager) GetSession(_ xlog.Logger, sessionId string) (*Session,
 }
 
 // CreateSession creates a new session.
-func (m *SessionManager) CreateSession(xl xlog.Logger) (*Session, error) {
+// apiKey 为创建会话使用的 API Key，记录在会话上用于统计每个 API Key 的会话数；工作空间的会话数达到上限时返回 errs.ErrSessionLimitExceeded
+func (m *SessionManager) CreateSession(xl xlog.Logger, apiKey string) (*Session, error) {
+	release, err := m.reserveSession()
+	if err != nil {
+		xl.Warnf("reject session: %v", err)
+		return nil, err
+	}
+	defer release()
+	m.startReaper()
+
 	session := NewSession(uuid.New().String())
+	session.SetApiKey(apiKey)
 	if m.existsSession(session.Id) {
 		xl.Errorf("session %s already exists", session.Id)
 		return nil, fmt.Errorf("session %s already exists", session.Id)
 	}
 
+	// 使用工作空间配置的命名规则
+	session.SetNamespace(NewNamespace(m.curWorkspace.cfg.Namespace))
+	session.SetMergeInstructions(m.curWorkspace.cfg.MergeInstructions)
+
 	// 设置清理回调
 	session.SetCleanupCallback(func(sessionId string) {
-		xl.Infof("Auto-cleaning inactive session: %s", sessionId)
-		m.CloseSession(xl, sessionId)
+		m.evictSession(xl, sessionId, SessionEvictDisconnected)
 	})
 
+	// 等待正在启动的服务，共用同一个超时时间
+	ctx, cancel := context.WithTimeout(context.Background(), sessionStartWait)
+	defer cancel()
+
+	// best-effort 时订阅失败的服务不影响会话创建，由服务监听在后台重试
+	policy := m.curWorkspace.cfg.GetSessionPolicy()
 	mcpServices := m.curWorkspace.getMcpServices()
 	for _, mcpService := range mcpServices {
-		if mcpService.GetStatus() != Running {
+		if mcpService.GetStatus() == Starting {
+			xl.Infof("waiting for service %s to start", mcpService.Name)
+			mcpService.WaitStarted(ctx)
+		}
+		if status := mcpService.GetStatus(); status != Running {
 			xl.Warnf("service %s is not running", mcpService.Name)
+			session.markUnattached(mcpService.Name, AttachStatePending, fmt.Sprintf("service is %s", status))
 			continue
 		}
-		if err := session.SubscribeSSE(xl, mcpService.Name, mcpService.GetSSEUrl()); err != nil {
-			xl.Errorf("failed to subscribe to SSE for service %s: %v", mcpService.Name, err)
+		session.SetToolFilter(mcpService.Name, mcpService.Config.ToolFilter)
+		if err := subscribeService(xl, session, mcpService); err != nil {
+			xl.Errorf("failed to subscribe service %s: %v", mcpService.Name, err)
+			if policy == config.SessionPolicyBestEffort {
+				session.markUnattached(mcpService.Name, AttachStateFailed, err.Error())
+				continue
+			}
+			// 释放已经为会话启动的子进程
+			session.Close()
 			return nil, fmt.Errorf("failed to subscribe mcpServer[%s]", mcpService.Name)
 		}
 	}
-	if !session.IsReady() {
+	if policy == config.SessionPolicyStrict && !session.IsReady() {
+		session.Close()
 		return nil, fmt.Errorf("create session %s failed", session.Id)
 	}
+	if session.IsDegraded() {
+		xl.Warnf("session %s created without services: %v", session.Id, session.Attachments())
+	}
 	m.sessionsMutex.Lock()
 	m.sessions[session.Id] = session
 	m.sessionsMutex.Unlock()
+	m.counters.recordCreated()
 	return session, nil
 }
 
+// subscribeService 会话订阅服务，per-session 和 pooled 服务订阅为会话分配的子进程，会话关闭时释放
+func subscribeService(xl xlog.Logger, session *Session, mcpService *McpService) error {
+	_, gen := mcpService.startState()
+	if err := subscribeTarget(xl, session, mcpService); err != nil {
+		return err
+	}
+	session.markAttached(mcpService.Name, gen)
+	return nil
+}
+
+func subscribeTarget(xl xlog.Logger, session *Session, mcpService *McpService) error {
+	target := mcpService
+	if mcpService.Config.Isolation.Isolated() {
+		instance, err := mcpService.acquireInstance(xl, session.Id)
+		if err != nil {
+			return err
+		}
+		sessionId := session.Id
+		session.OnClose(func() { mcpService.releaseInstance(xl, sessionId) })
+		target = instance
+	}
+	// 优先使用 SSE，仅暴露 Streamable HTTP 的服务使用对应的客户端
+	if sseUrl := target.GetSSEUrl(); sseUrl != "" {
+		return session.SubscribeSSE(xl, mcpService.Name, sseUrl, transport.WithHeaders(target.GetUpstreamHeaders()), transport.WithHTTPClient(target.HTTPClient()))
+	}
+	return session.SubscribeStreamableHTTP(xl, mcpService.Name, target.GetStreamableHTTPUrl(), transport.WithHTTPHeaders(target.GetUpstreamHeaders()), transport.WithHTTPBasicClient(target.HTTPClient()))
+}
+
+// watchService 监听服务的状态变化：服务启动或重新启动后，已有会话重新订阅服务；服务停止或失败后，会话取消订阅
+// 会话订阅的服务发生变化时向下游客户端发送 tools/list_changed 通知
+func (m *SessionManager) watchService(xl xlog.Logger, mcpService *McpService) {
+	// 先订阅再同步当前状态，避免两者之间的变化丢失
+	events, unsubscribe := mcpService.SubscribeStatus()
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(attachRetryInterval)
+		defer ticker.Stop()
+		m.syncService(xl, mcpService)
+		// 事件只用于唤醒，以服务当前状态为准，重启期间的多次变化合并处理
+		// 服务运行中但订阅失败的会话定时重试
+		for {
+			select {
+			case _, ok := <-events:
+				if !ok {
+					return
+				}
+			case <-ticker.C:
+			}
+			m.syncService(xl, mcpService)
+		}
+	}()
+
+	m.watchersMutex.Lock()
+	previous := m.watchers[mcpService.Name]
+	m.watchers[mcpService.Name] = func() {
+		unsubscribe()
+		<-exited
+	}
+	m.watchersMutex.Unlock()
+	if previous != nil {
+		previous()
+	}
+}
+
+// unwatchService 停止监听服务并让所有会话取消订阅，服务从工作空间移除后调用
+func (m *SessionManager) unwatchService(xl xlog.Logger, mcpName McpName) {
+	m.watchersMutex.Lock()
+	stop := m.watchers[mcpName]
+	delete(m.watchers, mcpName)
+	m.watchersMutex.Unlock()
+	if stop != nil {
+		stop()
+	}
+
+	for _, session := range m.GetAllSessions(xl) {
+		if session.detach(xl, mcpName) {
+			session.notifyToolsListChanged(xl)
+		}
+	}
+}
+
+// syncService 使所有会话对服务的订阅与服务当前状态一致
+func (m *SessionManager) syncService(xl xlog.Logger, mcpService *McpService) {
+	status, gen := mcpService.startState()
+	for _, session := range m.GetAllSessions(xl) {
+		if syncSession(xl, session, mcpService, status, gen) {
+			session.notifyToolsListChanged(xl)
+		}
+	}
+}
+
+// syncSession 按服务当前状态订阅、重新订阅或取消订阅服务，返回会话订阅的MCP是否发生变化
+func syncSession(xl xlog.Logger, session *Session, mcpService *McpService, status CmdStatus, gen uint64) bool {
+	mcpName := mcpService.Name
+	switch status {
+	case Running:
+		if session.startGen(mcpName) == gen {
+			return false
+		}
+		xl.Infof("session %s subscribing service %s", session.Id, mcpName)
+		session.SetToolFilter(mcpName, mcpService.Config.ToolFilter)
+		if err := subscribeService(xl, session, mcpService); err != nil {
+			xl.Errorf("session %s failed to subscribe service %s: %v", session.Id, mcpName, err)
+			// 旧的客户端已经不可用
+			detached := session.detach(xl, mcpName)
+			session.markUnattached(mcpName, AttachStateFailed, err.Error())
+			return detached
+		}
+		return true
+	case Stopped, Failed:
+		detached := session.detach(xl, mcpName)
+		session.markUnattached(mcpName, AttachStatePending, fmt.Sprintf("service is %s", status))
+		return detached
+	default:
+		// 启动或停止过程中保持原有订阅，等待最终状态
+		if _, ok := session.attachment(mcpName); !ok {
+			session.markUnattached(mcpName, AttachStatePending, fmt.Sprintf("service is %s", status))
+		}
+		return false
+	}
+}
+
 func (m *SessionManager) CloseSession(xl xlog.Logger, sessionId string) error {
-	session, ok := m.GetSession(xl, sessionId)
-	if !ok {
+	if m.removeSession(sessionId) == nil {
 		xl.Errorf("session %s not found", sessionId)
 		return fmt.Errorf("session %s not found", sessionId)
 	}
+	m.counters.recordClosed()
+	return nil
+}
+
+// removeSession 删除并关闭会话，会话不存在时返回 nil
+func (m *SessionManager) removeSession(sessionId string) *Session {
 	// 先删除session，再关闭session, 避免在关闭session时，session被其他协程访问
 	m.sessionsMutex.Lock()
-	delete(m.sessions, session.Id)
+	session, ok := m.sessions[sessionId]
+	delete(m.sessions, sessionId)
 	m.sessionsMutex.Unlock()
-
+	if !ok {
+		return nil
+	}
 	session.Close()
-	return nil
+	return session
 }
 
 func (m *SessionManager) existsSession(sessionId string) bool {
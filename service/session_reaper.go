@@ -0,0 +1,250 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/errs"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+// SessionEvictReason 会话被回收的原因
+type SessionEvictReason string
+
+const (
+	SessionEvictIdle         SessionEvictReason = "idle"         // 没有活跃连接且空闲超时
+	SessionEvictMaxLifetime  SessionEvictReason = "max_lifetime" // 超过最长存活时间
+	SessionEvictDisconnected SessionEvictReason = "disconnected" // SSE 客户端断开后未重连
+)
+
+// SessionRejectReason 创建会话被拒绝的原因
+type SessionRejectReason string
+
+const (
+	SessionRejectWorkspaceLimit SessionRejectReason = "workspace_limit" // 工作空间的会话数达到上限
+	SessionRejectApiKeyLimit    SessionRejectReason = "api_key_limit"   // API Key 的会话数达到上限
+)
+
+// SessionMetrics 工作空间的会话统计
+type SessionMetrics struct {
+	Active   int                            `json:"active"`
+	Created  uint64                         `json:"created"`
+	Closed   uint64                         `json:"closed"` // 客户端主动结束的会话
+	Evicted  map[SessionEvictReason]uint64  `json:"evicted"`
+	Rejected map[SessionRejectReason]uint64 `json:"rejected"`
+	Limits   SessionLimitsInfo              `json:"limits"`
+}
+
+// SessionLimitsInfo 生效的会话回收和数量限制，0 表示不限制
+type SessionLimitsInfo struct {
+	GCIntervalSeconds  int `json:"gc_interval_seconds"`
+	IdleTimeoutSeconds int `json:"idle_timeout_seconds"`
+	MaxLifetimeSeconds int `json:"max_lifetime_seconds"`
+	MaxSessions        int `json:"max_sessions"`
+}
+
+// sessionCounters 会话的创建、结束、回收和拒绝次数
+type sessionCounters struct {
+	mu       sync.Mutex
+	created  uint64
+	closed   uint64
+	evicted  map[SessionEvictReason]uint64
+	rejected map[SessionRejectReason]uint64
+}
+
+func (c *sessionCounters) recordCreated() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.created++
+}
+
+func (c *sessionCounters) recordClosed() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed++
+}
+
+func (c *sessionCounters) recordEvicted(reason SessionEvictReason) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.evicted == nil {
+		c.evicted = make(map[SessionEvictReason]uint64)
+	}
+	c.evicted[reason]++
+}
+
+func (c *sessionCounters) recordRejected(reason SessionRejectReason) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rejected == nil {
+		c.rejected = make(map[SessionRejectReason]uint64)
+	}
+	c.rejected[reason]++
+}
+
+// snapshot 返回统计的副本
+func (c *sessionCounters) snapshot() SessionMetrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	metrics := SessionMetrics{
+		Created:  c.created,
+		Closed:   c.closed,
+		Evicted:  make(map[SessionEvictReason]uint64, len(c.evicted)),
+		Rejected: make(map[SessionRejectReason]uint64, len(c.rejected)),
+	}
+	for reason, n := range c.evicted {
+		metrics.Evicted[reason] = n
+	}
+	for reason, n := range c.rejected {
+		metrics.Rejected[reason] = n
+	}
+	return metrics
+}
+
+// evictReason 按回收规则检查会话，返回需要回收的原因，不需要回收时返回空
+func (s *Session) evictReason(now time.Time, limits config.SessionLimits) SessionEvictReason {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if limits.MaxLifetime > 0 && now.Sub(s.CreatedAt) > limits.MaxLifetime {
+		return SessionEvictMaxLifetime
+	}
+	if limits.IdleTimeout > 0 && len(s.eventChans) == 0 && now.Sub(s.LastReceiveTime) > limits.IdleTimeout {
+		return SessionEvictIdle
+	}
+	return ""
+}
+
+// touch 记录客户端的活动，只发送通知、不接收事件的客户端也不会被判定为空闲
+func (s *Session) touch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.LastReceiveTime = time.Now()
+}
+
+// SetApiKey 记录创建会话使用的 API Key
+func (s *Session) SetApiKey(apiKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiKey = apiKey
+}
+
+// getApiKey 获取创建会话使用的 API Key
+func (s *Session) getApiKey() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.apiKey
+}
+
+// getLimits 获取工作空间生效的会话限制
+func (m *SessionManager) getLimits() config.SessionLimits {
+	m.sessionsMutex.RLock()
+	defer m.sessionsMutex.RUnlock()
+	return m.limits
+}
+
+// setLimits 更新会话限制，下一次回收时生效
+func (m *SessionManager) setLimits(limits config.SessionLimits) {
+	m.sessionsMutex.Lock()
+	defer m.sessionsMutex.Unlock()
+	m.limits = limits
+}
+
+// reserveSession 检查工作空间的会话数上限并占用一个名额，返回的函数在会话创建完成或失败后释放占用
+func (m *SessionManager) reserveSession() (func(), error) {
+	m.sessionsMutex.Lock()
+	defer m.sessionsMutex.Unlock()
+	if max := m.limits.MaxSessions; max > 0 && len(m.sessions)+m.creating >= max {
+		m.counters.recordRejected(SessionRejectWorkspaceLimit)
+		return nil, fmt.Errorf("%w: workspace %s allows at most %d sessions", errs.ErrSessionLimitExceeded, m.curWorkspace.Id, max)
+	}
+	m.creating++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.sessionsMutex.Lock()
+			m.creating--
+			m.sessionsMutex.Unlock()
+		})
+	}, nil
+}
+
+// countApiKeySessions 统计使用指定 API Key 创建的会话数
+func (m *SessionManager) countApiKeySessions(xl xlog.Logger, apiKey string) int {
+	count := 0
+	for _, session := range m.GetAllSessions(xl) {
+		if session.getApiKey() == apiKey {
+			count++
+		}
+	}
+	return count
+}
+
+// startReaper 启动回收会话的协程，在第一次创建会话时启动
+func (m *SessionManager) startReaper() {
+	m.reaperOnce.Do(func() {
+		go m.runReaper()
+	})
+}
+
+// runReaper 按检查间隔回收空闲和超过存活时间的会话，检查间隔在每次回收后重新读取
+func (m *SessionManager) runReaper() {
+	xl := xlog.NewLogger("session-reaper-" + m.curWorkspace.Id)
+	timer := time.NewTimer(m.getLimits().GetGCInterval())
+	defer timer.Stop()
+	for {
+		select {
+		case <-m.stopReaper:
+			return
+		case <-timer.C:
+			m.reap(xl, time.Now())
+			timer.Reset(m.getLimits().GetGCInterval())
+		}
+	}
+}
+
+// reap 回收空闲和超过存活时间的会话
+func (m *SessionManager) reap(xl xlog.Logger, now time.Time) {
+	limits := m.getLimits()
+	for _, session := range m.GetAllSessions(xl) {
+		if reason := session.evictReason(now, limits); reason != "" {
+			m.evictSession(xl, session.Id, reason)
+		}
+	}
+}
+
+// evictSession 回收会话并记录原因
+func (m *SessionManager) evictSession(xl xlog.Logger, sessionId string, reason SessionEvictReason) {
+	if m.removeSession(sessionId) == nil {
+		return
+	}
+	xl.Infof("Evicted session %s: %s", sessionId, reason)
+	m.counters.recordEvicted(reason)
+}
+
+// Metrics 返回工作空间的会话统计和生效的限制
+func (m *SessionManager) Metrics() SessionMetrics {
+	metrics := m.counters.snapshot()
+	limits := m.getLimits()
+	m.sessionsMutex.RLock()
+	metrics.Active = len(m.sessions)
+	m.sessionsMutex.RUnlock()
+	metrics.Limits = SessionLimitsInfo{
+		GCIntervalSeconds:  int(limits.GetGCInterval() / time.Second),
+		IdleTimeoutSeconds: int(limits.IdleTimeout / time.Second),
+		MaxLifetimeSeconds: int(limits.MaxLifetime / time.Second),
+		MaxSessions:        limits.MaxSessions,
+	}
+	return metrics
+}
+
+// close 停止回收协程并关闭所有会话，工作空间关闭时调用
+func (m *SessionManager) close(xl xlog.Logger) {
+	m.closeOnce.Do(func() {
+		close(m.stopReaper)
+	})
+	for _, session := range m.GetAllSessions(xl) {
+		m.removeSession(session.Id)
+	}
+}
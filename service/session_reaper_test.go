@@ -0,0 +1,130 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/errs"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+func TestSessionManager_ReapSessions(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	workspace := NewWorkSpace("default", config.WorkspaceConfig{
+		Servers: make(map[string]config.MCPServerConfig),
+		Bridge:  config.BridgeListenConfig{Network: config.BridgeNetworkUnix, SocketDir: t.TempDir()},
+		// 工作空间中没有服务，允许创建没有服务的会话
+		WorkspaceOptions: config.WorkspaceOptions{SessionPolicy: config.SessionPolicyBestEffort},
+		Sessions: config.SessionLimits{
+			IdleTimeout: time.Minute,
+			MaxLifetime: time.Hour,
+			MaxSessions: 2,
+		},
+	}, mockPortMgr)
+	defer workspace.Close(xl)
+	mgr := workspace.sessionMgr
+
+	idle, err := mgr.CreateSession(xl, "")
+	if err != nil {
+		t.Fatalf("CreateSession() failed: %v", err)
+	}
+	connected, err := mgr.CreateSession(xl, "")
+	if err != nil {
+		t.Fatalf("CreateSession() failed: %v", err)
+	}
+	_, closer := connected.GetEventChanWithCloser()
+	defer closer()
+
+	// 达到工作空间的会话数上限后拒绝创建
+	if _, err := mgr.CreateSession(xl, ""); !errors.Is(err, errs.ErrSessionLimitExceeded) {
+		t.Fatalf("Expected session limit error, got %v", err)
+	}
+
+	// 空闲超时只回收没有活跃连接的会话
+	mgr.reap(xl, time.Now().Add(2*time.Minute))
+	if _, ok := mgr.GetSession(xl, idle.Id); ok {
+		t.Error("Expected idle session to be evicted")
+	}
+	if _, ok := mgr.GetSession(xl, connected.Id); !ok {
+		t.Fatal("Expected connected session to be kept")
+	}
+
+	// 超过最长存活时间时即使有连接也会回收
+	mgr.reap(xl, time.Now().Add(2*time.Hour))
+	if _, ok := mgr.GetSession(xl, connected.Id); ok {
+		t.Error("Expected session to be evicted after max lifetime")
+	}
+
+	// 回收后释放名额
+	created, err := mgr.CreateSession(xl, "")
+	if err != nil {
+		t.Fatalf("CreateSession() after eviction failed: %v", err)
+	}
+	mgr.CloseSession(xl, created.Id)
+
+	metrics := mgr.Metrics()
+	if metrics.Active != 0 || metrics.Created != 3 || metrics.Closed != 1 {
+		t.Errorf("Unexpected session counts: %+v", metrics)
+	}
+	if metrics.Evicted[SessionEvictIdle] != 1 || metrics.Evicted[SessionEvictMaxLifetime] != 1 {
+		t.Errorf("Unexpected evictions: %v", metrics.Evicted)
+	}
+	if metrics.Rejected[SessionRejectWorkspaceLimit] != 1 {
+		t.Errorf("Unexpected rejections: %v", metrics.Rejected)
+	}
+	if metrics.Limits.MaxSessions != 2 || metrics.Limits.IdleTimeoutSeconds != 60 {
+		t.Errorf("Unexpected limits: %+v", metrics.Limits)
+	}
+}
+
+func TestServiceManager_ApiKeySessionLimit(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	mgr := NewServiceMgr(config.Config{
+		SocketDir:            t.TempDir(),
+		MaxSessionsPerApiKey: 1,
+		Workspaces: map[string]config.WorkspaceOptions{
+			"a": {SessionPolicy: config.SessionPolicyBestEffort},
+			"b": {SessionPolicy: config.SessionPolicyBestEffort},
+		},
+	}, mockPortMgr, nil)
+	defer mgr.Close()
+
+	if _, err := mgr.CreateProxySession(xl, NameArg{Workspace: "a", ApiKey: "key"}); err != nil {
+		t.Fatalf("CreateProxySession() failed: %v", err)
+	}
+	// 上限按 API Key 在所有工作空间中计算
+	if _, err := mgr.CreateProxySession(xl, NameArg{Workspace: "b", ApiKey: "key"}); !errors.Is(err, errs.ErrSessionLimitExceeded) {
+		t.Fatalf("Expected api key session limit error, got %v", err)
+	}
+	if _, err := mgr.CreateProxySession(xl, NameArg{Workspace: "b", ApiKey: "other"}); err != nil {
+		t.Fatalf("CreateProxySession() with another api key failed: %v", err)
+	}
+
+	metrics := mgr.GetSessionMetrics(xl)
+	if metrics["a"].Active != 1 || metrics["b"].Active != 1 || metrics["b"].Rejected[SessionRejectApiKeyLimit] != 1 {
+		t.Errorf("Unexpected session metrics: %+v", metrics)
+	}
+}
+
+func TestSession_EvictReasonNotificationOnly(t *testing.T) {
+	session := NewSession("notify")
+	defer session.Close()
+	limits := config.SessionLimits{IdleTimeout: time.Minute}
+
+	// 客户端只发送通知、没有事件连接时，每条消息都刷新空闲时间
+	session.mu.Lock()
+	session.LastReceiveTime = time.Now().Add(-2 * time.Minute)
+	session.mu.Unlock()
+	if reason := session.evictReason(time.Now(), limits); reason != SessionEvictIdle {
+		t.Fatalf("Expected session to be idle, got %q", reason)
+	}
+	if err := session.SendMessage(xlog.NewLogger("test"), json.RawMessage(`{"jsonrpc": "2.0", "method": "notifications/initialized"}`)); err != nil {
+		t.Fatalf("SendMessage() failed: %v", err)
+	}
+	if reason := session.evictReason(time.Now(), limits); reason != "" {
+		t.Errorf("Expected active session to be kept, got %q", reason)
+	}
+}
@@ -1,13 +1,17 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
 	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
 )
 
 func TestSession(t *testing.T) {
@@ -180,3 +184,176 @@ func TestSessionAggregatedToolsList(t *testing.T) {
 
 	xl.Infof("Test completed successfully with %d aggregated tools", len(allTools))
 }
+
+func TestSessionForwardNotifications(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	mcpServer := server.NewMCPServer("remote", "1.0.0", server.WithResourceCapabilities(true, true))
+	ts := server.NewTestServer(mcpServer)
+	defer ts.Close()
+
+	session := NewSession("notify")
+	defer session.Close()
+	eventChan, closeChan := session.GetEventChanWithCloser()
+	defer closeChan()
+
+	if err := session.SubscribeSSE(xl, "remote", ts.URL+"/sse"); err != nil {
+		t.Fatalf("SubscribeSSE failed: %v", err)
+	}
+	// 资源列表中对外名称冲突时追加了序号，通知中使用相同的名称
+	session.GetNamespace().Register(nameKindResource, "remote_file:///tmp/a", "b.txt")
+	session.GetNamespace().Register(nameKindResource, "remote", "file:///tmp/a_b.txt")
+
+	mcpServer.SendNotificationToAllClients(mcp.MethodNotificationResourceUpdated, map[string]any{"uri": "file:///tmp/a_b.txt"})
+	// 相同的通知连续发出时都需要转发
+	mcpServer.SendNotificationToAllClients("notifications/message", map[string]any{"level": "info", "logger": "fs", "data": "hello"})
+	mcpServer.SendNotificationToAllClients("notifications/message", map[string]any{"level": "info", "logger": "fs", "data": "hello"})
+
+	expected := []struct {
+		method string
+		field  string
+		value  string
+	}{
+		{method: mcp.MethodNotificationResourceUpdated, field: "uri", value: "remote_file:///tmp/a_b.txt_2"},
+		{method: "notifications/message", field: "logger", value: "remote_fs"},
+		{method: "notifications/message", field: "logger", value: "remote_fs"},
+	}
+	for _, want := range expected {
+		select {
+		case event := <-eventChan:
+			var notification struct {
+				Method string         `json:"method"`
+				Params map[string]any `json:"params"`
+			}
+			if err := json.Unmarshal([]byte(event.Data), &notification); err != nil {
+				t.Fatalf("failed to unmarshal notification: %v", err)
+			}
+			if notification.Method != want.method {
+				t.Fatalf("expected method %s, got %s", want.method, notification.Method)
+			}
+			if notification.Params[want.field] != want.value {
+				t.Errorf("expected %s=%s, got %v", want.field, want.value, notification.Params[want.field])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for notification %s", want.method)
+		}
+	}
+}
+
+// newTestMcpServer 创建一个带有资源、资源模板和提示词的测试MCP服务
+func newTestMcpServer(name string) *server.MCPServer {
+	mcpServer := server.NewMCPServer(name, "1.0.0",
+		server.WithResourceCapabilities(true, true),
+		server.WithPromptCapabilities(true))
+	mcpServer.AddResource(mcp.NewResource("file:///readme.md", "readme"),
+		func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			return []mcp.ResourceContents{mcp.TextResourceContents{URI: request.Params.URI, Text: name}}, nil
+		})
+	mcpServer.AddResourceTemplate(mcp.NewResourceTemplate("file:///{path}", "file"),
+		func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			return nil, nil
+		})
+	mcpServer.AddPrompt(mcp.NewPrompt("greet"),
+		func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			return mcp.NewGetPromptResult(name, []mcp.PromptMessage{mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent("hello"))}), nil
+		})
+	return mcpServer
+}
+
+func TestSessionAggregatedLists(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	session := NewSession("aggregate")
+	defer session.Close()
+
+	for _, name := range []string{"alpha", "beta"} {
+		// 会话关闭后再关闭测试服务，否则 SSE 连接会阻塞 Close
+		ts := server.NewTestServer(newTestMcpServer(name))
+		t.Cleanup(ts.Close)
+		if err := session.SubscribeSSE(xl, name, ts.URL+"/sse"); err != nil {
+			t.Fatalf("SubscribeSSE %s failed: %v", name, err)
+		}
+	}
+
+	// call 发送请求并等待唯一的响应
+	call := func(id int, method string, params string) mcp.JSONRPCResponse {
+		t.Helper()
+		respChan, cancel := session.WaitResponse(mcp.NewRequestId(int64(id)))
+		defer cancel()
+		body := fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"%s","params":%s}`, id, method, params)
+		if err := session.SendMessage(xl, []byte(body)); err != nil {
+			t.Fatalf("SendMessage %s failed: %v", method, err)
+		}
+		select {
+		case msg := <-respChan:
+			var resp mcp.JSONRPCResponse
+			if err := json.Unmarshal([]byte(msg.Data), &resp); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			return resp
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %s response", method)
+		}
+		return mcp.JSONRPCResponse{}
+	}
+	decode := func(result any, v any) {
+		t.Helper()
+		data, _ := json.Marshal(result)
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatalf("failed to decode result: %v", err)
+		}
+	}
+
+	var resources mcp.ListResourcesResult
+	decode(call(1, "resources/list", "{}").Result, &resources)
+	if len(resources.Resources) != 2 || resources.Resources[0].URI != "alpha_file:///readme.md" || resources.Resources[1].URI != "beta_file:///readme.md" {
+		t.Errorf("unexpected aggregated resources: %+v", resources.Resources)
+	}
+
+	var templates struct {
+		ResourceTemplates []struct {
+			URITemplate string `json:"uriTemplate"`
+		} `json:"resourceTemplates"`
+	}
+	decode(call(2, "resources/templates/list", "{}").Result, &templates)
+	if len(templates.ResourceTemplates) != 2 || templates.ResourceTemplates[0].URITemplate != "alpha_file:///{path}" {
+		t.Errorf("unexpected aggregated templates: %+v", templates.ResourceTemplates)
+	}
+
+	var prompts mcp.ListPromptsResult
+	decode(call(3, "prompts/list", "{}").Result, &prompts)
+	if len(prompts.Prompts) != 2 || prompts.Prompts[1].Name != "beta_greet" {
+		t.Errorf("unexpected aggregated prompts: %+v", prompts.Prompts)
+	}
+
+	var read struct {
+		Contents []mcp.TextResourceContents `json:"contents"`
+	}
+	decode(call(4, "resources/read", `{"uri":"beta_file:///readme.md"}`).Result, &read)
+	if len(read.Contents) != 1 || read.Contents[0].Text != "beta" || read.Contents[0].URI != "beta_file:///readme.md" {
+		t.Errorf("unexpected resources/read result: %+v", read.Contents)
+	}
+
+	var prompt struct {
+		Description string `json:"description"`
+	}
+	decode(call(5, "prompts/get", `{"name":"alpha_greet"}`).Result, &prompt)
+	if prompt.Description != "alpha" {
+		t.Errorf("expected prompt from alpha, got %q", prompt.Description)
+	}
+}
+
+func TestSession_ToolFilter(t *testing.T) {
+	session := NewSession("filter")
+	defer session.Close()
+	session.SetToolFilter("fs", &config.ToolFilter{Allow: []string{"read_*", "write_file"}, Deny: []string{"read_secret"}})
+
+	session.updateToolsMap("fs", &mcp.ListToolsResult{Tools: []mcp.Tool{
+		mcp.NewTool("read_file"), mcp.NewTool("read_secret"), mcp.NewTool("write_file"), mcp.NewTool("delete_file"),
+	}})
+	tools := session.GetMcpTools("fs")
+	if len(tools) != 2 || tools["read_file"].Name == "" || tools["write_file"].Name == "" {
+		t.Errorf("Expected only read_file and write_file, got %v", tools)
+	}
+	if session.toolAllowed("fs", "delete_file") || !session.toolAllowed("other", "delete_file") {
+		t.Error("Expected filter to apply only to its own MCP")
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const statusSubscriberBuffer = 32
+
+// StatusEvent 服务状态的变化，启动期间同一状态下的进展也会推送，例如预热依赖包、启动子进程
+type StatusEvent struct {
+	Time      time.Time `json:"time"`
+	Service   string    `json:"service"`
+	Workspace string    `json:"workspace"`
+	Status    CmdStatus `json:"status"`
+	Message   string    `json:"message,omitempty"`
+	Error     string    `json:"error,omitempty"` // 状态为 Failed 时的错误信息
+}
+
+// statusBroadcaster 将服务状态的变化推送给订阅者，订阅者处理不及时时丢弃事件
+type statusBroadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan StatusEvent]struct{}
+}
+
+func (b *statusBroadcaster) publish(event StatusEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+func (b *statusBroadcaster) subscribe() (<-chan StatusEvent, func()) {
+	ch := make(chan StatusEvent, statusSubscriberBuffer)
+	b.mu.Lock()
+	if b.subscribers == nil {
+		b.subscribers = make(map[chan StatusEvent]struct{})
+	}
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// setStatus 更新服务状态并通知订阅者，调用方需持有锁
+func (s *McpService) setStatus(status CmdStatus, message string) {
+	s.Status = status
+	s.publishStatus(message)
+}
+
+// publishStatus 推送当前状态和进展，调用方需持有锁
+func (s *McpService) publishStatus(message string) {
+	event := s.statusEventLocked()
+	event.Message = message
+	s.statusEvents.publish(event)
+}
+
+// statusEventLocked 当前状态对应的事件，调用方需持有锁
+func (s *McpService) statusEventLocked() StatusEvent {
+	event := StatusEvent{
+		Time:      time.Now(),
+		Service:   s.Name,
+		Workspace: s.Config.Workspace,
+		Status:    s.Status,
+	}
+	if s.Status == Failed {
+		event.Error = s.LastError
+	}
+	return event
+}
+
+// SubscribeStatus 订阅服务状态的变化，返回的函数用于取消订阅
+func (s *McpService) SubscribeStatus() (<-chan StatusEvent, func()) {
+	return s.statusEvents.subscribe()
+}
+
+// WaitStarted 等待正在启动的服务启动完成或失败，返回最终状态，ctx 结束时返回当前状态
+func (s *McpService) WaitStarted(ctx context.Context) CmdStatus {
+	events, unsubscribe := s.SubscribeStatus()
+	defer unsubscribe()
+	// 事件只用于唤醒，以当前状态为准，避免丢弃事件后一直等待
+	for {
+		status := s.GetStatus()
+		if status != Starting {
+			return status
+		}
+		select {
+		case <-ctx.Done():
+			return status
+		case <-events:
+		}
+	}
+}
+
+// CurrentStatus 返回服务当前的状态
+func (s *McpService) CurrentStatus() StatusEvent {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.statusEventLocked()
+}
+
+// startState 返回服务当前的状态和最近一次启动的序号，运行中的服务序号变化说明服务已重新启动
+func (s *McpService) startState() (CmdStatus, uint64) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.Status, s.startGen
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+func TestMcpService_StartAsync(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	svc := newTestStdioService(t, "slow", map[string]string{testStdioDelayEnv: "500ms"})
+	events, unsubscribe := svc.SubscribeStatus()
+	defer unsubscribe()
+
+	if err := svc.StartAsync(xl); err != nil {
+		t.Fatalf("StartAsync() failed: %v", err)
+	}
+	defer svc.Stop(xl)
+	if status := svc.GetStatus(); status != Starting {
+		t.Fatalf("Expected service to be starting, got %s", status)
+	}
+	if err := svc.StartAsync(xl); err == nil {
+		t.Error("Expected starting service not to be started again")
+	}
+
+	// 子进程初始化期间不持有锁，可以查询服务信息
+	waitStatusEvent(t, events, func(event StatusEvent) bool { return event.Message == "Launching process" })
+	begin := time.Now()
+	if info := svc.Info(); info.Status != Starting {
+		t.Errorf("Expected info to report starting, got %s", info.Status)
+	}
+	if elapsed := time.Since(begin); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected info not to wait for startup, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if status := svc.WaitStarted(ctx); status != Running {
+		t.Fatalf("Expected service to be running, got %s", status)
+	}
+	waitStatusEvent(t, events, func(event StatusEvent) bool { return event.Status == Running })
+	if svc.GetSSEUrl() == "" {
+		t.Error("Expected running service to have SSE url")
+	}
+}
+
+func TestMcpService_StopDuringStart(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	svc := newTestStdioService(t, "slow", map[string]string{testStdioDelayEnv: "500ms"})
+	events, unsubscribe := svc.SubscribeStatus()
+	defer unsubscribe()
+
+	if err := svc.StartAsync(xl); err != nil {
+		t.Fatalf("StartAsync() failed: %v", err)
+	}
+	waitStatusEvent(t, events, func(event StatusEvent) bool { return event.Message == "Launching process" })
+	if err := svc.Stop(xl); err != nil {
+		t.Fatalf("Stop() failed: %v", err)
+	}
+	if status := svc.GetStatus(); status != Stopped {
+		t.Fatalf("Expected service to be stopped, got %s", status)
+	}
+
+	// 被放弃的启动不会把服务改回运行状态
+	time.Sleep(time.Second)
+	if status := svc.GetStatus(); status != Stopped || svc.GetSSEUrl() != "" {
+		t.Errorf("Expected abandoned startup to leave service stopped, got %s", status)
+	}
+
+	// 再次启动使用新的子进程
+	if err := svc.Start(xl); err != nil {
+		t.Fatalf("Start() after abandoned startup failed: %v", err)
+	}
+	defer svc.Stop(xl)
+	if svc.GetStatus() != Running {
+		t.Errorf("Expected service to be running, got %s", svc.GetStatus())
+	}
+}
+
+// waitStatusEvent 等待满足条件的状态事件
+func waitStatusEvent(t *testing.T, events <-chan StatusEvent, match func(StatusEvent) bool) StatusEvent {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if match(event) {
+				return event
+			}
+		case <-timeout:
+			t.Fatal("Timeout waiting for status event")
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+)
+
+// DesiredState 服务的期望状态，网关重启后按期望状态恢复服务
+type DesiredState string
+
+const (
+	DesiredStateRunning DesiredState = "running"
+	DesiredStateStopped DesiredState = "stopped"
+)
+
+// storeStateVersion 持久化状态的格式版本
+const storeStateVersion = 1
+
+// StoreState 持久化的网关状态: 所有工作空间及其服务配置和期望状态
+type StoreState struct {
+	Version    int                        `json:"version"`
+	Workspaces map[string]WorkspaceRecord `json:"workspaces"`
+}
+
+// WorkspaceRecord 持久化的工作空间
+// 工作空间选项来自 config.json，不在此保存
+type WorkspaceRecord struct {
+	Services map[string]ServiceRecord `json:"services"`
+}
+
+// ServiceRecord 持久化的服务配置，环境变量中的密钥引用按原样保存，不保存解析后的值
+type ServiceRecord struct {
+	Config       config.MCPServerConfig `json:"config"`
+	DesiredState DesiredState           `json:"desiredState"`
+}
+
+// Store 持久化工作空间和服务的存储
+type Store interface {
+	// Load 读取保存的状态，尚未保存过时返回空状态
+	Load() (StoreState, error)
+	// Save 保存完整状态，写入必须是原子的
+	Save(state StoreState) error
+}
+
+// NewStoreState 创建空的状态
+func NewStoreState() StoreState {
+	return StoreState{Version: storeStateVersion, Workspaces: make(map[string]WorkspaceRecord)}
+}
+
+// JSONFileStore 基于单个 JSON 文件的存储，先写临时文件再重命名，保证文件总是完整的
+type JSONFileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewJSONFileStore(path string) *JSONFileStore {
+	return &JSONFileStore{path: path}
+}
+
+// Path 返回状态文件路径
+func (s *JSONFileStore) Path() string {
+	return s.path
+}
+
+func (s *JSONFileStore) Load() (StoreState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return NewStoreState(), nil
+	}
+	if err != nil {
+		return StoreState{}, fmt.Errorf("read state file %s: %w", s.path, err)
+	}
+
+	state := NewStoreState()
+	if err := json.Unmarshal(data, &state); err != nil {
+		return StoreState{}, fmt.Errorf("parse state file %s: %w", s.path, err)
+	}
+	if state.Version > storeStateVersion {
+		return StoreState{}, fmt.Errorf("state file %s has unsupported version %d", s.path, state.Version)
+	}
+	if state.Workspaces == nil {
+		state.Workspaces = make(map[string]WorkspaceRecord)
+	}
+	return state, nil
+}
+
+func (s *JSONFileStore) Save(state StoreState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state.Version = storeStateVersion
+	data, err := json.MarshalIndent(state, "", "    ")
+	if err != nil {
+		return fmt.Errorf("marshal state: %w", err)
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create state dir: %w", err)
+	}
+	// 服务配置中可能包含 Token，文件仅当前用户可读写
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temp state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write state: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("replace state file: %w", err)
+	}
+	return nil
+}
+
+// nopStore 不做持久化的存储
+type nopStore struct{}
+
+func (nopStore) Load() (StoreState, error) { return NewStoreState(), nil }
+
+func (nopStore) Save(StoreState) error { return nil }
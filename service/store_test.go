@@ -0,0 +1,126 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+func TestJSONFileStore_SaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	store := NewJSONFileStore(filepath.Join(dir, "state", "state.json"))
+
+	// 尚未保存时返回空状态
+	state, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if len(state.Workspaces) != 0 {
+		t.Fatalf("Expected empty state, got %v", state.Workspaces)
+	}
+
+	state.Workspaces["ws"] = WorkspaceRecord{Services: map[string]ServiceRecord{
+		"time": {
+			Config:       config.MCPServerConfig{Command: "uvx", Args: []string{"mcp-server-time"}, Env: map[string]string{"TOKEN": "${secret:token}"}},
+			DesiredState: DesiredStateStopped,
+		},
+	}}
+	if err := store.Save(state); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, state) {
+		t.Errorf("Load() = %+v, want %+v", loaded, state)
+	}
+
+	// 写入临时文件后重命名，不残留临时文件
+	entries, err := os.ReadDir(filepath.Join(dir, "state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the state file, got %d entries", len(entries))
+	}
+	info, err := os.Stat(store.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected state file mode 0600, got %v", info.Mode().Perm())
+	}
+}
+
+func TestJSONFileStore_LoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("{invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewJSONFileStore(path).Load(); err == nil {
+		t.Error("Expected Load() to fail on invalid state file")
+	}
+}
+
+func TestWorkspaceManager_RestoreAndPersist(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	store := NewJSONFileStore(filepath.Join(t.TempDir(), "state.json"))
+	state := NewStoreState()
+	state.Workspaces["ws"] = WorkspaceRecord{Services: map[string]ServiceRecord{
+		"stopped": {Config: config.MCPServerConfig{Command: "uvx", Args: []string{"stopped"}}, DesiredState: DesiredStateStopped},
+		"removed": {Config: config.MCPServerConfig{Command: "uvx", Args: []string{"removed"}}, DesiredState: DesiredStateStopped},
+	}}
+	state.Workspaces["empty"] = WorkspaceRecord{Services: map[string]ServiceRecord{}}
+	if err := store.Save(state); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := NewWorkspaceManager(config.Config{}, mockPortMgr, store)
+	if err := mgr.Restore(xl); err != nil {
+		t.Fatalf("Restore() error: %v", err)
+	}
+	mgr.StartRestored(xl)
+
+	workspace, ok := mgr.GetWorkspace(xl, "ws", false)
+	if !ok {
+		t.Fatal("Expected workspace ws to be restored")
+	}
+	if _, ok := mgr.GetWorkspace(xl, "empty", false); !ok {
+		t.Error("Expected empty workspace to be restored")
+	}
+	services := workspace.GetMcpServices()
+	if len(services) != 2 || services["stopped"].GetStatus() != Stopped {
+		t.Fatalf("Expected restored services to stay stopped, got %v", services)
+	}
+	if _, ok := workspace.cfg.Servers["stopped"]; !ok {
+		t.Error("Expected restored service config in workspace config")
+	}
+
+	// 删除服务和新建工作空间后立即写入存储
+	if err := workspace.RemoveMcpService(xl, "removed"); err != nil {
+		t.Fatalf("RemoveMcpService() error: %v", err)
+	}
+	mgr.GetWorkspace(xl, "created", true)
+	if !mgr.RemoveWorkspace(xl, "empty") {
+		t.Error("Expected RemoveWorkspace() to remove existing workspace")
+	}
+
+	saved, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := NewStoreState()
+	expected.Workspaces["ws"] = WorkspaceRecord{Services: map[string]ServiceRecord{
+		"stopped": state.Workspaces["ws"].Services["stopped"],
+	}}
+	expected.Workspaces["created"] = WorkspaceRecord{Services: map[string]ServiceRecord{}}
+	if !reflect.DeepEqual(saved, expected) {
+		t.Errorf("Saved state = %+v, want %+v", saved, expected)
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/bridge"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+const (
+	restartBackoffBase = time.Second     // 第一次自动重启前的等待时间
+	restartBackoffMax  = time.Minute     // 自动重启等待时间的上限
+	stableUptime       = 2 * time.Minute // 连续运行超过该时长后崩溃，视为偶发崩溃，恢复全部重试次数
+	maxCrashHistory    = 20              // 保留的崩溃记录数
+)
+
+// CrashReason 服务被判定为崩溃的原因
+type CrashReason string
+
+const (
+	CrashReasonExit        CrashReason = "exit"         // 子进程意外退出
+	CrashReasonHealthCheck CrashReason = "health_check" // 健康检查连续失败
+)
+
+// CrashRecord 服务崩溃的记录
+type CrashRecord struct {
+	Time          time.Time   `json:"time"`
+	Reason        CrashReason `json:"reason"`
+	ExitCode      int         `json:"exit_code"` // 被信号终止、无法获取或不是因为退出时为 -1
+	Error         string      `json:"error,omitempty"`
+	UptimeSeconds float64     `json:"uptime_seconds"`
+	Stderr        []string    `json:"stderr,omitempty"` // 退出前 stderr 的最后几行
+}
+
+// processExitNotifier 能够通知子进程意外退出的桥接器，即 stdio 桥接器
+type processExitNotifier interface {
+	Exited() <-chan bridge.ProcessExit
+}
+
+// supervise 等待桥接器的子进程退出，意外退出时记录崩溃并按指数退避自动重启
+func (s *McpService) supervise(logger xlog.Logger, b mcpBridge) {
+	notifier, ok := b.(processExitNotifier)
+	if !ok {
+		return
+	}
+	exit, ok := <-notifier.Exited()
+	if !ok {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// 服务已被停止或重启，不是当前的子进程
+	if s.bridge != b || s.Status != Running {
+		return
+	}
+
+	record := CrashRecord{
+		Reason:   CrashReasonExit,
+		ExitCode: exit.ExitCode,
+		Stderr:   exit.Stderr,
+	}
+	if exit.Err != nil {
+		record.Error = exit.Err.Error()
+	}
+	s.recoverLocked(logger, record, fmt.Sprintf("process exited with code %d", exit.ExitCode), "Process exited unexpectedly")
+}
+
+// recoverLocked 记录崩溃，停止服务并按指数退避自动重启，调用方需持有锁
+// 连续运行超过 stableUptime 后的崩溃视为偶发，先恢复全部重试次数
+func (s *McpService) recoverLocked(logger xlog.Logger, record CrashRecord, lastError, failureReason string) {
+	uptime := time.Since(s.LastStartedAt)
+	record.Time = time.Now()
+	record.UptimeSeconds = uptime.Seconds()
+	s.crashes = append(s.crashes, record)
+	if len(s.crashes) > maxCrashHistory {
+		s.crashes = s.crashes[len(s.crashes)-maxCrashHistory:]
+	}
+	logger.Warnf("Service %s crashed after %s: %s", s.Name, uptime.Round(time.Second), lastError)
+	s.serviceLogs().Logf(LogLevelError, "Service crashed after %s: %s", uptime.Round(time.Second), lastError)
+
+	if uptime >= stableUptime {
+		s.RetryCount = s.RetryMax
+	}
+	if err := s.stopLocked(logger); err != nil {
+		logger.Errorf("Failed to stop crashed service %s: %v", s.Name, err)
+	}
+	if s.RetryCount <= 0 {
+		s.failLocked(lastError, "Max retry count reached")
+		return
+	}
+	s.failLocked(lastError, failureReason)
+	s.scheduleRestart(logger, s.RetryMax-s.RetryCount+1)
+}
+
+// scheduleRestart 等待退避时间后自动重启，调用方需持有锁
+func (s *McpService) scheduleRestart(logger xlog.Logger, attempt int) {
+	delay := restartBackoff(attempt)
+	logger.Infof("Restarting %s in %s (attempt %d/%d)", s.Name, delay.Round(time.Millisecond), attempt, s.RetryMax)
+	s.nextRestartAt = time.Now().Add(delay)
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		// 等待期间服务被停止或手动启动
+		if s.restartTimer != timer {
+			return
+		}
+		s.restartLocked(logger, false)
+	})
+	s.restartTimer = timer
+}
+
+// cancelRestart 取消等待中的自动重启，调用方需持有锁
+func (s *McpService) cancelRestart() {
+	if s.restartTimer != nil {
+		s.restartTimer.Stop()
+		s.restartTimer = nil
+	}
+	s.nextRestartAt = time.Time{}
+}
+
+// restartBackoff 第 attempt 次重启前的等待时间: 指数增长并加入随机抖动，避免多个服务同时重启
+func restartBackoff(attempt int) time.Duration {
+	delay := restartBackoffMax
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt <= 16 {
+		delay = min(restartBackoffBase<<(attempt-1), restartBackoffMax)
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// CrashHistory 返回最近的崩溃记录，按时间排序
+func (s *McpService) CrashHistory() []CrashRecord {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return append([]CrashRecord(nil), s.crashes...)
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/bridge"
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+// fakeProcessBridge 子进程退出时通过 exited 通知的桥接器
+type fakeProcessBridge struct {
+	exited chan bridge.ProcessExit
+}
+
+func (b *fakeProcessBridge) Serve(net.Listener) error          { return nil }
+func (b *fakeProcessBridge) Close() error                      { return nil }
+func (b *fakeProcessBridge) Ping(ctx context.Context) error    { return nil }
+func (b *fakeProcessBridge) Exited() <-chan bridge.ProcessExit { return b.exited }
+
+func newCrashedService(uptime time.Duration, retryCount int) (*McpService, *fakeProcessBridge) {
+	b := &fakeProcessBridge{exited: make(chan bridge.ProcessExit, 1)}
+	b.exited <- bridge.ProcessExit{ExitCode: 2, Stderr: []string{"panic: boom"}}
+	return &McpService{
+		Name:          "crash-service",
+		Status:        Running,
+		RetryCount:    retryCount,
+		RetryMax:      3,
+		LastStartedAt: time.Now().Add(-uptime),
+		bridge:        b,
+		Config:        config.MCPServerConfig{Command: "invalid-command"},
+	}, b
+}
+
+func TestMcpService_SuperviseCrash(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	service, b := newCrashedService(stableUptime+time.Minute, 0)
+
+	service.supervise(xl, b)
+
+	crashes := service.CrashHistory()
+	if len(crashes) != 1 || crashes[0].ExitCode != 2 || crashes[0].Stderr[0] != "panic: boom" {
+		t.Fatalf("Unexpected crash history: %+v", crashes)
+	}
+	info := service.Info()
+	if info.Status != Failed || info.FailureReason != "Process exited unexpectedly" {
+		t.Errorf("Unexpected status after crash: %s (%s)", info.Status, info.FailureReason)
+	}
+	// 稳定运行后崩溃，恢复全部重试次数并等待自动重启
+	if info.RetryCount != info.RetryMax {
+		t.Errorf("Expected retry budget to be reset, got %d/%d", info.RetryCount, info.RetryMax)
+	}
+	if info.NextRestartAt.IsZero() {
+		t.Fatal("Expected an automatic restart to be scheduled")
+	}
+
+	// 手动停止时取消自动重启
+	service.Stop(xl)
+	if !service.Info().NextRestartAt.IsZero() {
+		t.Error("Expected Stop() to cancel the scheduled restart")
+	}
+}
+
+func TestMcpService_SuperviseCrashLoop(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	service, b := newCrashedService(time.Second, 0)
+
+	service.supervise(xl, b)
+
+	info := service.Info()
+	if info.FailureReason != "Max retry count reached" || !info.NextRestartAt.IsZero() {
+		t.Errorf("Expected no restart without retry budget, got %q next=%v", info.FailureReason, info.NextRestartAt)
+	}
+}
+
+func TestRestartBackoff(t *testing.T) {
+	for attempt, base := range map[int]time.Duration{1: time.Second, 3: 4 * time.Second, 100: restartBackoffMax} {
+		delay := restartBackoff(attempt)
+		if delay < base/2 || delay > base {
+			t.Errorf("restartBackoff(%d) = %s, want between %s and %s", attempt, delay, base/2, base)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// upstreamDetectTimeout 探测远程服务传输协议的超时时间
+const upstreamDetectTimeout = 10 * time.Second
+
+// guessUpstreamType 不发起网络请求，根据配置推断远程服务的传输协议，无法推断时返回 UpstreamAuto
+func guessUpstreamType(cfg config.MCPServerConfig) config.MCPUpstreamType {
+	if cfg.Type != config.UpstreamAuto {
+		return cfg.Type
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return config.UpstreamAuto
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	switch {
+	case strings.HasSuffix(path, "/sse"):
+		return config.UpstreamSSE
+	case strings.HasSuffix(path, "/mcp"):
+		return config.UpstreamStreamableHTTP
+	}
+	return config.UpstreamAuto
+}
+
+// errNotStreamableHTTP 远程服务明确表示不支持 Streamable HTTP，应回退到 SSE
+var errNotStreamableHTTP = errors.New("upstream does not support streamable http")
+
+// detectUpstreamType 探测远程服务的传输协议
+// 按照 MCP 规范的向后兼容方式: 先尝试 Streamable HTTP 的 initialize，服务返回 404/405 等状态码或非 MCP 响应时回退到 SSE；
+// 鉴权失败、网络错误和超时无法判断协议，返回错误
+func detectUpstreamType(ctx context.Context, cfg config.MCPServerConfig) (config.MCPUpstreamType, error) {
+	if t := guessUpstreamType(cfg); t != config.UpstreamAuto {
+		return t, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, upstreamDetectTimeout)
+	defer cancel()
+
+	err := probeStreamableHTTP(ctx, http.DefaultClient, cfg)
+	if errors.Is(err, errNotStreamableHTTP) {
+		return config.UpstreamSSE, nil
+	}
+	if err != nil {
+		return config.UpstreamAuto, fmt.Errorf("detect upstream transport: %w", err)
+	}
+	return config.UpstreamStreamableHTTP, nil
+}
+
+// probeStreamableHTTP 以 Streamable HTTP 方式向远程服务发送 initialize，只检查响应的状态码和类型
+func probeStreamableHTTP(ctx context.Context, httpClient *http.Client, cfg config.MCPServerConfig) error {
+	body, err := json.Marshal(mcp.JSONRPCRequest{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		ID:      mcp.NewRequestId(int64(1)),
+		Request: mcp.Request{Method: string(mcp.MethodInitialize)},
+		Params: mcp.InitializeParams{
+			ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION,
+			ClientInfo: mcp.Implementation{
+				Name:    "mcp-gateway-client",
+				Version: "1.0.0",
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal initialize request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	for k, v := range cfg.GetHeaders() {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 探测时建立的会话不再使用
+	if sessionId := resp.Header.Get("Mcp-Session-Id"); sessionId != "" {
+		defer terminateProbeSession(httpClient, cfg, sessionId)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("authorization failed with status %d", resp.StatusCode)
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusNotFound ||
+		resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotAcceptable ||
+		resp.StatusCode == http.StatusUnsupportedMediaType:
+		return fmt.Errorf("%w: status %d", errNotStreamableHTTP, resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "application/json" && mediaType != "text/event-stream" {
+		return fmt.Errorf("%w: content type %q", errNotStreamableHTTP, resp.Header.Get("Content-Type"))
+	}
+	return nil
+}
+
+// terminateProbeSession 结束探测时建立的会话，失败时忽略
+func terminateProbeSession(httpClient *http.Client, cfg config.MCPServerConfig, sessionId string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, cfg.URL, nil)
+	if err != nil {
+		return
+	}
+	for k, v := range cfg.GetHeaders() {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Mcp-Session-Id", sessionId)
+	if resp, err := httpClient.Do(req); err == nil {
+		resp.Body.Close()
+	}
+}
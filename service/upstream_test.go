@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestGuessUpstreamType(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.MCPServerConfig
+		expected config.MCPUpstreamType
+	}{
+		{name: "explicit type", cfg: config.MCPServerConfig{URL: "http://example.com/sse", Type: config.UpstreamStreamableHTTP}, expected: config.UpstreamStreamableHTTP},
+		{name: "sse path", cfg: config.MCPServerConfig{URL: "http://example.com/sse"}, expected: config.UpstreamSSE},
+		{name: "mcp path with trailing slash", cfg: config.MCPServerConfig{URL: "http://example.com/mcp/"}, expected: config.UpstreamStreamableHTTP},
+		{name: "unknown path", cfg: config.MCPServerConfig{URL: "http://example.com/api"}, expected: config.UpstreamAuto},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := guessUpstreamType(tt.cfg); got != tt.expected {
+				t.Errorf("guessUpstreamType() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDetectUpstreamType(t *testing.T) {
+	streamable, _ := newRemoteStreamableServer(t)
+	statusServer := func(status int, contentType string) string {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(status)
+		}))
+		t.Cleanup(ts.Close)
+		return ts.URL
+	}
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name     string
+		url      string
+		expected config.MCPUpstreamType
+		wantErr  bool
+	}{
+		{name: "streamable http", url: streamable.URL, expected: config.UpstreamStreamableHTTP},
+		{name: "method not allowed", url: statusServer(http.StatusMethodNotAllowed, "text/plain"), expected: config.UpstreamSSE},
+		{name: "not found", url: statusServer(http.StatusNotFound, "text/plain"), expected: config.UpstreamSSE},
+		{name: "wrong content type", url: statusServer(http.StatusOK, "text/html"), expected: config.UpstreamSSE},
+		{name: "unauthorized", url: statusServer(http.StatusUnauthorized, "text/plain"), wantErr: true},
+		{name: "forbidden", url: statusServer(http.StatusForbidden, "text/plain"), wantErr: true},
+		{name: "server error", url: statusServer(http.StatusBadGateway, "text/plain"), wantErr: true},
+		{name: "connection refused", url: closed.URL, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := detectUpstreamType(context.Background(), config.MCPServerConfig{URL: tt.url})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("detectUpstreamType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.expected {
+				t.Errorf("detectUpstreamType() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMcpService_UpstreamDetectErrorNotCached(t *testing.T) {
+	logger := xlog.NewLogger("test")
+	unauthorized := true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unauthorized {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	defer ts.Close()
+
+	svc := NewMcpService("remote", config.MCPServerConfig{URL: ts.URL}, mockPortMgr)
+	if err := svc.Start(logger); err == nil {
+		t.Fatal("Expected start to fail when upstream rejects credentials")
+	}
+	if svc.GetStatus() == Running || svc.IsSSE() {
+		t.Errorf("Expected failed detection not to be cached, status %s", svc.GetStatus())
+	}
+
+	// 凭据修复后重新探测
+	unauthorized = false
+	if err := svc.Start(logger); err != nil {
+		t.Fatalf("Start() after fixing credentials failed: %v", err)
+	}
+	defer svc.Stop(logger)
+	if !svc.IsSSE() {
+		t.Error("Expected upstream to be detected as sse")
+	}
+}
+
+func TestMCPServerConfig_GetHeaders(t *testing.T) {
+	cfg := config.MCPServerConfig{
+		Headers:     map[string]string{"X-Api-Key": "key", "Authorization": "Basic xxx"},
+		BearerToken: "token",
+	}
+	headers := cfg.GetHeaders()
+	if headers["X-Api-Key"] != "key" {
+		t.Errorf("Expected custom header to be kept, got %v", headers)
+	}
+	if headers["Authorization"] != "Bearer token" {
+		t.Errorf("Expected bearer token to override Authorization, got %q", headers["Authorization"])
+	}
+	if (&config.MCPServerConfig{}).GetHeaders() != nil {
+		t.Errorf("Expected nil headers when nothing configured")
+	}
+}
+
+// newRemoteStreamableServer 启动一个仅支持 Streamable HTTP 的远程 MCP 服务，并记录收到的 Authorization 请求头
+func newRemoteStreamableServer(t *testing.T) (*httptest.Server, func() []string) {
+	mcpServer := server.NewMCPServer("remote", "1.0.0", server.WithToolCapabilities(true))
+	mcpServer.AddTool(mcp.NewTool("echo"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+	streamServer := server.NewStreamableHTTPServer(mcpServer)
+
+	var mu sync.Mutex
+	var auths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// mcp-go 关闭会话时发送的 DELETE 请求不携带自定义请求头
+		if r.Method != http.MethodDelete {
+			mu.Lock()
+			auths = append(auths, r.Header.Get("Authorization"))
+			mu.Unlock()
+		}
+		if r.Method == http.MethodGet {
+			// 不支持服务端主动推送的事件流
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		streamServer.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), auths...)
+	}
+}
+
+func TestMcpService_RemoteStreamableHTTPUpstream(t *testing.T) {
+	ts, receivedAuths := newRemoteStreamableServer(t)
+	logger := xlog.NewLogger("test")
+
+	svc := NewMcpService("remote", config.MCPServerConfig{
+		URL:         ts.URL,
+		BearerToken: "secret",
+	}, mockPortMgr)
+	if err := svc.Start(logger); err != nil {
+		t.Fatalf("Failed to start remote service: %v", err)
+	}
+
+	if svc.GetStatus() != Running {
+		t.Errorf("Expected status Running, got %s", svc.GetStatus())
+	}
+	if !svc.IsStreamableHTTP() {
+		t.Fatalf("Expected upstream to be detected as streamable-http")
+	}
+	if svc.GetSSEUrl() != "" {
+		t.Errorf("Expected empty SSE url, got %s", svc.GetSSEUrl())
+	}
+	if svc.GetStreamableHTTPUrl() != ts.URL {
+		t.Errorf("Expected streamable http url %s, got %s", ts.URL, svc.GetStreamableHTTPUrl())
+	}
+
+	// 远程服务可以加入会话聚合
+	session := NewSession("test-session")
+	defer session.Close()
+	err := session.SubscribeStreamableHTTP(logger, svc.Name, svc.GetStreamableHTTPUrl(), transport.WithHTTPHeaders(svc.GetUpstreamHeaders()))
+	if err != nil {
+		t.Fatalf("Failed to subscribe remote service: %v", err)
+	}
+	if !session.IsReady() {
+		t.Errorf("Expected session to be ready")
+	}
+
+	auths := receivedAuths()
+	if len(auths) == 0 {
+		t.Fatalf("Expected remote server to receive requests")
+	}
+	for _, auth := range auths {
+		if auth != "Bearer secret" {
+			t.Errorf("Expected bearer token on every request, got %q", auth)
+		}
+	}
+}
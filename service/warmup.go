@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/sandbox"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+// warmup 执行预热命令下载并缓存依赖包，失败时只记录日志，启动子进程时仍会自行安装，调用时无需持有锁
+func (s *McpService) warmup(logger xlog.Logger, command string, args []string) {
+	envs, err := s.envResolver.Resolve(s.Config)
+	if err != nil {
+		logger.Warnf("Skip warming up %s: failed to resolve env: %v", s.Name, err)
+		return
+	}
+
+	// 预热命令与服务的子进程使用相同的沙箱，以相同的用户、目录和资源限制运行
+	sb, err := sandbox.New(logger, s.sandboxID()+"-warmup", s.Config.Sandbox)
+	if err != nil {
+		logger.Warnf("Skip warming up %s: failed to create sandbox: %v", s.Name, err)
+		return
+	}
+	defer sb.Close()
+	launchCommand, launchArgs, err := sb.Command(command, args)
+	if err != nil {
+		logger.Warnf("Skip warming up %s: %v", s.Name, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.Config.Warmup.Timeout())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, launchCommand, launchArgs...)
+	cmd.Env = append(append(os.Environ(), envs...), sb.Env()...)
+	cmd.Stdout = s.serviceLogs()
+	cmd.Stderr = s.serviceLogs()
+
+	logger.Infof("Warming up %s: %s %s", s.Name, command, strings.Join(args, " "))
+	s.serviceLogs().Logf(LogLevelInfo, "Warming up packages: %s %s", command, strings.Join(args, " "))
+	started := time.Now()
+	if err := cmd.Run(); err != nil {
+		logger.Warnf("Failed to warm up %s: %v", s.Name, err)
+		s.serviceLogs().Logf(LogLevelWarn, "Failed to warm up packages: %v", err)
+		return
+	}
+	logger.Infof("Warmed up %s in %s", s.Name, time.Since(started).Round(time.Millisecond))
+	s.serviceLogs().Logf(LogLevelInfo, "Warmed up packages in %s", time.Since(started).Round(time.Millisecond))
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/lucky-aeon/agentx/plugin-helper/config"
+	"github.com/lucky-aeon/agentx/plugin-helper/xlog"
+)
+
+func TestWorkSpace_WarmupOnDeploy(t *testing.T) {
+	xl := xlog.NewLogger("test")
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to locate test binary: %v", err)
+	}
+	marker := filepath.Join(t.TempDir(), "warmup")
+	workspace := NewWorkSpace("default", config.WorkspaceConfig{
+		Servers: make(map[string]config.MCPServerConfig),
+		Bridge:  config.BridgeListenConfig{Network: config.BridgeNetworkUnix, SocketDir: t.TempDir()},
+	}, mockPortMgr)
+	defer workspace.Close(xl)
+
+	_, err = workspace.AddMcpService(xl, "warm", config.MCPServerConfig{
+		Workspace:   "default",
+		Command:     exe,
+		Env:         map[string]string{testStdioServerEnv: "1"},
+		Warmup:      &config.WarmupConfig{Command: "sh", Args: []string{"-c", "echo installed >> " + marker}},
+		HealthCheck: &config.HealthCheckConfig{Disabled: true},
+		LogConfig:   config.LogConfig{Path: t.TempDir()},
+	})
+	if err != nil {
+		t.Fatalf("AddMcpService() failed: %v", err)
+	}
+
+	// 预热只在部署时执行一次，重启不再执行
+	if err := workspace.RestartMcpService(xl, "warm"); err != nil {
+		t.Fatalf("RestartMcpService() failed: %v", err)
+	}
+	data, err := os.ReadFile(marker)
+	if err != nil {
+		t.Fatalf("Expected warmup command to run: %v", err)
+	}
+	if lines := strings.Count(string(data), "installed"); lines != 1 {
+		t.Errorf("Expected warmup command to run once, ran %d times", lines)
+	}
+}
+
+func TestMcpService_WarmupInSandbox(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("sandbox is only supported on linux")
+	}
+	dir := t.TempDir()
+	marker := filepath.Join(t.TempDir(), "warmup")
+	svc := newTestStdioService(t, "warm", nil)
+	svc.Config.Sandbox = &config.SandboxConfig{WorkDir: dir, OpenFiles: 64}
+
+	// 预热命令经过沙箱启动器，使用服务配置的目录和资源限制
+	svc.warmup(xlog.NewLogger("test"), "sh", []string{"-c", "pwd > " + marker + "; ulimit -n >> " + marker})
+	data, err := os.ReadFile(marker)
+	if err != nil {
+		t.Fatalf("Expected warmup command to run: %v", err)
+	}
+	if output, want := strings.TrimSpace(string(data)), dir+"\n64"; output != want {
+		t.Errorf("Unexpected output %q, want %q", output, want)
+	}
+}
@@ -28,15 +28,25 @@ type WorkSpace struct {
 
 	// MCP
 	servers      map[string]*McpService
+	desired      map[string]DesiredState // 服务的期望状态，持久化后用于重启恢复
 	serversMutex sync.RWMutex
 
+	// onChange 服务增删、启停或配置变更后调用，用于持久化
+	onChange func(xl xlog.Logger)
+
 	// Other Mgr
 	portManager PortManagerI
 	sessionMgr  *SessionManager
 }
 
 func NewWorkSpace(workId string, cfg config.WorkspaceConfig, portManager PortManagerI) *WorkSpace {
-	space := &WorkSpace{Id: workId, cfg: cfg, portManager: portManager, servers: make(map[string]*McpService)}
+	space := &WorkSpace{
+		Id:          workId,
+		cfg:         cfg,
+		portManager: portManager,
+		servers:     make(map[string]*McpService),
+		desired:     make(map[string]DesiredState),
+	}
 	// init session manager, it will be used to create session for each workspace
 	space.sessionMgr = NewSessionManager(space)
 	return space
@@ -54,6 +64,16 @@ const (
 // AddMcpService adds a new MCP service to the workspace.
 // Returns the operation result type and error if any.
 func (w *WorkSpace) AddMcpService(xl xlog.Logger, serviceName string, mcpConfig config.MCPServerConfig) (AddMcpServiceResult, error) {
+	return w.addMcpService(xl, serviceName, mcpConfig, false)
+}
+
+// AddMcpServiceAsync 添加服务并在后台启动，服务以 Starting 状态加入工作空间后立即返回
+// 启动失败的服务保留在工作空间中，状态为 Failed
+func (w *WorkSpace) AddMcpServiceAsync(xl xlog.Logger, serviceName string, mcpConfig config.MCPServerConfig) (AddMcpServiceResult, error) {
+	return w.addMcpService(xl, serviceName, mcpConfig, true)
+}
+
+func (w *WorkSpace) addMcpService(xl xlog.Logger, serviceName string, mcpConfig config.MCPServerConfig, async bool) (AddMcpServiceResult, error) {
 	xl.Infof("Adding MCP service %s", serviceName)
 
 	// check if the service already exists
@@ -88,15 +108,27 @@ func (w *WorkSpace) AddMcpService(xl xlog.Logger, serviceName string, mcpConfig
 
 	// create service instance
 	instance := NewMcpService(serviceName, mcpConfig, w.portManager)
-	if err := instance.Start(xl); err != nil {
+	instance.envResolver = w.cfg.EnvResolver
+	instance.bridgeListen = w.cfg.Bridge
+	instance.RetryMax = w.retryMax(mcpConfig)
+	instance.warmupPending = true
+	if async {
+		if err := instance.StartAsync(xl); err != nil {
+			xl.Errorf("Failed to start service %s: %v", serviceName, err)
+			return "", err
+		}
+	} else if err := instance.Start(xl); err != nil {
 		xl.Errorf("Failed to start service %s: %v", serviceName, err)
 		return "", err
 	}
 
 	// add to workspace
 	w.serversMutex.Lock()
-	defer w.serversMutex.Unlock()
 	w.servers[serviceName] = instance
+	w.desired[serviceName] = DesiredStateRunning
+	w.serversMutex.Unlock()
+	w.sessionMgr.watchService(xl, instance)
+	w.changed(xl)
 
 	if serviceExists {
 		return AddMcpServiceResultReplaced, nil
@@ -168,6 +200,7 @@ func (w *WorkSpace) RestartMcpService(xl xlog.Logger, serviceName string) error
 	if err != nil {
 		return err
 	}
+	w.setDesiredState(xl, serviceName, DesiredStateRunning)
 	server.Restart(xl)
 	return nil
 }
@@ -180,13 +213,23 @@ func (w *WorkSpace) StopMcpService(xl xlog.Logger, serviceName string) error {
 	if err != nil {
 		return err
 	}
+	w.setDesiredState(xl, serviceName, DesiredStateStopped)
 	server.Stop(xl)
 	return nil
 }
 
 // RemoveMcpService removes the MCP service with the given name.
+// Unlike the internal removal, the service config is dropped as well.
 func (w *WorkSpace) RemoveMcpService(xl xlog.Logger, serviceName string) error {
-	return w.removeMcpServiceInternal(xl, serviceName)
+	if err := w.removeMcpServiceInternal(xl, serviceName); err != nil {
+		return err
+	}
+	w.serversMutex.Lock()
+	delete(w.cfg.Servers, serviceName)
+	delete(w.desired, serviceName)
+	w.serversMutex.Unlock()
+	w.changed(xl)
+	return nil
 }
 
 // removeMcpServiceInternal removes the MCP service with the given name.
@@ -208,9 +251,11 @@ func (w *WorkSpace) removeMcpServiceInternal(xl xlog.Logger, serviceName string)
 
 	// 最后从map中删除
 	w.serversMutex.Lock()
-	defer w.serversMutex.Unlock()
 	delete(w.servers, serviceName)
+	w.serversMutex.Unlock()
 
+	// 已有会话取消订阅被移除的服务
+	w.sessionMgr.unwatchService(xl, serviceName)
 	return nil
 }
 
@@ -221,7 +266,103 @@ func (w *WorkSpace) SetMcpServiceConfig(xl xlog.Logger, serviceName string, mcpC
 	if err != nil {
 		return err
 	}
-	return server.setConfig(mcpConfig)
+	if err := server.setConfig(mcpConfig); err != nil {
+		return err
+	}
+	w.changed(xl)
+	return nil
+}
+
+// restoreMcpService 注册持久化的服务，不启动服务，启动由 startRestoredMcpServices 完成
+func (w *WorkSpace) restoreMcpService(xl xlog.Logger, serviceName string, record ServiceRecord) {
+	xl.Infof("Restoring MCP service %s, desired state: %s", serviceName, record.DesiredState)
+	desired := record.DesiredState
+	if desired != DesiredStateStopped {
+		desired = DesiredStateRunning
+	}
+
+	instance := NewMcpService(serviceName, record.Config, w.portManager)
+	instance.envResolver = w.cfg.EnvResolver
+	instance.bridgeListen = w.cfg.Bridge
+	instance.RetryMax = w.retryMax(record.Config)
+
+	w.serversMutex.Lock()
+	w.cfg.AddMcpServerCfg(serviceName, record.Config)
+	w.servers[serviceName] = instance
+	w.desired[serviceName] = desired
+	w.serversMutex.Unlock()
+	w.sessionMgr.watchService(xl, instance)
+}
+
+// startRestoredMcpServices 启动期望运行但尚未启动的服务，启动失败的服务保留在工作空间中，状态为 Failed
+func (w *WorkSpace) startRestoredMcpServices(xl xlog.Logger) {
+	for name, instance := range w.getMcpServices() {
+		w.serversMutex.RLock()
+		desired := w.desired[name]
+		w.serversMutex.RUnlock()
+		if desired != DesiredStateRunning || instance.GetStatus() != Stopped {
+			continue
+		}
+		xl.Infof("Starting restored MCP service %s", name)
+		if err := instance.Start(xl); err != nil {
+			xl.Errorf("Failed to start restored service %s: %v", name, err)
+		}
+	}
+}
+
+// retryMax 服务的最大重试次数，服务配置未指定时使用工作空间的配置
+func (w *WorkSpace) retryMax(mcpConfig config.MCPServerConfig) int {
+	if mcpConfig.McpServiceRetryCount > 0 {
+		return mcpConfig.McpServiceRetryCount
+	}
+	w.serversMutex.RLock()
+	defer w.serversMutex.RUnlock()
+	return w.cfg.McpServiceMgrConfig.GetMcpServiceRetryCount()
+}
+
+// updateServiceMgrConfig 更新工作空间的服务管理配置，未单独指定重试次数的服务立即生效
+func (w *WorkSpace) updateServiceMgrConfig(xl xlog.Logger, mgrConfig config.McpServiceMgrConfig) {
+	w.serversMutex.Lock()
+	w.cfg.McpServiceMgrConfig = mgrConfig
+	w.serversMutex.Unlock()
+
+	for name, instance := range w.getMcpServices() {
+		if instance.Config.McpServiceRetryCount > 0 {
+			continue
+		}
+		xl.Infof("Updating retry count of service %s to %d", name, mgrConfig.GetMcpServiceRetryCount())
+		instance.setRetryMax(mgrConfig.GetMcpServiceRetryCount())
+	}
+}
+
+// setDesiredState 记录服务的期望状态
+func (w *WorkSpace) setDesiredState(xl xlog.Logger, serviceName string, desired DesiredState) {
+	w.serversMutex.Lock()
+	w.desired[serviceName] = desired
+	w.serversMutex.Unlock()
+	w.changed(xl)
+}
+
+// record 返回需要持久化的工作空间状态
+func (w *WorkSpace) record() WorkspaceRecord {
+	w.serversMutex.RLock()
+	defer w.serversMutex.RUnlock()
+	record := WorkspaceRecord{Services: make(map[string]ServiceRecord, len(w.servers))}
+	for name, instance := range w.servers {
+		desired := w.desired[name]
+		if desired == "" {
+			desired = DesiredStateRunning
+		}
+		record.Services[name] = ServiceRecord{Config: instance.Config, DesiredState: desired}
+	}
+	return record
+}
+
+// changed 通知工作空间状态已变更
+func (w *WorkSpace) changed(xl xlog.Logger) {
+	if w.onChange != nil {
+		w.onChange(xl)
+	}
 }
 
 // Close stops all MCP services in the workspace.
@@ -254,5 +395,6 @@ func (w *WorkSpace) Close(xl xlog.Logger) {
 			delete(w.servers, serverName)
 		}
 	}
+	w.sessionMgr.close(xl)
 	xl.Infof("Workspace %s closed successfully", w.Id)
 }
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/google/uuid"
@@ -14,10 +15,17 @@ type WorkspaceManager struct {
 
 	cfg         config.Config
 	portManager PortManagerI
+
+	// 持久化工作空间和服务，persistMutex 保证快照按变更顺序写入
+	store        Store
+	persistMutex sync.Mutex
 }
 
-func NewWorkspaceManager(cfg config.Config, portManager PortManagerI) *WorkspaceManager {
-	return &WorkspaceManager{workspaces: make(map[string]*WorkSpace), cfg: cfg, portManager: portManager}
+func NewWorkspaceManager(cfg config.Config, portManager PortManagerI, store Store) *WorkspaceManager {
+	if store == nil {
+		store = nopStore{}
+	}
+	return &WorkspaceManager{workspaces: make(map[string]*WorkSpace), cfg: cfg, portManager: portManager, store: store}
 }
 
 // GetWorkspace returns a workspace by id. If the workspace does not exist, it creates a new one.
@@ -30,6 +38,7 @@ func (m *WorkspaceManager) GetWorkspace(xl xlog.Logger, workId string, createIfN
 		xl.Warnf("Workspace %s not found, creating new workspace", workId)
 		if createIfNotExists {
 			workspace = m.createWorkspace(xl, workId)
+			m.persist(xl)
 			return workspace, true
 		}
 		return nil, false
@@ -44,14 +53,22 @@ func (m *WorkspaceManager) createWorkspace(xl xlog.Logger, workId string) *WorkS
 	if workId == "" {
 		workId = uuid.New().String()
 	}
+	m.workspacesLock.RLock()
+	cfg := m.cfg
+	m.workspacesLock.RUnlock()
 	workspace := NewWorkSpace(workId, config.WorkspaceConfig{
 		LogConfig: config.LogConfig{
-			Level: m.cfg.LogLevel,
-			Path:  m.cfg.ConfigDirPath,
+			Level: cfg.LogLevel,
+			Path:  cfg.ConfigDirPath,
 		},
-		McpServiceMgrConfig: m.cfg.McpServiceMgrConfig,
+		McpServiceMgrConfig: cfg.McpServiceMgrConfig,
+		WorkspaceOptions:    cfg.GetWorkspaceOptions(workId),
+		EnvResolver:         cfg.GetEnvResolver(),
+		Bridge:              cfg.GetBridgeListenConfig(),
+		Sessions:            cfg.GetSessionLimits(workId),
 		Servers:             make(map[string]config.MCPServerConfig),
 	}, m.portManager)
+	workspace.onChange = m.persist
 	m.workspacesLock.Lock()
 	m.workspaces[workspace.Id] = workspace
 	m.workspacesLock.Unlock()
@@ -61,5 +78,75 @@ func (m *WorkspaceManager) createWorkspace(xl xlog.Logger, workId string) *WorkS
 func (m *WorkspaceManager) GetWorkspaces() map[string]*WorkSpace {
 	m.workspacesLock.RLock()
 	defer m.workspacesLock.RUnlock()
-	return m.workspaces
+	workspaces := make(map[string]*WorkSpace, len(m.workspaces))
+	for id, workspace := range m.workspaces {
+		workspaces[id] = workspace
+	}
+	return workspaces
+}
+
+// RemoveWorkspace 关闭工作空间的所有服务并删除工作空间
+func (m *WorkspaceManager) RemoveWorkspace(xl xlog.Logger, workId string) bool {
+	m.workspacesLock.Lock()
+	workspace, ok := m.workspaces[workId]
+	delete(m.workspaces, workId)
+	m.workspacesLock.Unlock()
+	if !ok {
+		return false
+	}
+	workspace.Close(xl)
+	m.persist(xl)
+	return true
+}
+
+// UpdateConfig 热加载配置: 更新之后创建的工作空间使用的配置，并将重试次数和会话限制应用到现有工作空间
+func (m *WorkspaceManager) UpdateConfig(xl xlog.Logger, cfg config.Config) {
+	m.workspacesLock.Lock()
+	m.cfg = cfg
+	m.workspacesLock.Unlock()
+
+	for id, workspace := range m.GetWorkspaces() {
+		workspace.updateServiceMgrConfig(xl, cfg.McpServiceMgrConfig)
+		workspace.sessionMgr.setLimits(cfg.GetSessionLimits(id))
+	}
+}
+
+// Restore 从存储中恢复工作空间和服务，只注册不启动，启动由 StartRestored 完成
+func (m *WorkspaceManager) Restore(xl xlog.Logger) error {
+	state, err := m.store.Load()
+	if err != nil {
+		return fmt.Errorf("load state: %w", err)
+	}
+	for workId, record := range state.Workspaces {
+		workspace, ok := m.GetWorkspace(xl, workId, false)
+		if !ok {
+			workspace = m.createWorkspace(xl, workId)
+		}
+		for name, service := range record.Services {
+			workspace.restoreMcpService(xl, name, service)
+		}
+	}
+	xl.Infof("Restored %d workspaces from state", len(state.Workspaces))
+	return nil
+}
+
+// StartRestored 启动所有期望运行但尚未启动的服务
+func (m *WorkspaceManager) StartRestored(xl xlog.Logger) {
+	for _, workspace := range m.GetWorkspaces() {
+		workspace.startRestoredMcpServices(xl)
+	}
+}
+
+// persist 保存所有工作空间的快照，失败时只记录日志，不影响服务操作
+func (m *WorkspaceManager) persist(xl xlog.Logger) {
+	m.persistMutex.Lock()
+	defer m.persistMutex.Unlock()
+
+	state := NewStoreState()
+	for id, workspace := range m.GetWorkspaces() {
+		state.Workspaces[id] = workspace.record()
+	}
+	if err := m.store.Save(state); err != nil {
+		xl.Errorf("Failed to persist workspaces: %v", err)
+	}
 }
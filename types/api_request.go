@@ -7,6 +7,11 @@ type DeployRequest struct {
 	MCPServers map[string]config.MCPServerConfig `json:"mcpServers"`
 }
 
+// WorkspaceManifest 工作空间清单，描述工作空间应有的全部服务，格式与部署请求相同
+type WorkspaceManifest struct {
+	MCPServers map[string]config.MCPServerConfig `json:"mcpServers"`
+}
+
 // ServiceDeployStatus 服务部署状态
 type ServiceDeployStatus string
 
@@ -23,6 +28,8 @@ type ServiceDeployResult struct {
 	Status  ServiceDeployStatus `json:"status"`            // 部署状态
 	Message string              `json:"message,omitempty"` // 详细信息或错误消息
 	Error   string              `json:"error,omitempty"`   // 错误详情
+
+	StatusURL string `json:"status_url,omitempty"` // 后台启动时订阅服务状态变化的地址
 }
 
 // DeployResponse 部署响应结构
@@ -3,6 +3,7 @@ package utils
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/labstack/echo/v4"
@@ -37,3 +38,39 @@ func GetSession(c echo.Context) (string, error) {
 	}
 	return session, nil
 }
+
+// McpSessionIdHeader Streamable HTTP 传输使用的会话头
+const McpSessionIdHeader = "Mcp-Session-Id"
+
+// GetMcpSessionId 获取 Streamable HTTP 的 session, 从 Mcp-Session-Id header 中获取
+func GetMcpSessionId(c echo.Context) (string, error) {
+	session := c.Request().Header.Get(McpSessionIdHeader)
+	if session == "" {
+		return "", fmt.Errorf("missing %s header", McpSessionIdHeader)
+	}
+	return session, nil
+}
+
+// LastEventIdHeader SSE 客户端重连时携带的最后收到的事件序号
+const LastEventIdHeader = "Last-Event-ID"
+
+// GetLastEventId 获取客户端重连时携带的事件序号，没有或无法解析时返回 false
+func GetLastEventId(c echo.Context) (uint64, bool) {
+	value := c.Request().Header.Get(LastEventIdHeader)
+	if value == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// GetApiKey 获取请求使用的 API Key, 优先从 Authorization Bearer header 中获取，如果没有则从 query 中获取
+func GetApiKey(c echo.Context) string {
+	if auth := c.Request().Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return c.QueryParam("api_key")
+}
@@ -156,3 +156,138 @@ func TestGetSession(t *testing.T) {
 		})
 	}
 }
+
+func TestGetMcpSessionId(t *testing.T) {
+	e := echo.New()
+
+	tests := []struct {
+		name           string
+		headerValue    string
+		expectedResult string
+		expectError    bool
+	}{
+		{
+			name:           "Header has session",
+			headerValue:    "session-123",
+			expectedResult: "session-123",
+			expectError:    false,
+		},
+		{
+			name:           "No session",
+			headerValue:    "",
+			expectedResult: "",
+			expectError:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+			if tt.headerValue != "" {
+				req.Header.Set(McpSessionIdHeader, tt.headerValue)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			result, err := GetMcpSessionId(c)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedResult, result)
+			}
+		})
+	}
+}
+
+func TestGetLastEventId(t *testing.T) {
+	e := echo.New()
+
+	tests := []struct {
+		name           string
+		headerValue    string
+		expectedResult uint64
+		expectOk       bool
+	}{
+		{
+			name:           "Header has event id",
+			headerValue:    "42",
+			expectedResult: 42,
+			expectOk:       true,
+		},
+		{
+			name:        "Invalid event id",
+			headerValue: "abc",
+			expectOk:    false,
+		},
+		{
+			name:        "No event id",
+			headerValue: "",
+			expectOk:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/sse", nil)
+			if tt.headerValue != "" {
+				req.Header.Set(LastEventIdHeader, tt.headerValue)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			result, ok := GetLastEventId(c)
+			assert.Equal(t, tt.expectOk, ok)
+			assert.Equal(t, tt.expectedResult, result)
+		})
+	}
+}
+
+func TestGetApiKey(t *testing.T) {
+	e := echo.New()
+
+	tests := []struct {
+		name           string
+		headerValue    string
+		queryValue     string
+		expectedResult string
+	}{
+		{
+			name:           "Header has bearer token",
+			headerValue:    "Bearer key-from-header",
+			queryValue:     "key-from-query",
+			expectedResult: "key-from-header",
+		},
+		{
+			name:           "Query has api key",
+			queryValue:     "key-from-query",
+			expectedResult: "key-from-query",
+		},
+		{
+			name:           "Non-bearer authorization",
+			headerValue:    "Basic abc",
+			expectedResult: "",
+		},
+		{
+			name:           "No api key",
+			expectedResult: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := "/sse"
+			if tt.queryValue != "" {
+				url += "?api_key=" + tt.queryValue
+			}
+			req := httptest.NewRequest(http.MethodGet, url, nil)
+			if tt.headerValue != "" {
+				req.Header.Set("Authorization", tt.headerValue)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			assert.Equal(t, tt.expectedResult, GetApiKey(c))
+		})
+	}
+}
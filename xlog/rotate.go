@@ -0,0 +1,136 @@
+package xlog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	DefaultRotateMaxSize    = 10 * 1024 * 1024 // 单个日志文件默认最大 10MB
+	DefaultRotateMaxBackups = 3                // 默认保留的历史文件数
+)
+
+// RotatingFile 按大小轮转的日志文件，超过 maxSize 时将当前文件重命名为 name.1，已有的历史文件依次后移
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// OpenRotatingFile 在 baseDir/logs 下打开（或创建）轮转日志文件
+func OpenRotatingFile(baseDir, fileName string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	if err := CreateLogDir(baseDir); err != nil {
+		return nil, err
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultRotateMaxSize
+	}
+	if maxBackups < 0 {
+		maxBackups = 0
+	}
+	r := &RotatingFile{
+		path:       filepath.Join(baseDir, "logs", fileName),
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+// Name 返回当前日志文件路径
+func (r *RotatingFile) Name() string {
+	return r.path
+}
+
+// RotatedFiles 返回轮转日志的所有文件路径，按从旧到新排列，不存在的文件会被跳过
+func RotatedFiles(path string, maxBackups int) []string {
+	files := make([]string, 0, maxBackups+1)
+	for i := maxBackups; i >= 0; i-- {
+		name := path
+		if i > 0 {
+			name = fmt.Sprintf("%s.%d", path, i)
+		}
+		if _, err := os.Stat(name); err == nil {
+			files = append(files, name)
+		}
+	}
+	return files
+}
+
+func (r *RotatingFile) backupName(i int) string {
+	return fmt.Sprintf("%s.%d", r.path, i)
+}
+
+// Write 写入日志，写入后超过大小限制时先轮转
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate 关闭当前文件，历史文件依次后移，超出保留数量的最旧文件被覆盖
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	r.file = nil
+	if r.maxBackups == 0 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove log file: %w", err)
+		}
+		return r.open()
+	}
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(r.backupName(i), r.backupName(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+	if err := os.Rename(r.path, r.backupName(1)); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	return r.open()
+}
+
+// Close 关闭日志文件
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}